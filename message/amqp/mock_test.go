@@ -0,0 +1,206 @@
+package amqp
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+// fakeChannel keeps the queues of a broker in memory, so that the provider can be tested without a broker.
+// Messages rejected without requeueing are moved to the queue bound to the dead-letter exchange.
+type fakeChannel struct {
+	mu         sync.Mutex
+	queues     map[string][]fakeMessage
+	deadLetter map[string]string // Dead-letter exchange by queue.
+	bindings   map[string]string // Queue by exchange.
+	unacked    map[uint64]fakeUnacked
+	nextTag    uint64
+	confirm    bool
+	confirms   chan amqp.Confirmation
+	nack       bool   // Nack published messages.
+	fail       string // Method failing with an error.
+	calls      []string
+	closed     bool
+}
+
+type fakeMessage struct {
+	msg         amqp.Publishing
+	redelivered bool
+}
+
+type fakeUnacked struct {
+	queue string
+	fakeMessage
+}
+
+func newFakeChannel() *fakeChannel {
+	return &fakeChannel{
+		queues:     make(map[string][]fakeMessage),
+		deadLetter: make(map[string]string),
+		bindings:   make(map[string]string),
+		unacked:    make(map[uint64]fakeUnacked),
+	}
+}
+
+// call records a call and returns an error if the method should fail.
+func (c *fakeChannel) call(method string) error {
+	c.calls = append(c.calls, method)
+	if c.fail == method {
+		return errors.New("something went wrong")
+	}
+	return nil
+}
+
+func (c *fakeChannel) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.call("ExchangeDeclare")
+}
+
+func (c *fakeChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.call("QueueDeclare"); err != nil {
+		return amqp.Queue{}, err
+	}
+	if exchange, ok := args["x-dead-letter-exchange"].(string); ok {
+		c.deadLetter[name] = exchange
+	}
+	return amqp.Queue{Name: name, Messages: len(c.queues[name])}, nil
+}
+
+func (c *fakeChannel) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.call("QueueBind"); err != nil {
+		return err
+	}
+	c.bindings[exchange] = name
+	return nil
+}
+
+func (c *fakeChannel) Confirm(noWait bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.call("Confirm"); err != nil {
+		return err
+	}
+	c.confirm = true
+	return nil
+}
+
+func (c *fakeChannel) NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.confirms = confirm
+	return confirm
+}
+
+func (c *fakeChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.call("Publish"); err != nil {
+		return err
+	}
+
+	c.nextTag++
+	if !c.nack {
+		// The default exchange routes by queue name.
+		c.queues[key] = append(c.queues[key], fakeMessage{msg: msg})
+	}
+	if c.confirm {
+		c.confirms <- amqp.Confirmation{DeliveryTag: c.nextTag, Ack: !c.nack}
+	}
+	return nil
+}
+
+func (c *fakeChannel) Get(queue string, autoAck bool) (amqp.Delivery, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.call("Get"); err != nil {
+		return amqp.Delivery{}, false, err
+	}
+	if len(c.queues[queue]) == 0 {
+		return amqp.Delivery{}, false, nil
+	}
+
+	m := c.queues[queue][0]
+	c.queues[queue] = c.queues[queue][1:]
+
+	c.nextTag++
+	c.unacked[c.nextTag] = fakeUnacked{queue, m}
+
+	return amqp.Delivery{
+		Headers:     m.msg.Headers,
+		ContentType: m.msg.ContentType,
+		DeliveryTag: c.nextTag,
+		Redelivered: m.redelivered,
+		Body:        m.msg.Body,
+	}, true, nil
+}
+
+func (c *fakeChannel) Ack(tag uint64, multiple bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.call("Ack"); err != nil {
+		return err
+	}
+	if _, ok := c.unacked[tag]; !ok {
+		return errors.New("PRECONDITION_FAILED - unknown delivery tag")
+	}
+	delete(c.unacked, tag)
+	return nil
+}
+
+func (c *fakeChannel) Nack(tag uint64, multiple bool, requeue bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.call("Nack"); err != nil {
+		return err
+	}
+	u, ok := c.unacked[tag]
+	if !ok {
+		return errors.New("PRECONDITION_FAILED - unknown delivery tag")
+	}
+	delete(c.unacked, tag)
+
+	if requeue {
+		u.redelivered = true
+		c.queues[u.queue] = append([]fakeMessage{u.fakeMessage}, c.queues[u.queue]...)
+	} else if dead, ok := c.bindings[c.deadLetter[u.queue]]; ok {
+		c.queues[dead] = append(c.queues[dead], fakeMessage{msg: u.msg})
+	}
+	return nil
+}
+
+func (c *fakeChannel) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	if c.confirms != nil {
+		close(c.confirms)
+	}
+	return c.call("Close")
+}
+
+// redeliver returns the unacknowledged messages to their queues, like the broker does when a channel is closed.
+func (c *fakeChannel) redeliver() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for tag, u := range c.unacked {
+		u.redelivered = true
+		c.queues[u.queue] = append([]fakeMessage{u.fakeMessage}, c.queues[u.queue]...)
+		delete(c.unacked, tag)
+	}
+}
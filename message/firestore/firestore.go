@@ -20,6 +20,9 @@ const (
 	LockDuration  time.Duration = time.Minute * 10
 )
 
+// Using a variable so that we can mock it in tests.
+var now = time.Now
+
 // Provider implements the Provider interface.
 type Provider struct {
 	ctx      context.Context
@@ -43,7 +46,7 @@ func (fs Provider) GetNextMessage() (*message.Message, error) {
 		// Conditions provided to the client query.
 		[]fsClient.Condition{
 			{"retry_available", "==", true},
-			{"lock", "<", time.Now().UnixNano()},
+			{"lock", "<", now().UnixNano()},
 		},
 		// Order parameters for the results.
 		[]fsClient.Order{
@@ -67,7 +70,12 @@ func (fs Provider) GetNextMessage() (*message.Message, error) {
 			out := map[string]interface{}{
 				"retries":         retries,
 				"retry_available": retryAvailable,
-				"lock":            time.Now().Add(LockDuration).UnixNano(),
+				"lock":            now().Add(LockDuration).UnixNano(),
+			}
+
+			// The message is dead if this last attempt is not deleted.
+			if !retryAvailable {
+				out["status"] = message.StatusDead
 			}
 			return out, nil
 		},
@@ -96,6 +104,113 @@ func (fs Provider) DeleteMessage(ref *string) error {
 	return fs.client.DeleteDoc(fmt.Sprintf("%s/%s", fs.rootPath, *ref))
 }
 
+// FailMessage records the reason on a received message.
+func (fs Provider) FailMessage(ref *string, reason string) error {
+	if ref == nil {
+		return errors.New("firestore: no message reference")
+	}
+
+	path := fmt.Sprintf("%s/%s", fs.rootPath, *ref)
+
+	// Setting a deleted Document would create it again.
+	if fs.client.GetDoc(path) == nil {
+		return nil
+	}
+
+	return fs.client.SetDoc(path, map[string]interface{}{
+		"last_error": reason,
+	})
+}
+
+// DeadMessages gets the dead messages from Firestore, the oldest failure first.
+func (fs Provider) DeadMessages() ([]*message.QueueMessage, error) {
+	items, err := fs.client.QueryItems(fs.rootPath, deadConditions(), []fsClient.Order{
+		{"lock", "asc"},
+		{"created", "asc"},
+	}, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var dead []*message.QueueMessage
+	for _, item := range items {
+		data := item.(map[string]interface{})
+		qmsg := itom(data)
+		if qmsg == nil || qmsg.Message == nil {
+			continue
+		}
+
+		if ref, ok := data["_id"].(string); ok {
+			qmsg.Message.ExternalRef = &ref
+		}
+		dead = append(dead, qmsg)
+	}
+
+	return dead, nil
+}
+
+// RequeueMessage makes a dead message available again with all its retries.
+func (fs Provider) RequeueMessage(ref *string) error {
+	if ref == nil {
+		return errors.New("firestore: no message reference")
+	}
+
+	path := fmt.Sprintf("%s/%s", fs.rootPath, *ref)
+
+	data := fs.client.GetDoc(path)
+	retryAvailable, _ := data["retry_available"].(bool)
+	lock, _ := data["lock"].(int64)
+	if data == nil || retryAvailable || lock >= now().UnixNano() {
+		return message.ErrNotDead
+	}
+
+	return fs.client.SetDoc(path, requeueData())
+}
+
+// RequeueDeadMessages requeues all dead messages in a transaction.
+func (fs Provider) RequeueDeadMessages() (int, error) {
+	items, err := fs.client.QueryItems(fs.rootPath, deadConditions(), nil, 0,
+		func(data map[string]interface{}) (map[string]interface{}, error) {
+			return requeueData(), nil
+		},
+	)
+	return len(items), err
+}
+
+// PurgeDeadMessages deletes all dead messages.
+func (fs Provider) PurgeDeadMessages() (int, error) {
+	dead, err := fs.DeadMessages()
+	if err != nil {
+		return 0, err
+	}
+
+	for i, qmsg := range dead {
+		if err := fs.DeleteMessage(qmsg.Message.ExternalRef); err != nil {
+			return i, err
+		}
+	}
+
+	return len(dead), nil
+}
+
+// deadConditions returns the query conditions of dead messages: out of retries and no longer locked.
+func deadConditions() []fsClient.Condition {
+	return []fsClient.Condition{
+		{"retry_available", "==", false},
+		{"lock", "<", now().UnixNano()},
+	}
+}
+
+// requeueData returns the fields updated to requeue a message. The last error is kept.
+func requeueData() map[string]interface{} {
+	return map[string]interface{}{
+		"lock":            int64(0),
+		"retries":         int64(RetryAttempts),
+		"retry_available": true,
+		"status":          message.StatusPending,
+	}
+}
+
 // Close the Firestore client.
 func (fs Provider) Close() error {
 	if fs.client != nil {
@@ -126,11 +241,11 @@ func generateMessage(in *message.Message) map[string]interface{} {
 
 	// Return the QueueMessage as an interface map.
 	return map[string]interface{}{
-		"created":         time.Now().UnixNano(),
+		"created":         now().UnixNano(),
 		"lock":            int64(0),
 		"retries":         int64(RetryAttempts),
 		"message":         msgMap,
-		"status":          "pending",
+		"status":          message.StatusPending,
 		"retry_available": true,
 	}
 }
@@ -4,8 +4,10 @@ import (
 	"context"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/message/messagetest"
 	fsClient "github.com/wptide/pkg/wrapper/firestore"
 )
 
@@ -274,3 +276,35 @@ func TestFirestoreProvider_Close(t *testing.T) {
 		})
 	}
 }
+
+func TestFirestoreProvider_conformance(t *testing.T) {
+	clock := time.Unix(1500000000, 0)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	newProvider := func() message.Provider {
+		p, _ := NewWithClient(context.Background(), "project", "queue", newFakeClient())
+		return p
+	}
+
+	messagetest.TestProvider(t, newProvider, messagetest.Options{
+		ExpireLocks:   func() { clock = clock.Add(LockDuration + time.Second) },
+		RetryAttempts: RetryAttempts,
+	})
+}
+
+func TestFirestoreProvider_deadLetters(t *testing.T) {
+	clock := time.Unix(1500000000, 0)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	newProvider := func() message.DeadLetterProvider {
+		p, _ := NewWithClient(context.Background(), "project", "queue", newFakeClient())
+		return p
+	}
+
+	messagetest.TestDeadLetters(t, newProvider, messagetest.Options{
+		ExpireLocks:   func() { clock = clock.Add(LockDuration + time.Second) },
+		RetryAttempts: RetryAttempts,
+	})
+}
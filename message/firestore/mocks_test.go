@@ -2,6 +2,9 @@ package firestore
 
 import (
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/wptide/pkg/message"
 	fsClient "github.com/wptide/pkg/wrapper/firestore"
@@ -81,3 +84,119 @@ func (m mockClient) QueryItems(collection string, conditions []fsClient.Conditio
 func (m mockClient) DeleteDoc(path string) error {
 	return nil
 }
+
+// fakeClient is a ClientInterface keeping documents in memory, evaluating queries like Firestore.
+type fakeClient struct {
+	docs   map[string]map[string]interface{}
+	nextID int
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{docs: make(map[string]map[string]interface{})}
+}
+
+func (f *fakeClient) GetDoc(path string) map[string]interface{} {
+	return f.docs[path]
+}
+
+// SetDoc merges the data into the document, like the Client.
+func (f *fakeClient) SetDoc(path string, data map[string]interface{}) error {
+	if f.docs[path] == nil {
+		f.docs[path] = make(map[string]interface{})
+	}
+	for key, val := range data {
+		f.docs[path][key] = val
+	}
+	return nil
+}
+
+func (f *fakeClient) AddDoc(collection string, data interface{}) error {
+	doc, ok := data.(map[string]interface{})
+	if !ok {
+		return errors.New("fake client only stores maps")
+	}
+	f.nextID++
+	f.docs[fmt.Sprintf("%s/%08d", collection, f.nextID)] = doc
+	return nil
+}
+
+func (f *fakeClient) Authenticated() bool {
+	return true
+}
+
+func (f *fakeClient) Close() error {
+	return nil
+}
+
+func (f *fakeClient) QueryItems(collection string, conditions []fsClient.Condition, ordering []fsClient.Order, limit int, updateFunc fsClient.UpdateFunc) ([]interface{}, error) {
+	var paths []string
+	for path, doc := range f.docs {
+		if strings.HasPrefix(path, collection+"/") && matches(doc, conditions) {
+			paths = append(paths, path)
+		}
+	}
+
+	// Document ids break ties, like in Firestore.
+	sort.Strings(paths)
+	sort.SliceStable(paths, func(i, j int) bool {
+		for _, order := range ordering {
+			a, b := f.docs[paths[i]][order.Field].(int64), f.docs[paths[j]][order.Field].(int64)
+			if a != b {
+				return (a < b) == (order.Direction != "desc")
+			}
+		}
+		return false
+	})
+
+	if limit != 0 && len(paths) > limit {
+		paths = paths[:limit]
+	}
+
+	var items []interface{}
+	for _, path := range paths {
+		doc := f.docs[path]
+		if updateFunc != nil {
+			data, err := updateFunc(doc)
+			if err != nil {
+				return nil, err
+			}
+			for key, val := range data {
+				doc[key] = val
+			}
+		}
+
+		item := make(map[string]interface{})
+		for key, val := range doc {
+			item[key] = val
+		}
+		item["_id"] = strings.TrimPrefix(path, collection+"/")
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+func (f *fakeClient) DeleteDoc(path string) error {
+	delete(f.docs, path)
+	return nil
+}
+
+// matches evaluates the "==" and "<" conditions used by the provider.
+func matches(doc map[string]interface{}, conditions []fsClient.Condition) bool {
+	for _, c := range conditions {
+		switch c.Operator {
+		case "==":
+			if doc[c.Path] != c.Value {
+				return false
+			}
+		case "<":
+			value, _ := doc[c.Path].(int64)
+			if value >= c.Value.(int64) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,315 @@
+// Package memory is a message provider keeping the queue in memory, for tests and single process workers.
+//
+// Messages are locked and retried like with the Firestore and Mongo providers: a received message is
+// locked for LockDuration, after which it is delivered again until its retries run out. Messages out
+// of retries are kept as dead messages, see message.DeadLetterProvider.
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/wptide/pkg/message"
+)
+
+const (
+	// RetryAttempts sets the amount of default retries.
+	RetryAttempts = 3
+
+	// LockDuration sets how long an item needs to be locked for.
+	LockDuration time.Duration = time.Minute * 10
+)
+
+var (
+	// Using a variable so that we can mock it in tests.
+	now = time.Now
+
+	// ErrClosed is returned when using a closed provider.
+	ErrClosed = errors.New("memory: provider is closed")
+)
+
+// Provider is an in-memory message queue. It is safe for concurrent use.
+type Provider struct {
+	mu            sync.Mutex
+	items         map[string]*message.QueueMessage
+	nextID        int
+	lockDuration  time.Duration
+	retryAttempts int64
+	closed        bool
+}
+
+// New returns an empty queue with the default lock duration and retries.
+func New() *Provider {
+	return NewWithOptions(LockDuration, RetryAttempts)
+}
+
+// NewWithOptions returns an empty queue locking received messages for lockDuration
+// and delivering them at most retryAttempts times.
+func NewWithOptions(lockDuration time.Duration, retryAttempts int) *Provider {
+	return &Provider{
+		items:         make(map[string]*message.QueueMessage),
+		lockDuration:  lockDuration,
+		retryAttempts: int64(retryAttempts),
+	}
+}
+
+// SendMessage adds a copy of the message to the queue.
+func (p *Provider) SendMessage(msg *message.Message) error {
+	// Copy the message so that later changes by the caller don't change the queue.
+	queued, err := copyMessage(msg)
+	if err != nil {
+		return err
+	}
+	queued.ExternalRef = nil
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return ErrClosed
+	}
+
+	p.nextID++
+	p.items[strconv.Itoa(p.nextID)] = &message.QueueMessage{
+		Created:        now().UnixNano(),
+		Lock:           0,
+		Message:        queued,
+		Retries:        p.retryAttempts,
+		Status:         message.StatusPending,
+		RetryAvailable: true,
+	}
+
+	return nil
+}
+
+// GetNextMessage locks and returns the next available message, or nil if there is none.
+// Messages whose lock expired come before new messages, then the oldest message comes first.
+func (p *Provider) GetNextMessage() (*message.Message, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, ErrClosed
+	}
+
+	t := now()
+
+	var available []string
+	for id, item := range p.items {
+		if item.RetryAvailable && item.Lock < t.UnixNano() {
+			available = append(available, id)
+		}
+	}
+	if len(available) == 0 {
+		return nil, nil
+	}
+
+	p.sort(available)
+
+	id := available[0]
+	item := p.items[id]
+
+	// Decrease retries and lock the item.
+	item.Retries--
+	item.RetryAvailable = item.Retries > 0
+	item.Lock = t.Add(p.lockDuration).UnixNano()
+	if !item.RetryAvailable {
+		item.Status = message.StatusDead
+	}
+
+	msg, err := copyMessage(item.Message)
+	if err != nil {
+		return nil, err
+	}
+	msg.ExternalRef = &id
+
+	return msg, nil
+}
+
+// DeleteMessage removes a message from the queue. Deleting a message that does not exist is not an error.
+func (p *Provider) DeleteMessage(ref *string) error {
+	if ref == nil {
+		return errors.New("memory: no message reference")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return ErrClosed
+	}
+
+	delete(p.items, *ref)
+	return nil
+}
+
+// FailMessage records the reason on a received message.
+func (p *Provider) FailMessage(ref *string, reason string) error {
+	if ref == nil {
+		return errors.New("memory: no message reference")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return ErrClosed
+	}
+
+	if item, ok := p.items[*ref]; ok {
+		item.LastError = reason
+	}
+	return nil
+}
+
+// DeadMessages returns copies of the dead messages, the oldest failure first.
+func (p *Provider) DeadMessages() ([]*message.QueueMessage, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, ErrClosed
+	}
+
+	var dead []*message.QueueMessage
+	for _, id := range p.dead() {
+		item := *p.items[id]
+
+		msg, err := copyMessage(item.Message)
+		if err != nil {
+			return nil, err
+		}
+		ref := id
+		msg.ExternalRef = &ref
+		item.Message = msg
+
+		dead = append(dead, &item)
+	}
+
+	return dead, nil
+}
+
+// RequeueMessage makes a dead message available again with all its retries.
+func (p *Provider) RequeueMessage(ref *string) error {
+	if ref == nil {
+		return errors.New("memory: no message reference")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return ErrClosed
+	}
+
+	item, ok := p.items[*ref]
+	if !ok || !isDead(item) {
+		return message.ErrNotDead
+	}
+	p.requeue(item)
+
+	return nil
+}
+
+// RequeueDeadMessages requeues all dead messages.
+func (p *Provider) RequeueDeadMessages() (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return 0, ErrClosed
+	}
+
+	dead := p.dead()
+	for _, id := range dead {
+		p.requeue(p.items[id])
+	}
+
+	return len(dead), nil
+}
+
+// PurgeDeadMessages deletes all dead messages.
+func (p *Provider) PurgeDeadMessages() (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return 0, ErrClosed
+	}
+
+	dead := p.dead()
+	for _, id := range dead {
+		delete(p.items, id)
+	}
+
+	return len(dead), nil
+}
+
+// dead returns the IDs of the dead messages, the oldest failure first.
+func (p *Provider) dead() []string {
+	var ids []string
+	for id, item := range p.items {
+		if isDead(item) {
+			ids = append(ids, id)
+		}
+	}
+	p.sort(ids)
+	return ids
+}
+
+// requeue resets the retries of a message. The last error is kept.
+func (p *Provider) requeue(item *message.QueueMessage) {
+	item.Lock = 0
+	item.Retries = p.retryAttempts
+	item.RetryAvailable = true
+	item.Status = message.StatusPending
+}
+
+// sort sorts message IDs by lock, then the oldest message first.
+func (p *Provider) sort(ids []string) {
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := p.items[ids[i]], p.items[ids[j]]
+		if a.Lock != b.Lock {
+			return a.Lock < b.Lock
+		}
+		if a.Created != b.Created {
+			return a.Created < b.Created
+		}
+		// Messages sent at the same time keep their order.
+		ai, _ := strconv.Atoi(ids[i])
+		bi, _ := strconv.Atoi(ids[j])
+		return ai < bi
+	})
+}
+
+// isDead tells whether the lock of the last attempt of a message expired.
+func isDead(item *message.QueueMessage) bool {
+	return !item.RetryAvailable && item.Lock < now().UnixNano()
+}
+
+// Close releases the queue. The provider can't be used afterwards.
+func (p *Provider) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	p.items = nil
+
+	return nil
+}
+
+// copyMessage returns a deep copy of a message.
+func copyMessage(msg *message.Message) (*message.Message, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var c *message.Message
+	err = json.Unmarshal(data, &c)
+	return c, err
+}
@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/message/messagetest"
+)
+
+func TestProvider(t *testing.T) {
+	clock := time.Unix(1500000000, 0)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	messagetest.TestProvider(t, func() message.Provider { return New() }, messagetest.Options{
+		ExpireLocks:   func() { clock = clock.Add(LockDuration + time.Second) },
+		RetryAttempts: RetryAttempts,
+	})
+}
+
+func TestNewWithOptions(t *testing.T) {
+	clock := time.Unix(1500000000, 0)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	p := NewWithOptions(time.Minute, 1)
+	p.SendMessage(&message.Message{Title: "Once"})
+
+	if msg, _ := p.GetNextMessage(); msg == nil {
+		t.Fatalf("Provider.GetNextMessage() = nil, want a message")
+	}
+
+	clock = clock.Add(2 * time.Minute)
+	if msg, _ := p.GetNextMessage(); msg != nil {
+		t.Errorf("Provider.GetNextMessage() = %+v, want no retry", msg)
+	}
+}
+
+func TestProvider_expiredFirst(t *testing.T) {
+	clock := time.Unix(1500000000, 0)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	p := New()
+	p.SendMessage(&message.Message{Title: "Expired"})
+	p.GetNextMessage()
+
+	clock = clock.Add(LockDuration + time.Second)
+	p.SendMessage(&message.Message{Title: "New"})
+
+	// New messages have no lock, so they come before retries like with Firestore.
+	if msg, _ := p.GetNextMessage(); msg == nil || msg.Title != "New" {
+		t.Errorf("Provider.GetNextMessage() = %+v, want the new message", msg)
+	}
+}
+
+func TestProvider_Close(t *testing.T) {
+	p := New()
+	p.SendMessage(&message.Message{Title: "Closed"})
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("Provider.Close() error = %v", err)
+	}
+
+	ref := "1"
+	if err := p.SendMessage(&message.Message{}); err != ErrClosed {
+		t.Errorf("Provider.SendMessage() error = %v, want %v", err, ErrClosed)
+	}
+	if _, err := p.GetNextMessage(); err != ErrClosed {
+		t.Errorf("Provider.GetNextMessage() error = %v, want %v", err, ErrClosed)
+	}
+	if err := p.DeleteMessage(&ref); err != ErrClosed {
+		t.Errorf("Provider.DeleteMessage() error = %v, want %v", err, ErrClosed)
+	}
+	if err := p.DeleteMessage(nil); err == nil {
+		t.Errorf("Provider.DeleteMessage() error = nil, want error without a reference")
+	}
+}
+
+func TestProvider_deadLetters(t *testing.T) {
+	clock := time.Unix(1500000000, 0)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	messagetest.TestDeadLetters(t, func() message.DeadLetterProvider { return New() }, messagetest.Options{
+		ExpireLocks:   func() { clock = clock.Add(LockDuration + time.Second) },
+		RetryAttempts: RetryAttempts,
+	})
+}
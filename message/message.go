@@ -1,5 +1,20 @@
 package message
 
+import "errors"
+
+// Status values of a QueueMessage.
+const (
+	// StatusPending is the status of a message that has retries left.
+	StatusPending = "pending"
+
+	// StatusDead is the status of a message on its last attempt. If it is not deleted
+	// before its lock expires, it is a dead message.
+	StatusDead = "dead"
+)
+
+// ErrNotDead is returned when requeueing a message that is not a dead message.
+var ErrNotDead = errors.New("message: not a dead message")
+
 // QueueMessage defines how messages are stored in a document store.
 type QueueMessage struct {
 	Created        int64    `json:"created" firestore:"created"`
@@ -8,6 +23,7 @@ type QueueMessage struct {
 	Retries        int64    `json:"retries" firestore:"retries"`
 	Status         string   `json:"status" firestore:"status"`
 	RetryAvailable bool     `json:"retry_available" firestore:"retry_available"`
+	LastError      string   `json:"last_error,omitempty" firestore:"last_error,omitempty"`
 }
 
 // Message represents a task to read from or send to a queue.
@@ -45,10 +61,44 @@ type AuditOption struct {
 	StandardOverride string `json:"standard-override,omitempty"`
 }
 
-// Provider is an interface for creating new providers. E.g. firestore, mongo, sqs.
+// Provider is an interface for creating new providers. E.g. firestore, mongo, sqs, postgres, redis, amqp, memory.
+// The messagetest package checks that a provider behaves like the others.
 type Provider interface {
 	SendMessage(msg *Message) error
 	GetNextMessage() (*Message, error)
 	DeleteMessage(ref *string) error
 	Close() error
 }
+
+// FailureRecorder is implemented by providers that can record why processing a message failed.
+type FailureRecorder interface {
+	// FailMessage records the reason on a received message. The message is retried as usual.
+	// Recording a failure for a message that does not exist is not an error.
+	FailMessage(ref *string, reason string) error
+}
+
+// DeadLetterProvider is implemented by providers keeping the messages that ran out of retries.
+// A dead message is a message that was not deleted after its last attempt, once its lock expired.
+// The firestore, mongo, postgres and memory providers implement it.
+//
+// The redis and amqp providers don't: their queued messages can't be changed to record a failure,
+// and their brokers keep the messages that ran out of retries (see the documentation of each package).
+// Neither does sqs, where a redrive policy of the queue moves them to a dead-letter queue.
+type DeadLetterProvider interface {
+	Provider
+	FailureRecorder
+
+	// DeadMessages returns the dead messages, the oldest failure first.
+	// The ExternalRef of their Message references the dead message.
+	DeadMessages() ([]*QueueMessage, error)
+
+	// RequeueMessage makes a dead message available again with all its retries.
+	// It returns ErrNotDead if the message is not a dead message.
+	RequeueMessage(ref *string) error
+
+	// RequeueDeadMessages requeues all dead messages and returns how many were requeued.
+	RequeueDeadMessages() (int, error)
+
+	// PurgeDeadMessages deletes all dead messages and returns how many were deleted.
+	PurgeDeadMessages() (int, error)
+}
@@ -0,0 +1,330 @@
+// Package messagetest checks that message providers behave the same way.
+package messagetest
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/message"
+)
+
+// Options describes the provider under test.
+type Options struct {
+	// ExpireLocks makes the locks of the received messages expire, e.g. by moving the clock of
+	// the provider past its lock duration. The lock and retry checks are skipped without it.
+	ExpireLocks func()
+
+	// RetryAttempts is the number of times a message is delivered before it is dropped. Defaults to 3.
+	RetryAttempts int
+}
+
+// TestProvider sends, receives and deletes messages with the providers returned by newProvider.
+// Every call of newProvider must return a provider for a new, empty queue.
+func TestProvider(t *testing.T, newProvider func() message.Provider, opts Options) {
+	if opts.RetryAttempts == 0 {
+		opts.RetryAttempts = 3
+	}
+
+	run := func(name string, test func(t *testing.T, p message.Provider)) {
+		t.Run(name, func(t *testing.T) {
+			p := newProvider()
+			defer p.Close()
+			test(t, p)
+		})
+	}
+
+	run("Empty Queue", func(t *testing.T, p message.Provider) {
+		// Some providers also return an error for an empty queue.
+		if msg, _ := p.GetNextMessage(); msg != nil {
+			t.Errorf("GetNextMessage() = %+v, want no message", msg)
+		}
+	})
+
+	run("Send And Receive", func(t *testing.T, p message.Provider) {
+		sent := newMessage("Send And Receive")
+		if err := p.SendMessage(sent); err != nil {
+			t.Fatalf("SendMessage() error = %v", err)
+		}
+
+		// Changing the message after sending it does not change the queued message.
+		sent.Audits[0].Options.Standard = "changed"
+
+		got := receive(t, p)
+		if got.ExternalRef == nil || *got.ExternalRef == "" {
+			t.Errorf("GetNextMessage() ExternalRef = %v, want a reference", got.ExternalRef)
+		}
+
+		got.ExternalRef = nil
+		if want := newMessage("Send And Receive"); !reflect.DeepEqual(got, want) {
+			t.Errorf("GetNextMessage() = %+v, want %+v", got, want)
+		}
+	})
+
+	run("Oldest First", func(t *testing.T, p message.Provider) {
+		for _, title := range []string{"First", "Second", "Third"} {
+			if err := p.SendMessage(newMessage(title)); err != nil {
+				t.Fatalf("SendMessage() error = %v", err)
+			}
+		}
+
+		for _, want := range []string{"First", "Second", "Third"} {
+			if got := receive(t, p); got.Title != want {
+				t.Errorf("GetNextMessage() Title = %q, want %q", got.Title, want)
+			}
+		}
+	})
+
+	run("Locked While Processing", func(t *testing.T, p message.Provider) {
+		p.SendMessage(newMessage("Locked"))
+		receive(t, p)
+
+		if msg, _ := p.GetNextMessage(); msg != nil {
+			t.Errorf("GetNextMessage() = %+v, want no message while the first one is locked", msg)
+		}
+	})
+
+	run("Delete", func(t *testing.T, p message.Provider) {
+		p.SendMessage(newMessage("Delete"))
+		p.SendMessage(newMessage("Keep"))
+
+		got := receive(t, p)
+		if err := p.DeleteMessage(got.ExternalRef); err != nil {
+			t.Fatalf("DeleteMessage() error = %v", err)
+		}
+
+		if opts.ExpireLocks != nil {
+			opts.ExpireLocks()
+		}
+
+		if got := receive(t, p); got.Title != "Keep" {
+			t.Errorf("GetNextMessage() Title = %q, want the message that was not deleted", got.Title)
+		}
+	})
+
+	if opts.ExpireLocks == nil {
+		return
+	}
+
+	run("Retry After Lock Expires", func(t *testing.T, p message.Provider) {
+		p.SendMessage(newMessage("Retry"))
+
+		for attempt := 1; attempt <= opts.RetryAttempts; attempt++ {
+			got, err := p.GetNextMessage()
+			if err != nil || got == nil || got.Title != "Retry" {
+				t.Fatalf("GetNextMessage() attempt %d = %+v, %v, want the message again", attempt, got, err)
+			}
+			opts.ExpireLocks()
+		}
+
+		if msg, _ := p.GetNextMessage(); msg != nil {
+			t.Errorf("GetNextMessage() = %+v, want no message after %d attempts", msg, opts.RetryAttempts)
+		}
+	})
+
+	run("Delete After Retry", func(t *testing.T, p message.Provider) {
+		p.SendMessage(newMessage("Retry"))
+
+		receive(t, p)
+		opts.ExpireLocks()
+
+		// The reference of the latest delivery deletes the message.
+		got := receive(t, p)
+		if err := p.DeleteMessage(got.ExternalRef); err != nil {
+			t.Fatalf("DeleteMessage() error = %v", err)
+		}
+		opts.ExpireLocks()
+
+		if msg, _ := p.GetNextMessage(); msg != nil {
+			t.Errorf("GetNextMessage() = %+v, want no message after deleting it", msg)
+		}
+	})
+}
+
+// TestDeadLetters checks the dead-letter API with the providers returned by newProvider.
+// Every call of newProvider must return a provider for a new, empty queue. The ExpireLocks option is required.
+func TestDeadLetters(t *testing.T, newProvider func() message.DeadLetterProvider, opts Options) {
+	if opts.ExpireLocks == nil {
+		t.Fatal("messagetest: TestDeadLetters needs the ExpireLocks option")
+	}
+	if opts.RetryAttempts == 0 {
+		opts.RetryAttempts = 3
+	}
+
+	run := func(name string, test func(t *testing.T, p message.DeadLetterProvider)) {
+		t.Run(name, func(t *testing.T) {
+			p := newProvider()
+			defer p.Close()
+			test(t, p)
+		})
+	}
+
+	// kill sends messages and fails all their attempts.
+	kill := func(t *testing.T, p message.DeadLetterProvider, titles ...string) {
+		for _, title := range titles {
+			p.SendMessage(newMessage(title))
+		}
+		for attempt := 1; attempt <= opts.RetryAttempts; attempt++ {
+			for {
+				msg, _ := p.GetNextMessage()
+				if msg == nil {
+					break
+				}
+				if err := p.FailMessage(msg.ExternalRef, fmt.Sprintf("%s: attempt %d failed", msg.Title, attempt)); err != nil {
+					t.Fatalf("FailMessage() error = %v", err)
+				}
+			}
+			opts.ExpireLocks()
+		}
+	}
+
+	run("No Dead Messages", func(t *testing.T, p message.DeadLetterProvider) {
+		p.SendMessage(newMessage("Pending"))
+
+		if dead, err := p.DeadMessages(); err != nil || len(dead) != 0 {
+			t.Errorf("DeadMessages() = %v, %v, want no messages", dead, err)
+		}
+	})
+
+	run("Dead After Retries", func(t *testing.T, p message.DeadLetterProvider) {
+		kill(t, p, "Dead")
+
+		if msg, _ := p.GetNextMessage(); msg != nil {
+			t.Errorf("GetNextMessage() = %+v, want no message", msg)
+		}
+
+		dead, err := p.DeadMessages()
+		if err != nil || len(dead) != 1 {
+			t.Fatalf("DeadMessages() = %v, %v, want 1 message", dead, err)
+		}
+		got := dead[0]
+		if got.Message == nil || got.Message.Title != "Dead" || got.Message.ExternalRef == nil {
+			t.Errorf("DeadMessages() Message = %+v, want the dead message with a reference", got.Message)
+		}
+		if want := fmt.Sprintf("Dead: attempt %d failed", opts.RetryAttempts); got.LastError != want {
+			t.Errorf("DeadMessages() LastError = %q, want %q", got.LastError, want)
+		}
+		if got.Status != message.StatusDead || got.RetryAvailable {
+			t.Errorf("DeadMessages() Status, RetryAvailable = %q, %v, want %q, false", got.Status, got.RetryAvailable, message.StatusDead)
+		}
+	})
+
+	run("Locked On Last Attempt", func(t *testing.T, p message.DeadLetterProvider) {
+		p.SendMessage(newMessage("Last Attempt"))
+		for attempt := 1; attempt < opts.RetryAttempts; attempt++ {
+			receive(t, p)
+			opts.ExpireLocks()
+		}
+		last := receive(t, p)
+
+		if dead, _ := p.DeadMessages(); len(dead) != 0 {
+			t.Errorf("DeadMessages() = %v, want no messages while the last attempt is locked", dead)
+		}
+
+		// Deleting the message after a successful last attempt leaves no dead message.
+		p.DeleteMessage(last.ExternalRef)
+		opts.ExpireLocks()
+		if dead, _ := p.DeadMessages(); len(dead) != 0 {
+			t.Errorf("DeadMessages() = %v, want no messages after deleting the message", dead)
+		}
+	})
+
+	run("Requeue", func(t *testing.T, p message.DeadLetterProvider) {
+		kill(t, p, "First", "Second")
+
+		dead, _ := p.DeadMessages()
+		if len(dead) != 2 {
+			t.Fatalf("DeadMessages() = %v, want 2 messages", dead)
+		}
+		if err := p.RequeueMessage(dead[0].Message.ExternalRef); err != nil {
+			t.Fatalf("RequeueMessage() error = %v", err)
+		}
+
+		got := receive(t, p)
+		if got.Title != dead[0].Message.Title {
+			t.Errorf("GetNextMessage() Title = %q, want the requeued %q", got.Title, dead[0].Message.Title)
+		}
+		if err := p.RequeueMessage(got.ExternalRef); err != message.ErrNotDead {
+			t.Errorf("RequeueMessage() error = %v, want %v for a message that is not dead", err, message.ErrNotDead)
+		}
+
+		// The message has all its retries again.
+		for attempt := 2; attempt <= opts.RetryAttempts; attempt++ {
+			opts.ExpireLocks()
+			receive(t, p)
+		}
+
+		if dead, _ := p.DeadMessages(); len(dead) != 1 {
+			t.Errorf("DeadMessages() = %v, want 1 message", dead)
+		}
+	})
+
+	run("Requeue All", func(t *testing.T, p message.DeadLetterProvider) {
+		kill(t, p, "First", "Second")
+
+		if n, err := p.RequeueDeadMessages(); err != nil || n != 2 {
+			t.Errorf("RequeueDeadMessages() = %d, %v, want 2", n, err)
+		}
+		receive(t, p)
+		receive(t, p)
+
+		if dead, _ := p.DeadMessages(); len(dead) != 0 {
+			t.Errorf("DeadMessages() = %v, want no messages", dead)
+		}
+	})
+
+	run("Purge", func(t *testing.T, p message.DeadLetterProvider) {
+		kill(t, p, "First", "Second")
+		p.SendMessage(newMessage("Pending"))
+
+		if n, err := p.PurgeDeadMessages(); err != nil || n != 2 {
+			t.Errorf("PurgeDeadMessages() = %d, %v, want 2", n, err)
+		}
+		if dead, _ := p.DeadMessages(); len(dead) != 0 {
+			t.Errorf("DeadMessages() = %v, want no messages", dead)
+		}
+		if got := receive(t, p); got.Title != "Pending" {
+			t.Errorf("GetNextMessage() Title = %q, want the pending message", got.Title)
+		}
+	})
+
+	run("Fail Without Reference", func(t *testing.T, p message.DeadLetterProvider) {
+		if err := p.FailMessage(nil, "failed"); err == nil {
+			t.Errorf("FailMessage() error = nil, want error without a reference")
+		}
+		if err := p.RequeueMessage(nil); err == nil {
+			t.Errorf("RequeueMessage() error = nil, want error without a reference")
+		}
+	})
+}
+
+// receive returns the next message, failing the test if there is none.
+func receive(t *testing.T, p message.Provider) *message.Message {
+	t.Helper()
+
+	msg, err := p.GetNextMessage()
+	if err != nil {
+		t.Fatalf("GetNextMessage() error = %v", err)
+	}
+	if msg == nil {
+		t.Fatalf("GetNextMessage() = nil, want a message")
+	}
+	return msg
+}
+
+func newMessage(title string) *message.Message {
+	return &message.Message{
+		Title:         title,
+		Slug:          "twentyseventeen",
+		SourceURL:     "https://downloads.wordpress.org/theme/twentyseventeen.1.4.zip",
+		SourceType:    "zip",
+		RequestClient: "wporg",
+		Visibility:    "public",
+		Audits: []*message.Audit{
+			{
+				Type:    "phpcs",
+				Options: &message.AuditOption{Standard: "wordpress"},
+			},
+		},
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"sort"
+	"sync"
 
 	"github.com/mongodb/mongo-go-driver/bson"
 	"github.com/mongodb/mongo-go-driver/bson/objectid"
 	"github.com/mongodb/mongo-go-driver/core/option"
+	"github.com/mongodb/mongo-go-driver/mongo"
 	"github.com/wptide/pkg/message"
 	wrapper "github.com/wptide/pkg/wrapper/mongo"
 )
@@ -64,6 +67,69 @@ func (m MockCollection) FindOneAndDelete(ctx context.Context, filter interface{}
 	return nil
 }
 
+func (m MockCollection) Find(ctx context.Context, filter interface{}, opts ...option.FindOptioner) (wrapper.CursorLayer, error) {
+
+	switch m.collection {
+	case "test-fail":
+		return nil, errors.New("something went wrong")
+	case "test-valid-message":
+		return &MockCursor{
+			collection: m.collection,
+			documents:  2,
+		}, nil
+	default:
+		return &MockCursor{}, nil
+	}
+}
+
+func (m MockCollection) UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...option.UpdateOptioner) (*mongo.UpdateResult, error) {
+
+	switch m.collection {
+	case "test-fail":
+		return nil, errors.New("something went wrong")
+	default:
+		return &mongo.UpdateResult{MatchedCount: 2, ModifiedCount: 2}, nil
+	}
+}
+
+func (m MockCollection) DeleteMany(ctx context.Context, filter interface{}, opts ...option.DeleteOptioner) (*mongo.DeleteResult, error) {
+
+	switch m.collection {
+	case "test-fail":
+		return nil, errors.New("something went wrong")
+	default:
+		return &mongo.DeleteResult{DeletedCount: 2}, nil
+	}
+}
+
+// MockCursor returns the document of its collection the given number of times.
+type MockCursor struct {
+	collection string
+	documents  int
+}
+
+func (c *MockCursor) Next(ctx context.Context) bool {
+	if c.documents == 0 {
+		return false
+	}
+	c.documents--
+	return true
+}
+
+func (c *MockCursor) Decode() (*bson.Document, error) {
+	return MockDocumentResult{
+		collection: c.collection,
+	}.Decode()
+}
+
+func (c *MockCursor) Err() error {
+	return nil
+}
+
+func (c *MockCursor) Close(ctx context.Context) error {
+	return nil
+}
+
 type MockDocumentResult struct {
 	collection string
 }
@@ -104,6 +170,9 @@ func (d MockDocumentResult) Decode() (*bson.Document, error) {
 	case "test-lock-fail-update":
 		return nil, errors.New("something went wrong")
 
+	case "test-no-records-update":
+		return nil, mongo.ErrNoDocuments
+
 	case "test-lock-fail":
 		msg := generateMessage(&message.Message{
 			Title: "Plugin One",
@@ -119,3 +188,215 @@ func (d MockDocumentResult) Decode() (*bson.Document, error) {
 
 	return document, nil
 }
+
+// memoryClient keeps the documents of a single collection in memory and applies the
+// filters and updates of the provider to them, so that the provider can be tested as a queue.
+type memoryClient struct {
+	mu        sync.Mutex
+	documents []map[string]interface{}
+}
+
+func newMemoryClient() *memoryClient {
+	return &memoryClient{}
+}
+
+func (c *memoryClient) Database(string) wrapper.DataLayer { return c }
+func (c *memoryClient) Collection(string) wrapper.CollectionLayer {
+	return &memoryCollection{client: c}
+}
+func (c *memoryClient) Close() error { return nil }
+
+type memoryCollection struct {
+	client *memoryClient
+}
+
+func (m memoryCollection) InsertOne(ctx context.Context, document interface{}, opts ...option.InsertOneOptioner) (wrapper.InsertOneResultLayer, error) {
+	m.client.mu.Lock()
+	defer m.client.mu.Unlock()
+
+	doc := copyDocument(document.(map[string]interface{}))
+	doc["_id"] = objectid.New()
+	m.client.documents = append(m.client.documents, doc)
+	return nil, nil
+}
+
+// FindOne returns the oldest matching document, like GetNextMessage asks for.
+func (m memoryCollection) FindOne(ctx context.Context, filter interface{}, opts ...option.FindOneOptioner) wrapper.DocumentResultLayer {
+	m.client.mu.Lock()
+	defer m.client.mu.Unlock()
+
+	found := m.client.find(filter, "created")
+	if len(found) == 0 {
+		return memoryResult{}
+	}
+	return memoryResult{copyDocument(found[0])}
+}
+
+// FindOneAndUpdate returns the document as it was before the update, like the driver does by default.
+func (m memoryCollection) FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, opts ...option.FindOneAndUpdateOptioner) wrapper.DocumentResultLayer {
+	m.client.mu.Lock()
+	defer m.client.mu.Unlock()
+
+	found := m.client.find(filter, "created")
+	if len(found) == 0 {
+		return memoryResult{}
+	}
+	before := copyDocument(found[0])
+	applyUpdate(found[0], update)
+	return memoryResult{before}
+}
+
+func (m memoryCollection) FindOneAndDelete(ctx context.Context, filter interface{}, opts ...option.FindOneAndDeleteOptioner) wrapper.DocumentResultLayer {
+	m.client.mu.Lock()
+	defer m.client.mu.Unlock()
+
+	found := m.client.find(filter, "created")
+	if len(found) == 0 {
+		return memoryResult{}
+	}
+	m.client.remove(found[:1])
+	return memoryResult{found[0]}
+}
+
+// Find returns the matching documents by lock, like DeadMessages asks for.
+func (m memoryCollection) Find(ctx context.Context, filter interface{}, opts ...option.FindOptioner) (wrapper.CursorLayer, error) {
+	m.client.mu.Lock()
+	defer m.client.mu.Unlock()
+
+	cursor := &memoryCursor{}
+	for _, doc := range m.client.find(filter, "lock") {
+		cursor.documents = append(cursor.documents, copyDocument(doc))
+	}
+	return cursor, nil
+}
+
+func (m memoryCollection) UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...option.UpdateOptioner) (*mongo.UpdateResult, error) {
+	m.client.mu.Lock()
+	defer m.client.mu.Unlock()
+
+	found := m.client.find(filter, "created")
+	for _, doc := range found {
+		applyUpdate(doc, update)
+	}
+	return &mongo.UpdateResult{MatchedCount: int64(len(found)), ModifiedCount: int64(len(found))}, nil
+}
+
+func (m memoryCollection) DeleteMany(ctx context.Context, filter interface{}, opts ...option.DeleteOptioner) (*mongo.DeleteResult, error) {
+	m.client.mu.Lock()
+	defer m.client.mu.Unlock()
+
+	found := m.client.find(filter, "created")
+	m.client.remove(found)
+	return &mongo.DeleteResult{DeletedCount: int64(len(found))}, nil
+}
+
+// find returns the documents matching the filter, ordered by the given field and then by insertion.
+// Filters compare fields for equality, or with {"$lt": int64}.
+func (c *memoryClient) find(filter interface{}, orderBy string) []map[string]interface{} {
+	var found []map[string]interface{}
+	for _, doc := range c.documents {
+		if matches(doc, filter.(map[string]interface{})) {
+			found = append(found, doc)
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		if a, b := found[i][orderBy].(int64), found[j][orderBy].(int64); a != b {
+			return a < b
+		}
+		return found[i]["created"].(int64) < found[j]["created"].(int64)
+	})
+
+	return found
+}
+
+// remove deletes the given documents from the collection.
+func (c *memoryClient) remove(documents []map[string]interface{}) {
+	var kept []map[string]interface{}
+	for _, doc := range c.documents {
+		removed := false
+		for _, r := range documents {
+			if doc["_id"] == r["_id"] {
+				removed = true
+			}
+		}
+		if !removed {
+			kept = append(kept, doc)
+		}
+	}
+	c.documents = kept
+}
+
+func matches(doc, filter map[string]interface{}) bool {
+	for key, want := range filter {
+		if cond, ok := want.(map[string]interface{}); ok {
+			got, isInt := doc[key].(int64)
+			if !isInt || got >= cond["$lt"].(int64) {
+				return false
+			}
+			continue
+		}
+		if doc[key] != want {
+			return false
+		}
+	}
+	return true
+}
+
+// applyUpdate applies a {"$set": {...}} update to a document.
+func applyUpdate(doc map[string]interface{}, update interface{}) {
+	for key, value := range update.(map[string]interface{})["$set"].(map[string]interface{}) {
+		doc[key] = value
+	}
+}
+
+func copyDocument(doc map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(doc))
+	for key, value := range doc {
+		copied[key] = value
+	}
+	return copied
+}
+
+// memoryResult decodes a document like WrapperDocumentResult, or reports that there is none.
+type memoryResult struct {
+	document map[string]interface{}
+}
+
+func (r memoryResult) Decode() (*bson.Document, error) {
+	if r.document == nil {
+		return bson.NewDocument(), mongo.ErrNoDocuments
+	}
+
+	fields := copyDocument(r.document)
+	id := fields["_id"].(objectid.ObjectID)
+	delete(fields, "_id")
+
+	fieldsJSON, _ := json.Marshal(fields)
+	doc, err := bson.ParseExtJSONObject(string(fieldsJSON))
+	if err != nil {
+		return nil, err
+	}
+	doc.Append(bson.EC.ObjectID("_id", id))
+	return doc, nil
+}
+
+type memoryCursor struct {
+	documents []map[string]interface{}
+	current   map[string]interface{}
+}
+
+func (c *memoryCursor) Next(ctx context.Context) bool {
+	if len(c.documents) == 0 {
+		return false
+	}
+	c.current, c.documents = c.documents[0], c.documents[1:]
+	return true
+}
+
+func (c *memoryCursor) Decode() (*bson.Document, error) {
+	return memoryResult{c.current}.Decode()
+}
+
+func (c *memoryCursor) Err() error                      { return nil }
+func (c *memoryCursor) Close(ctx context.Context) error { return nil }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/mongodb/mongo-go-driver/bson"
@@ -21,6 +22,9 @@ const (
 	LockDuration  time.Duration = time.Minute * 10
 )
 
+// Using a variable so that we can mock it in tests.
+var now = time.Now
+
 // Provider implements the Provider interface.
 type Provider struct {
 	ctx        context.Context
@@ -44,7 +48,7 @@ func (m Provider) GetNextMessage() (*message.Message, error) {
 	filter := map[string]interface{}{
 		"retry_available": true,
 		"lock": map[string]interface{}{
-			"$lt": time.Now().UnixNano(),
+			"$lt": now().UnixNano(),
 		},
 	}
 
@@ -73,12 +77,19 @@ func (m Provider) GetNextMessage() (*message.Message, error) {
 	}
 
 	// Update data.
+	set := map[string]interface{}{
+		"retries":         int64(retries),
+		"retry_available": retryAvailable,
+		"lock":            int64(now().Add(LockDuration).UnixNano()),
+	}
+
+	// The message is dead if this attempt fails too.
+	if !retryAvailable {
+		set["status"] = message.StatusDead
+	}
+
 	updateData := map[string]interface{}{
-		"$set": map[string]interface{}{
-			"retries":         int64(retries),
-			"retry_available": retryAvailable,
-			"lock":            int64(time.Now().Add(LockDuration).UnixNano()),
-		},
+		"$set": set,
 	}
 
 	// Update item and get new reference.
@@ -104,6 +115,100 @@ func (m Provider) DeleteMessage(ref *string) error {
 	return nil
 }
 
+// FailMessage records the reason on a received message.
+func (m Provider) FailMessage(ref *string, reason string) error {
+	itemID, err := parseRef(ref)
+	if err != nil {
+		return err
+	}
+
+	collection := m.client.Database(m.database).Collection(m.collection)
+
+	filter := map[string]interface{}{
+		"_id": itemID,
+	}
+	updateData := map[string]interface{}{
+		"$set": map[string]interface{}{
+			"last_error": reason,
+		},
+	}
+
+	// A missing message has nothing to record.
+	_, err = collection.FindOneAndUpdate(m.ctx, filter, updateData).Decode()
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+
+	return nil
+}
+
+// DeadMessages returns the dead messages, the oldest failure first.
+func (m Provider) DeadMessages() ([]*message.QueueMessage, error) {
+	collection := m.client.Database(m.database).Collection(m.collection)
+
+	sort, _ := mongo.Opt.Sort(bson.NewDocument(bson.EC.Int32("lock", 1), bson.EC.Int32("created", 1)))
+
+	cursor, err := collection.Find(m.ctx, deadFilter(), sort)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(m.ctx)
+
+	var dead []*message.QueueMessage
+	for cursor.Next(m.ctx) {
+		qm, err := ResultToQueueMessage(cursor)
+		if err != nil {
+			return nil, err
+		}
+		dead = append(dead, qm)
+	}
+
+	return dead, cursor.Err()
+}
+
+// RequeueMessage makes a dead message available again with all its retries. The last error is kept.
+func (m Provider) RequeueMessage(ref *string) error {
+	itemID, err := parseRef(ref)
+	if err != nil {
+		return err
+	}
+
+	collection := m.client.Database(m.database).Collection(m.collection)
+
+	filter := deadFilter()
+	filter["_id"] = itemID
+
+	if _, err := ResultToQueueMessage(collection.FindOneAndUpdate(m.ctx, filter, requeueData())); err != nil {
+		return message.ErrNotDead
+	}
+
+	return nil
+}
+
+// RequeueDeadMessages requeues all dead messages.
+func (m Provider) RequeueDeadMessages() (int, error) {
+	collection := m.client.Database(m.database).Collection(m.collection)
+
+	result, err := collection.UpdateMany(m.ctx, deadFilter(), requeueData())
+	if err != nil {
+		return 0, err
+	}
+
+	return int(result.ModifiedCount), nil
+}
+
+// PurgeDeadMessages deletes all dead messages.
+func (m Provider) PurgeDeadMessages() (int, error) {
+	collection := m.client.Database(m.database).Collection(m.collection)
+
+	result, err := collection.DeleteMany(m.ctx, deadFilter())
+	if err != nil {
+		return 0, err
+	}
+
+	return int(result.DeletedCount), nil
+}
+
 // Close the MongoDB client.
 func (m Provider) Close() error {
 	return m.client.Close()
@@ -118,15 +223,50 @@ func generateMessage(in *message.Message) map[string]interface{} {
 
 	// Return the QueueMessage as an interface map.
 	return map[string]interface{}{
-		"created":         time.Now().UnixNano(),
+		"created":         now().UnixNano(),
 		"lock":            int64(0),
 		"retries":         int64(RetryAttempts),
 		"message":         msgMap,
-		"status":          "pending",
+		"status":          message.StatusPending,
 		"retry_available": true,
 	}
 }
 
+// deadFilter matches the messages that are out of retries and no longer locked.
+func deadFilter() map[string]interface{} {
+	return map[string]interface{}{
+		"retry_available": false,
+		"lock": map[string]interface{}{
+			"$lt": now().UnixNano(),
+		},
+	}
+}
+
+// requeueData gives a dead message all its retries back.
+func requeueData() map[string]interface{} {
+	return map[string]interface{}{
+		"$set": map[string]interface{}{
+			"lock":            int64(0),
+			"retries":         int64(RetryAttempts),
+			"retry_available": true,
+			"status":          message.StatusPending,
+		},
+	}
+}
+
+// parseRef returns the document id of a message reference.
+func parseRef(ref *string) (objectid.ObjectID, error) {
+	if ref == nil {
+		return objectid.ObjectID{}, errors.New("mongodb: no message reference")
+	}
+
+	itemID, err := objectid.FromHex(*ref)
+	if err != nil {
+		return objectid.ObjectID{}, fmt.Errorf("mongodb: invalid message reference %q", *ref)
+	}
+	return itemID, nil
+}
+
 // ResultToQueueMessage converts a MongoDB result to a QueueMessage.
 func ResultToQueueMessage(layer wrapper.DocumentResultLayer) (*message.QueueMessage, error) {
 
@@ -7,9 +7,11 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/mongodb/mongo-go-driver/mongo"
 	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/message/messagetest"
 	wrapper "github.com/wptide/pkg/wrapper/mongo"
 )
 
@@ -204,6 +206,288 @@ func TestMongoProvider_DeleteMessage(t *testing.T) {
 	}
 }
 
+func TestMongoProvider_FailMessage(t *testing.T) {
+	type fields struct {
+		ctx        context.Context
+		client     wrapper.Client
+		database   string
+		collection string
+	}
+	type args struct {
+		ref    *string
+		reason string
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		{
+			"Fail Message",
+			fields{
+				context.Background(),
+				&MockClient{},
+				"test-db",
+				"test-collection",
+			},
+			args{
+				&[]string{"abcdef123456789009876364"}[0],
+				"something went wrong",
+			},
+			false,
+		},
+		{
+			"Fail Message - No Reference",
+			fields{
+				context.Background(),
+				&MockClient{},
+				"test-db",
+				"test-collection",
+			},
+			args{
+				nil,
+				"something went wrong",
+			},
+			true,
+		},
+		{
+			"Fail Message - Invalid Reference",
+			fields{
+				context.Background(),
+				&MockClient{},
+				"test-db",
+				"test-collection",
+			},
+			args{
+				&[]string{"mock-ref"}[0],
+				"something went wrong",
+			},
+			true,
+		},
+		{
+			"Fail Message - No Document",
+			fields{
+				context.Background(),
+				&MockClient{"test-no-records"},
+				"test-db",
+				"test-collection",
+			},
+			args{
+				&[]string{"abcdef123456789009876364"}[0],
+				"something went wrong",
+			},
+			false,
+		},
+		{
+			"Fail Message - Update Error",
+			fields{
+				context.Background(),
+				&MockClient{"test-lock-fail"},
+				"test-db",
+				"test-collection",
+			},
+			args{
+				&[]string{"abcdef123456789009876364"}[0],
+				"something went wrong",
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := NewWithClient(tt.fields.ctx, tt.fields.database, tt.fields.collection, tt.fields.client)
+			if err := m.FailMessage(tt.args.ref, tt.args.reason); (err != nil) != tt.wantErr {
+				t.Errorf("Provider.FailMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMongoProvider_DeadMessages(t *testing.T) {
+	tests := []struct {
+		name       string
+		collection string
+		want       int
+		wantErr    bool
+	}{
+		{
+			"Dead Messages",
+			"test-valid-message",
+			2,
+			false,
+		},
+		{
+			"Dead Messages - None",
+			"test-no-records",
+			0,
+			false,
+		},
+		{
+			"Dead Messages - Find Fail",
+			"test-fail",
+			0,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := NewWithClient(context.Background(), "test", tt.collection, &MockClient{tt.collection})
+
+			got, err := m.DeadMessages()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Provider.DeadMessages() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if len(got) != tt.want {
+				t.Errorf("Provider.DeadMessages() = %d messages, want %d", len(got), tt.want)
+			}
+			for _, qm := range got {
+				if qm.Message.Title != "Plugin One" || *qm.Message.ExternalRef != "abcdef123456789009876364" {
+					t.Errorf("Provider.DeadMessages() message = %+v", qm.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestMongoProvider_RequeueMessage(t *testing.T) {
+	tests := []struct {
+		name       string
+		collection string
+		ref        *string
+		wantErr    error
+	}{
+		{
+			"Requeue Message",
+			"test-valid-message",
+			&[]string{"abcdef123456789009876364"}[0],
+			nil,
+		},
+		{
+			"Requeue Message - Not Dead",
+			"test-no-records",
+			&[]string{"abcdef123456789009876364"}[0],
+			message.ErrNotDead,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := NewWithClient(context.Background(), "test", tt.collection, &MockClient{tt.collection})
+			if err := m.RequeueMessage(tt.ref); err != tt.wantErr {
+				t.Errorf("Provider.RequeueMessage() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	m, _ := NewWithClient(context.Background(), "test", "test-valid-message", &MockClient{"test-valid-message"})
+	if err := m.RequeueMessage(nil); err == nil {
+		t.Errorf("Provider.RequeueMessage() error = nil, want error without a reference")
+	}
+}
+
+func TestMongoProvider_RequeueDeadMessages(t *testing.T) {
+	tests := []struct {
+		name       string
+		collection string
+		want       int
+		wantErr    bool
+	}{
+		{
+			"Requeue Dead Messages",
+			"test-collection",
+			2,
+			false,
+		},
+		{
+			"Requeue Dead Messages - Update Fail",
+			"test-fail",
+			0,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := NewWithClient(context.Background(), "test", tt.collection, &MockClient{tt.collection})
+
+			got, err := m.RequeueDeadMessages()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Provider.RequeueDeadMessages() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Provider.RequeueDeadMessages() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMongoProvider_PurgeDeadMessages(t *testing.T) {
+	tests := []struct {
+		name       string
+		collection string
+		want       int
+		wantErr    bool
+	}{
+		{
+			"Purge Dead Messages",
+			"test-collection",
+			2,
+			false,
+		},
+		{
+			"Purge Dead Messages - Delete Fail",
+			"test-fail",
+			0,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := NewWithClient(context.Background(), "test", tt.collection, &MockClient{tt.collection})
+
+			got, err := m.PurgeDeadMessages()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Provider.PurgeDeadMessages() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Provider.PurgeDeadMessages() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMongoProvider_conformance(t *testing.T) {
+	clock := time.Unix(1500000000, 0)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	newProvider := func() message.Provider {
+		p, _ := NewWithClient(context.Background(), "test-db", "test-collection", newMemoryClient())
+		return p
+	}
+
+	messagetest.TestProvider(t, newProvider, messagetest.Options{
+		ExpireLocks:   func() { clock = clock.Add(LockDuration + time.Second) },
+		RetryAttempts: RetryAttempts,
+	})
+}
+
+func TestMongoProvider_deadLetters(t *testing.T) {
+	clock := time.Unix(1500000000, 0)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	newProvider := func() message.DeadLetterProvider {
+		p, _ := NewWithClient(context.Background(), "test-db", "test-collection", newMemoryClient())
+		return p
+	}
+
+	messagetest.TestDeadLetters(t, newProvider, messagetest.Options{
+		ExpireLocks:   func() { clock = clock.Add(LockDuration + time.Second) },
+		RetryAttempts: RetryAttempts,
+	})
+}
+
 func TestNew(t *testing.T) {
 	_, host := testServer(t, nil)
 
@@ -0,0 +1,239 @@
+package postgres
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The fake driver runs the statements of the provider on rows kept in memory,
+// so that the provider can be tested without a database server.
+var fake = &fakeDriver{databases: make(map[string]*fakeDatabase)}
+
+func init() {
+	sql.Register("fakepostgres", fake)
+}
+
+type fakeRow struct {
+	id             int64
+	created        int64
+	lock           int64
+	retries        int64
+	status         string
+	retryAvailable bool
+	lastError      string
+	message        string
+}
+
+type fakeDatabase struct {
+	mu      sync.Mutex
+	rows    []*fakeRow
+	nextID  int64
+	queries []string
+	fail    bool
+}
+
+type fakeDriver struct {
+	mu        sync.Mutex
+	databases map[string]*fakeDatabase
+}
+
+// database returns the database for a data source name, creating it if needed.
+func (d *fakeDriver) database(name string) *fakeDatabase {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.databases[name] == nil {
+		d.databases[name] = &fakeDatabase{}
+	}
+	return d.databases[name]
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{db: d.database(name)}, nil
+}
+
+type fakeConn struct {
+	db *fakeDatabase
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{db: c.db, query: query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type fakeStmt struct {
+	db    *fakeDatabase
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	db := s.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.fail {
+		return nil, errors.New("something went wrong")
+	}
+	db.queries = append(db.queries, s.query)
+
+	switch {
+	case strings.HasPrefix(s.query, "INSERT"):
+		db.nextID++
+		db.rows = append(db.rows, &fakeRow{
+			id:             db.nextID,
+			created:        args[0].(int64),
+			lock:           args[1].(int64),
+			retries:        args[2].(int64),
+			status:         args[3].(string),
+			retryAvailable: args[4].(bool),
+			message:        args[5].(string),
+		})
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(s.query, "DELETE") && strings.Contains(s.query, "retry_available"):
+		var kept []*fakeRow
+		for _, row := range db.rows {
+			if !db.dead(row, args[0].(int64)) {
+				kept = append(kept, row)
+			}
+		}
+		purged := len(db.rows) - len(kept)
+		db.rows = kept
+		return driver.RowsAffected(int64(purged)), nil
+	case strings.HasPrefix(s.query, "DELETE"):
+		for i, row := range db.rows {
+			if row.id == args[0].(int64) {
+				db.rows = append(db.rows[:i], db.rows[i+1:]...)
+				return driver.RowsAffected(1), nil
+			}
+		}
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(s.query, "UPDATE") && strings.Contains(s.query, "SET last_error"):
+		for _, row := range db.rows {
+			if row.id == args[1].(int64) {
+				row.lastError = args[0].(string)
+				return driver.RowsAffected(1), nil
+			}
+		}
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(s.query, "UPDATE") && strings.Contains(s.query, "retry_available = TRUE"):
+		var requeued int64
+		for _, row := range db.rows {
+			if !db.dead(row, args[2].(int64)) || (len(args) > 3 && row.id != args[3].(int64)) {
+				continue
+			}
+			row.lock = 0
+			row.retries = args[0].(int64)
+			row.retryAvailable = true
+			row.status = args[1].(string)
+			requeued++
+		}
+		return driver.RowsAffected(requeued), nil
+	case strings.HasPrefix(s.query, "CREATE"), strings.HasPrefix(s.query, "ALTER"):
+		return driver.RowsAffected(0), nil
+	}
+
+	return nil, errors.New("unexpected statement: " + s.query)
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	db := s.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.fail {
+		return nil, errors.New("something went wrong")
+	}
+	db.queries = append(db.queries, s.query)
+
+	switch {
+	case strings.HasPrefix(s.query, "SELECT"):
+		var dead []*fakeRow
+		for _, row := range db.rows {
+			if db.dead(row, args[0].(int64)) {
+				dead = append(dead, row)
+			}
+		}
+		sortRows(dead)
+
+		rows := &fakeRows{columns: []string{"id", "created", "lock", "retries", "status", "last_error", "message"}}
+		for _, row := range dead {
+			rows.values = append(rows.values, []driver.Value{
+				row.id, row.created, row.lock, row.retries, row.status, row.lastError, []byte(row.message),
+			})
+		}
+		return rows, nil
+	case !strings.HasPrefix(s.query, "UPDATE"):
+		return nil, errors.New("unexpected query: " + s.query)
+	}
+
+	lock, t := args[0].(int64), args[1].(int64)
+
+	var available []*fakeRow
+	for _, row := range db.rows {
+		if row.retryAvailable && row.lock < t {
+			available = append(available, row)
+		}
+	}
+	sortRows(available)
+
+	rows := &fakeRows{columns: []string{"id", "message"}}
+	if len(available) > 0 {
+		row := available[0]
+		if row.retries <= 1 {
+			row.status = args[2].(string)
+		}
+		row.retryAvailable = row.retries > 1
+		row.retries--
+		row.lock = lock
+		rows.values = [][]driver.Value{{row.id, []byte(row.message)}}
+	}
+	return rows, nil
+}
+
+// dead reports whether a row is out of retries and no longer locked at t.
+func (db *fakeDatabase) dead(row *fakeRow, t int64) bool {
+	return !row.retryAvailable && row.lock < t
+}
+
+// sortRows orders rows by lock, created and id, like the statements of the provider.
+func sortRows(rows []*fakeRow) {
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.lock != b.lock {
+			return a.lock < b.lock
+		}
+		if a.created != b.created {
+			return a.created < b.created
+		}
+		return a.id < b.id
+	})
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
@@ -0,0 +1,315 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/message/messagetest"
+)
+
+// postgresURLEnv is the environment variable with the database used by the integration test,
+// e.g. TIDE_TEST_POSTGRES_URL="postgres://localhost/tide_test?sslmode=disable".
+const postgresURLEnv = "TIDE_TEST_POSTGRES_URL"
+
+// mockNow sets the clock of the provider and returns a function moving it past the lock duration.
+func mockNow() (expireLocks func(), reset func()) {
+	oldNow := now
+	current := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	var mu sync.Mutex
+
+	now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		// Messages sent one after another are created at different times.
+		current = current.Add(time.Millisecond)
+		return current
+	}
+
+	expireLocks = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		current = current.Add(LockDuration)
+	}
+
+	return expireLocks, func() { now = oldNow }
+}
+
+func newFakeProvider(t *testing.T, name string) *Provider {
+	db, err := sql.Open("fakepostgres", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewWithDB(context.Background(), db, "queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestProvider_conformance(t *testing.T) {
+	expireLocks, reset := mockNow()
+	defer reset()
+
+	databases := 0
+	messagetest.TestProvider(t, func() message.Provider {
+		databases++
+		return newFakeProvider(t, fmt.Sprintf("conformance-%d", databases))
+	}, messagetest.Options{
+		ExpireLocks:   expireLocks,
+		RetryAttempts: RetryAttempts,
+	})
+}
+
+func TestProvider_deadLetters(t *testing.T) {
+	expireLocks, reset := mockNow()
+	defer reset()
+
+	databases := 0
+	messagetest.TestDeadLetters(t, func() message.DeadLetterProvider {
+		databases++
+		return newFakeProvider(t, fmt.Sprintf("dead-letters-%d", databases))
+	}, messagetest.Options{
+		ExpireLocks:   expireLocks,
+		RetryAttempts: RetryAttempts,
+	})
+}
+
+func TestProvider_integration(t *testing.T) {
+	dataSourceName := os.Getenv(postgresURLEnv)
+	if dataSourceName == "" {
+		t.Skipf("%s is not set", postgresURLEnv)
+	}
+
+	expireLocks, reset := mockNow()
+	defer reset()
+
+	ctx := context.Background()
+	tables := 0
+
+	newProvider := func() *Provider {
+		tables++
+		table := fmt.Sprintf("tide_test_queue_%d", tables)
+
+		p, err := New(ctx, dataSourceName, table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.db.ExecContext(ctx, "DROP TABLE IF EXISTS "+table); err != nil {
+			t.Fatal(err)
+		}
+		if err := Migrate(ctx, p.db, table); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	opts := messagetest.Options{
+		ExpireLocks:   expireLocks,
+		RetryAttempts: RetryAttempts,
+	}
+	messagetest.TestProvider(t, func() message.Provider { return newProvider() }, opts)
+	messagetest.TestDeadLetters(t, func() message.DeadLetterProvider { return newProvider() }, opts)
+
+	t.Run("Concurrent Workers", func(t *testing.T) {
+		p := newProvider()
+		defer p.Close()
+
+		const count = 20
+		for i := 0; i < count; i++ {
+			p.SendMessage(&message.Message{Title: fmt.Sprintf("Message %d", i)})
+		}
+
+		var (
+			mu       sync.Mutex
+			received = make(map[string]int)
+			wg       sync.WaitGroup
+		)
+		for worker := 0; worker < 4; worker++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					msg, err := p.GetNextMessage()
+					if err != nil {
+						t.Errorf("GetNextMessage() error = %v", err)
+						return
+					}
+					if msg == nil {
+						return
+					}
+					mu.Lock()
+					received[msg.Title]++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if len(received) != count {
+			t.Errorf("received %d messages, want %d", len(received), count)
+		}
+		for title, n := range received {
+			if n != 1 {
+				t.Errorf("received %q %d times, want once", title, n)
+			}
+		}
+	})
+}
+
+func TestProvider_GetNextMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		fail    bool
+		want    string
+		wantErr bool
+	}{
+		{"Message", `{"title":"Test Plugin"}`, false, "Test Plugin", false},
+		{"Invalid Message", `{"title":`, false, "", true},
+		{"Null Message", `null`, false, "", true},
+		{"Database Error", `{"title":"Test Plugin"}`, true, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newFakeProvider(t, "get-"+tt.name)
+			db := fake.database("get-" + tt.name)
+			db.rows = []*fakeRow{{id: 42, retries: RetryAttempts, retryAvailable: true, message: tt.message}}
+			db.fail = tt.fail
+
+			got, err := p.GetNextMessage()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Provider.GetNextMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Title != tt.want || got.ExternalRef == nil || *got.ExternalRef != "42" {
+				t.Errorf("Provider.GetNextMessage() = %+v, want %q with reference 42", got, tt.want)
+			}
+			if row := db.rows[0]; row.retries != RetryAttempts-1 || !row.retryAvailable || row.lock == 0 {
+				t.Errorf("Provider.GetNextMessage() row = %+v, want it locked with one retry less", row)
+			}
+		})
+	}
+}
+
+func TestProvider_DeleteMessage(t *testing.T) {
+	ref := func(s string) *string { return &s }
+
+	tests := []struct {
+		name    string
+		ref     *string
+		fail    bool
+		wantErr bool
+	}{
+		{"Delete", ref("1"), false, false},
+		{"Missing Message", ref("2"), false, false},
+		{"No Reference", nil, false, true},
+		{"Invalid Reference", ref("5b0d1b0e"), false, true},
+		{"Database Error", ref("1"), true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newFakeProvider(t, "delete-"+tt.name)
+			p.SendMessage(&message.Message{Title: "Test Plugin"})
+			fake.database("delete-" + tt.name).fail = tt.fail
+
+			if err := p.DeleteMessage(tt.ref); (err != nil) != tt.wantErr {
+				t.Errorf("Provider.DeleteMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProvider_SendMessage(t *testing.T) {
+	p := newFakeProvider(t, "send")
+	ref := "7"
+
+	if err := p.SendMessage(&message.Message{Title: "Test Plugin", ExternalRef: &ref}); err != nil {
+		t.Fatalf("Provider.SendMessage() error = %v", err)
+	}
+
+	db := fake.database("send")
+	if len(db.rows) != 1 {
+		t.Fatalf("Provider.SendMessage() inserted %d rows, want 1", len(db.rows))
+	}
+	row := db.rows[0]
+	if row.retries != RetryAttempts || !row.retryAvailable || row.status != "pending" || row.lock != 0 || row.created == 0 {
+		t.Errorf("Provider.SendMessage() row = %+v, want a pending message", row)
+	}
+	if strings.Contains(row.message, "external_ref") {
+		t.Errorf("Provider.SendMessage() message = %s, want no external reference", row.message)
+	}
+
+	db.fail = true
+	if err := p.SendMessage(&message.Message{Title: "Test Plugin"}); err == nil {
+		t.Errorf("Provider.SendMessage() error = nil, want error")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name      string
+		table     string
+		wantIndex string
+		wantErr   bool
+	}{
+		{"Table", "queue", "queue_available_idx", false},
+		{"Schema", "tide.queue", "queue_available_idx", false},
+		{"Invalid Table", "queue; DROP TABLE queue", "", true},
+		{"Quoted Table", `"Queue"`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := sql.Open("fakepostgres", "migrate-"+tt.name)
+
+			err := Migrate(context.Background(), db, tt.table)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Migrate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			queries := fake.database("migrate-" + tt.name).queries
+			if tt.wantErr {
+				if len(queries) != 0 {
+					t.Errorf("Migrate() ran %v, want no statements", queries)
+				}
+				return
+			}
+			if len(queries) != 3 ||
+				!strings.HasPrefix(queries[0], "CREATE TABLE IF NOT EXISTS "+tt.table+" (") ||
+				!strings.HasPrefix(queries[1], "ALTER TABLE "+tt.table+" ADD COLUMN IF NOT EXISTS last_error ") ||
+				!strings.HasPrefix(queries[2], "CREATE INDEX IF NOT EXISTS "+tt.wantIndex+" ON "+tt.table+" ") {
+				t.Errorf("Migrate() ran %q", queries)
+			}
+		})
+	}
+}
+
+func TestNewWithDB(t *testing.T) {
+	db, _ := sql.Open("fakepostgres", "new")
+
+	tests := []struct {
+		name    string
+		db      *sql.DB
+		table   string
+		wantErr bool
+	}{
+		{"Provider", db, "queue", false},
+		{"No Database", nil, "queue", true},
+		{"Invalid Table", db, "queue--", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewWithDB(context.Background(), tt.db, tt.table); (err != nil) != tt.wantErr {
+				t.Errorf("NewWithDB() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+)
+
+// fakeClient runs the stream commands of the provider on a single stream and group kept in memory,
+// so that the provider can be tested without a Redis server. Idle times use the clock of the fake.
+type fakeClient struct {
+	mu       sync.Mutex
+	now      time.Time
+	group    bool
+	nextID   int
+	entries  map[string][]interface{} // Fields by ID. Deleted entries are removed.
+	last     int                      // Last ID delivered to the group.
+	pending  map[string]*fakePending
+	commands []string
+	fail     string // Command failing with an error.
+	closed   bool
+}
+
+type fakePending struct {
+	delivered time.Time
+	count     int64
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		now:     time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC),
+		entries: make(map[string][]interface{}),
+		pending: make(map[string]*fakePending),
+	}
+}
+
+// expireLocks moves the clock past the lock duration.
+func (c *fakeClient) expireLocks() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(LockDuration)
+}
+
+func (c *fakeClient) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *fakeClient) Do(args ...interface{}) *goredis.Cmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	name := strings.ToUpper(fmt.Sprint(args[0]))
+	c.commands = append(c.commands, name)
+	if c.fail == name {
+		return goredis.NewCmdResult(nil, errors.New("something went wrong"))
+	}
+
+	switch name {
+	case "XGROUP":
+		if c.group {
+			return goredis.NewCmdResult(nil, errors.New("BUSYGROUP Consumer Group name already exists"))
+		}
+		c.group = true
+		return goredis.NewCmdResult("OK", nil)
+
+	case "XADD":
+		c.nextID++
+		id := fmt.Sprintf("%d-0", c.nextID)
+		c.entries[id] = args[3:]
+		return goredis.NewCmdResult(id, nil)
+
+	case "XREADGROUP":
+		for seq := c.last + 1; seq <= c.nextID; seq++ {
+			id := fmt.Sprintf("%d-0", seq)
+			if fields, ok := c.entries[id]; ok {
+				c.last = seq
+				c.pending[id] = &fakePending{delivered: c.now, count: 1}
+				stream := []interface{}{args[7], []interface{}{[]interface{}{id, fields}}}
+				return goredis.NewCmdResult([]interface{}{stream}, nil)
+			}
+		}
+		return goredis.NewCmdResult(nil, goredis.Nil)
+
+	case "XAUTOCLAIM":
+		minIdle := time.Duration(args[4].(int64)) * time.Millisecond
+		start := seq(args[5].(string))
+		count, _ := strconv.Atoi(fmt.Sprint(args[7]))
+
+		// Like Redis, at most ten times COUNT pending entries are scanned, and the cursor is the next one.
+		claimed, deleted := []interface{}{}, []interface{}{}
+		scanned, cursor := 0, "0-0"
+		for _, id := range c.pendingIDs() {
+			if seq(id) < start {
+				continue
+			}
+			if len(claimed) == count || scanned == count*10 {
+				cursor = id
+				break
+			}
+			scanned++
+
+			p := c.pending[id]
+			if c.now.Sub(p.delivered) < minIdle {
+				continue
+			}
+			// Like Redis 7, deleted entries are removed from the pending entries.
+			fields, ok := c.entries[id]
+			if !ok {
+				delete(c.pending, id)
+				deleted = append(deleted, id)
+				continue
+			}
+			p.delivered = c.now
+			p.count++
+			claimed = append(claimed, []interface{}{id, fields})
+		}
+		return goredis.NewCmdResult([]interface{}{cursor, claimed, deleted}, nil)
+
+	case "XPENDING":
+		id := args[3].(string)
+		p, ok := c.pending[id]
+		if !ok {
+			return goredis.NewCmdResult([]interface{}{}, nil)
+		}
+		idle := int64(c.now.Sub(p.delivered) / time.Millisecond)
+		return goredis.NewCmdResult([]interface{}{[]interface{}{id, "consumer", idle, p.count}}, nil)
+
+	case "XACK":
+		var n int64
+		for _, id := range args[3:] {
+			if _, ok := c.pending[id.(string)]; ok {
+				delete(c.pending, id.(string))
+				n++
+			}
+		}
+		return goredis.NewCmdResult(n, nil)
+
+	case "XDEL":
+		var n int64
+		for _, id := range args[2:] {
+			if _, ok := c.entries[id.(string)]; ok {
+				delete(c.entries, id.(string))
+				n++
+			}
+		}
+		return goredis.NewCmdResult(n, nil)
+	}
+
+	return goredis.NewCmdResult(nil, fmt.Errorf("ERR unknown command '%s'", name))
+}
+
+// pendingIDs returns the IDs of the pending entries, oldest first.
+func (c *fakeClient) pendingIDs() []string {
+	var ids []string
+	for id := range c.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return seq(ids[i]) < seq(ids[j])
+	})
+	return ids
+}
+
+// seq returns the sequence of an ID of the fake.
+func seq(id string) int {
+	n, _ := strconv.Atoi(strings.TrimSuffix(id, "-0"))
+	return n
+}
@@ -0,0 +1,290 @@
+// Package redis is a message provider keeping the queue in a Redis stream read by a consumer group.
+//
+// A received entry stays pending in the group until it is deleted, which acknowledges it. Entries
+// pending for longer than LockDuration are claimed again with XAUTOCLAIM (Redis 6.2 or later)
+// until they were delivered RetryAttempts times. Entries out of retries are acknowledged without
+// being deleted from the stream, like the Firestore and Mongo providers keep their documents.
+//
+// The provider does not implement message.DeadLetterProvider. Stream entries can't be changed, so
+// there is no place to record why a message failed, and telling entries out of retries apart needs
+// the last delivered ID of the group. They are the entries up to that ID (XINFO GROUPS) that are
+// not pending (XPENDING), and can be read with XRANGE and added again with XADD.
+package redis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+	"github.com/wptide/pkg/message"
+)
+
+const (
+	// RetryAttempts sets the amount of default retries.
+	RetryAttempts = 3
+
+	// LockDuration sets how long an item needs to be locked for.
+	LockDuration time.Duration = time.Minute * 10
+
+	// messageField is the field of the stream entries holding the message.
+	messageField = "message"
+)
+
+// Using a variable so that we can mock it in tests.
+var hostname = os.Hostname
+
+// Client is the part of the go-redis client used by the provider.
+// The stream commands are sent with Do, as go-redis has no methods for all of them.
+type Client interface {
+	Do(args ...interface{}) *goredis.Cmd
+	Close() error
+}
+
+// Provider implements the Provider interface.
+type Provider struct {
+	client        Client
+	stream        string
+	group         string
+	consumer      string
+	lockDuration  time.Duration
+	retryAttempts int64
+}
+
+// entry is a stream entry. The data is empty if the entry was deleted while it was pending.
+type entry struct {
+	id   string
+	data string
+}
+
+// SendMessage adds the message to the stream.
+func (p Provider) SendMessage(msg *message.Message) error {
+	// The reference of a received message is not part of the queued message.
+	queued := *msg
+	queued.ExternalRef = nil
+
+	data, err := json.Marshal(&queued)
+	if err != nil {
+		return err
+	}
+
+	return p.client.Do("XADD", p.stream, "*", messageField, string(data)).Err()
+}
+
+// GetNextMessage returns the next available message, or nil if there is none.
+// Messages whose lock expired come before new messages.
+func (p Provider) GetNextMessage() (*message.Message, error) {
+	for {
+		e, err := p.reclaim()
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			break
+		}
+
+		deliveries, err := p.deliveries(e.id)
+		if err != nil {
+			return nil, err
+		}
+		if e.data != "" && deliveries <= p.retryAttempts {
+			return decode(e)
+		}
+
+		// Out of retries or deleted from the stream, so stop delivering it.
+		if err := p.client.Do("XACK", p.stream, p.group, e.id).Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := p.client.Do("XREADGROUP", "GROUP", p.group, p.consumer, "COUNT", 1, "STREAMS", p.stream, ">").Result()
+	if err == goredis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The reply has the entries of each stream read.
+	streams, ok := reply.([]interface{})
+	if !ok || len(streams) == 0 {
+		return nil, nil
+	}
+	stream, ok := streams[0].([]interface{})
+	if !ok || len(stream) != 2 {
+		return nil, errors.New("redis: unexpected XREADGROUP reply")
+	}
+	entries, err := parseEntries(stream[1])
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+
+	return decode(&entries[0])
+}
+
+// reclaim claims the oldest entry pending for longer than the lock duration, or returns nil if there is none.
+func (p Provider) reclaim() (*entry, error) {
+	minIdle := int64(p.lockDuration / time.Millisecond)
+
+	// XAUTOCLAIM scans a limited number of pending entries, so follow its cursor until an entry
+	// is claimed or all of them were scanned.
+	cursor := "0-0"
+	for {
+		reply, err := p.client.Do("XAUTOCLAIM", p.stream, p.group, p.consumer, minIdle, cursor, "COUNT", 1).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		// The reply is the cursor, the claimed entries and, since Redis 7, the IDs of deleted entries.
+		values, ok := reply.([]interface{})
+		if !ok || len(values) < 2 {
+			return nil, errors.New("redis: unexpected XAUTOCLAIM reply")
+		}
+		entries, err := parseEntries(values[1])
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) > 0 {
+			return &entries[0], nil
+		}
+
+		if len(values) > 2 {
+			if deleted, ok := values[2].([]interface{}); ok && len(deleted) > 0 {
+				if id, ok := deleted[0].(string); ok {
+					return &entry{id: id}, nil
+				}
+			}
+		}
+
+		cursor, ok = values[0].(string)
+		if !ok {
+			return nil, errors.New("redis: unexpected XAUTOCLAIM cursor")
+		}
+		if cursor == "0-0" {
+			return nil, nil
+		}
+	}
+}
+
+// deliveries returns how many times a pending entry was delivered.
+func (p Provider) deliveries(id string) (int64, error) {
+	reply, err := p.client.Do("XPENDING", p.stream, p.group, id, id, 1).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// Each pending entry is its ID, consumer, idle time and delivery count.
+	values, ok := reply.([]interface{})
+	if !ok || len(values) == 0 {
+		// Deleted in the meantime.
+		return 0, nil
+	}
+	pending, ok := values[0].([]interface{})
+	if !ok || len(pending) != 4 {
+		return 0, errors.New("redis: unexpected XPENDING reply")
+	}
+	count, ok := pending[3].(int64)
+	if !ok {
+		return 0, errors.New("redis: unexpected XPENDING reply")
+	}
+
+	return count, nil
+}
+
+// DeleteMessage acknowledges the entry and deletes it from the stream.
+func (p Provider) DeleteMessage(ref *string) error {
+	if ref == nil {
+		return errors.New("redis: no message reference")
+	}
+
+	if err := p.client.Do("XACK", p.stream, p.group, *ref).Err(); err != nil {
+		return err
+	}
+	return p.client.Do("XDEL", p.stream, *ref).Err()
+}
+
+// Close closes the Redis client.
+func (p Provider) Close() error {
+	return p.client.Close()
+}
+
+// parseEntries parses a list of stream entries, each being its ID and its fields and values.
+func parseEntries(reply interface{}) ([]entry, error) {
+	values, ok := reply.([]interface{})
+	if !ok && reply != nil {
+		return nil, errors.New("redis: unexpected stream entries")
+	}
+
+	var entries []entry
+	for _, value := range values {
+		item, ok := value.([]interface{})
+		if !ok || len(item) != 2 {
+			return nil, errors.New("redis: unexpected stream entry")
+		}
+		id, ok := item[0].(string)
+		if !ok {
+			return nil, errors.New("redis: unexpected stream entry ID")
+		}
+
+		e := entry{id: id}
+		// Before Redis 7, deleted entries are claimed without fields.
+		fields, _ := item[1].([]interface{})
+		for i := 0; i+1 < len(fields); i += 2 {
+			if fields[i] == messageField {
+				e.data, _ = fields[i+1].(string)
+			}
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// decode returns the message of an entry with the entry ID as reference.
+func decode(e *entry) (*message.Message, error) {
+	var msg *message.Message
+	if err := json.Unmarshal([]byte(e.data), &msg); err != nil || msg == nil {
+		return nil, fmt.Errorf("redis: invalid message %s", e.id)
+	}
+
+	id := e.id
+	msg.ExternalRef = &id
+
+	return msg, nil
+}
+
+// New creates a new Provider with a go-redis client for the options.
+func New(opts *goredis.Options, stream string, group string) (*Provider, error) {
+	return NewWithClient(goredis.NewClient(opts), stream, group)
+}
+
+// NewWithClient creates a new Provider reading the stream with the consumer group, creating both if needed.
+// Note: Use this one for the tests with a mock Client.
+func NewWithClient(client Client, stream string, group string) (*Provider, error) {
+	if client == nil {
+		return nil, errors.New("redis: no client")
+	}
+	if stream == "" || group == "" {
+		return nil, errors.New("redis: stream and group are required")
+	}
+
+	err := client.Do("XGROUP", "CREATE", stream, group, "0", "MKSTREAM").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil, err
+	}
+
+	// Every worker process is a consumer of the group.
+	host, _ := hostname()
+
+	return &Provider{
+		client:        client,
+		stream:        stream,
+		group:         group,
+		consumer:      fmt.Sprintf("%s-%d", host, os.Getpid()),
+		lockDuration:  LockDuration,
+		retryAttempts: RetryAttempts,
+	}, nil
+}
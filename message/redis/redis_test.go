@@ -0,0 +1,317 @@
+package redis
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/message/messagetest"
+)
+
+// redisAddrEnv is the environment variable with the server used by the integration test,
+// e.g. TIDE_TEST_REDIS_ADDR="localhost:6379". The test deletes the streams it uses.
+const redisAddrEnv = "TIDE_TEST_REDIS_ADDR"
+
+func newFakeProvider(t *testing.T) (*Provider, *fakeClient) {
+	client := newFakeClient()
+	p, err := NewWithClient(client, "tide-queue", "tide-workers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, client
+}
+
+func TestProvider_conformance(t *testing.T) {
+	var client *fakeClient
+
+	messagetest.TestProvider(t, func() message.Provider {
+		var p *Provider
+		p, client = newFakeProvider(t)
+		return p
+	}, messagetest.Options{
+		ExpireLocks:   func() { client.expireLocks() },
+		RetryAttempts: RetryAttempts,
+	})
+}
+
+func TestProvider_integration(t *testing.T) {
+	addr := os.Getenv(redisAddrEnv)
+	if addr == "" {
+		t.Skipf("%s is not set", redisAddrEnv)
+	}
+
+	// Idle times are measured by the server, so the locks expire by waiting.
+	lockDuration := 100 * time.Millisecond
+	streams := 0
+
+	messagetest.TestProvider(t, func() message.Provider {
+		streams++
+		stream := fmt.Sprintf("tide-test-queue-%d", streams)
+
+		client := goredis.NewClient(&goredis.Options{Addr: addr})
+		if err := client.Del(stream).Err(); err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := NewWithClient(client, stream, "tide-test-workers")
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.lockDuration = lockDuration
+		return p
+	}, messagetest.Options{
+		ExpireLocks:   func() { time.Sleep(lockDuration + 20*time.Millisecond) },
+		RetryAttempts: RetryAttempts,
+	})
+}
+
+func TestProvider_GetNextMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		fail    string
+		want    string
+		wantErr bool
+	}{
+		{"Message", `{"title":"Test Plugin"}`, "", "Test Plugin", false},
+		{"Invalid Message", `{"title":`, "", "", true},
+		{"Null Message", `null`, "", "", true},
+		{"Claim Error", `{"title":"Test Plugin"}`, "XAUTOCLAIM", "", true},
+		{"Read Error", `{"title":"Test Plugin"}`, "XREADGROUP", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, client := newFakeProvider(t)
+			client.Do("XADD", "tide-queue", "*", messageField, tt.data)
+			client.fail = tt.fail
+
+			got, err := p.GetNextMessage()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Provider.GetNextMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Title != tt.want || got.ExternalRef == nil || *got.ExternalRef != "1-0" {
+				t.Errorf("Provider.GetNextMessage() = %+v, want %q with reference 1-0", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvider_GetNextMessage_reclaim(t *testing.T) {
+	tests := []struct {
+		name      string
+		deleted   bool
+		fail      string
+		wantTitle string
+		wantErr   bool
+	}{
+		{"Reclaim", false, "", "First", false},
+		{"Deleted Entry", true, "", "Second", false},
+		{"Pending Error", false, "XPENDING", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, client := newFakeProvider(t)
+			p.SendMessage(&message.Message{Title: "First"})
+			p.SendMessage(&message.Message{Title: "Second"})
+
+			first, _ := p.GetNextMessage()
+			if tt.deleted {
+				// Deleted without acknowledging it, e.g. by trimming the stream.
+				client.Do("XDEL", "tide-queue", *first.ExternalRef)
+			}
+			client.expireLocks()
+			client.fail = tt.fail
+
+			got, err := p.GetNextMessage()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Provider.GetNextMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Title != tt.wantTitle {
+				t.Errorf("Provider.GetNextMessage() Title = %q, want %q", got.Title, tt.wantTitle)
+			}
+			if _, pending := client.pending[*first.ExternalRef]; pending == tt.deleted {
+				t.Errorf("Provider.GetNextMessage() pending %s = %v, want %v", *first.ExternalRef, pending, !tt.deleted)
+			}
+		})
+	}
+}
+
+func TestProvider_GetNextMessage_reclaimCursor(t *testing.T) {
+	p, client := newFakeProvider(t)
+
+	// More pending entries than a single XAUTOCLAIM scans.
+	const count = 25
+	for i := 1; i <= count; i++ {
+		p.SendMessage(&message.Message{Title: fmt.Sprintf("Message %d", i)})
+	}
+	for i := 1; i <= count; i++ {
+		p.GetNextMessage()
+	}
+	client.expireLocks()
+
+	// Every reclaim scans the entries reclaimed before it.
+	for i := 1; i <= count; i++ {
+		got, err := p.GetNextMessage()
+		if err != nil {
+			t.Fatalf("Provider.GetNextMessage() error = %v", err)
+		}
+		if want := fmt.Sprintf("Message %d", i); got == nil || got.Title != want {
+			t.Fatalf("Provider.GetNextMessage() = %+v, want %q", got, want)
+		}
+	}
+}
+
+func TestProvider_GetNextMessage_exhausted(t *testing.T) {
+	p, client := newFakeProvider(t)
+	p.SendMessage(&message.Message{Title: "Retry"})
+
+	for attempt := 0; attempt < RetryAttempts; attempt++ {
+		p.GetNextMessage()
+		client.expireLocks()
+	}
+
+	if msg, err := p.GetNextMessage(); msg != nil || err != nil {
+		t.Errorf("Provider.GetNextMessage() = %+v, %v, want no message", msg, err)
+	}
+
+	// The entry is acknowledged, but kept in the stream.
+	if len(client.pending) != 0 || len(client.entries) != 1 {
+		t.Errorf("Provider.GetNextMessage() left %d pending and %d entries, want 0 and 1", len(client.pending), len(client.entries))
+	}
+}
+
+func TestProvider_DeleteMessage(t *testing.T) {
+	ref := func(s string) *string { return &s }
+
+	tests := []struct {
+		name         string
+		ref          *string
+		fail         string
+		wantErr      bool
+		wantCommands []string
+	}{
+		{"Delete", ref("1-0"), "", false, []string{"XACK", "XDEL"}},
+		{"Missing Message", ref("5-0"), "", false, []string{"XACK", "XDEL"}},
+		{"No Reference", nil, "", true, nil},
+		{"Ack Error", ref("1-0"), "XACK", true, []string{"XACK"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, client := newFakeProvider(t)
+			p.SendMessage(&message.Message{Title: "Test Plugin"})
+			p.GetNextMessage()
+			client.commands = nil
+			client.fail = tt.fail
+
+			if err := p.DeleteMessage(tt.ref); (err != nil) != tt.wantErr {
+				t.Errorf("Provider.DeleteMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(client.commands, tt.wantCommands) {
+				t.Errorf("Provider.DeleteMessage() commands = %v, want %v", client.commands, tt.wantCommands)
+			}
+		})
+	}
+}
+
+func TestProvider_SendMessage(t *testing.T) {
+	p, client := newFakeProvider(t)
+	ref := "7-0"
+
+	if err := p.SendMessage(&message.Message{Title: "Test Plugin", ExternalRef: &ref}); err != nil {
+		t.Fatalf("Provider.SendMessage() error = %v", err)
+	}
+
+	want := []interface{}{messageField, `{"response_api_endpoint":"","payload_type":"","title":"Test Plugin","content":"","slug":"","source_url":"","source_type":"","request_client":"","force":false,"visibility":""}`}
+	if got := client.entries["1-0"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Provider.SendMessage() entry = %v, want %v", got, want)
+	}
+
+	client.fail = "XADD"
+	if err := p.SendMessage(&message.Message{Title: "Test Plugin"}); err == nil {
+		t.Errorf("Provider.SendMessage() error = nil, want error")
+	}
+}
+
+func TestNewWithClient(t *testing.T) {
+	existing := newFakeClient()
+	existing.group = true
+
+	failing := newFakeClient()
+	failing.fail = "XGROUP"
+
+	tests := []struct {
+		name    string
+		client  Client
+		stream  string
+		group   string
+		wantErr bool
+	}{
+		{"New Group", newFakeClient(), "tide-queue", "tide-workers", false},
+		{"Existing Group", existing, "tide-queue", "tide-workers", false},
+		{"Group Error", failing, "tide-queue", "tide-workers", true},
+		{"No Client", nil, "tide-queue", "tide-workers", true},
+		{"No Stream", newFakeClient(), "", "tide-workers", true},
+		{"No Group", newFakeClient(), "tide-queue", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewWithClient(tt.client, tt.stream, tt.group)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewWithClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (p.consumer == "" || p.lockDuration != LockDuration) {
+				t.Errorf("NewWithClient() = %+v, want a consumer with the default lock duration", p)
+			}
+		})
+	}
+}
+
+func TestParseEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   interface{}
+		want    []entry
+		wantErr bool
+	}{
+		{
+			"Entries",
+			[]interface{}{
+				[]interface{}{"1-0", []interface{}{"other", "value", messageField, "{}"}},
+				[]interface{}{"2-0", []interface{}{messageField, "[]"}},
+			},
+			[]entry{{"1-0", "{}"}, {"2-0", "[]"}},
+			false,
+		},
+		{
+			"Deleted Entry",
+			[]interface{}{[]interface{}{"1-0", nil}},
+			[]entry{{"1-0", ""}},
+			false,
+		},
+		{"No Entries", nil, nil, false},
+		{"Invalid Reply", "1-0", nil, true},
+		{"Invalid Entry", []interface{}{"1-0"}, nil, true},
+		{"Invalid ID", []interface{}{[]interface{}{int64(1), nil}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseEntries(tt.reply)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseEntries() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseEntries() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
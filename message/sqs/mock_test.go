@@ -0,0 +1,85 @@
+package sqs
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// fakeSqs keeps the messages of a standard queue in memory and hides received messages for their
+// visibility timeout, so that the provider can be tested as a queue. Unlike SQS, it delivers messages
+// in the order they were sent and ignores the DelaySeconds of new messages.
+type fakeSqs struct {
+	sqsiface.SQSAPI
+	mu       sync.Mutex
+	now      time.Time
+	messages []*fakeSqsMessage
+	receipts int
+}
+
+type fakeSqsMessage struct {
+	body    string
+	receipt string
+	visible time.Time
+}
+
+func newFakeSqs() *fakeSqs {
+	return &fakeSqs{
+		now: time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func (f *fakeSqs) SendMessage(in *sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.messages = append(f.messages, &fakeSqsMessage{
+		body:    aws.StringValue(in.MessageBody),
+		visible: f.now,
+	})
+	return &sqs.SendMessageOutput{}, nil
+}
+
+func (f *fakeSqs) ReceiveMessage(in *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, msg := range f.messages {
+		if msg.visible.After(f.now) {
+			continue
+		}
+
+		f.receipts++
+		msg.receipt = fmt.Sprintf("receipt-%d", f.receipts)
+		msg.visible = f.now.Add(time.Duration(aws.Int64Value(in.VisibilityTimeout)) * time.Second)
+
+		return &sqs.ReceiveMessageOutput{
+			Messages: []*sqs.Message{
+				{
+					Body:          aws.String(msg.body),
+					ReceiptHandle: aws.String(msg.receipt),
+				},
+			},
+		}, nil
+	}
+
+	return &sqs.ReceiveMessageOutput{}, nil
+}
+
+func (f *fakeSqs) DeleteMessage(in *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, msg := range f.messages {
+		if msg.receipt == aws.StringValue(in.ReceiptHandle) {
+			f.messages = append(f.messages[:i], f.messages[i+1:]...)
+			return &sqs.DeleteMessageOutput{}, nil
+		}
+	}
+	return nil, errors.New("ReceiptHandleIsInvalid")
+}
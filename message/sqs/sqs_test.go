@@ -11,6 +11,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
 	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/message/messagetest"
 )
 
 type mockSqs struct {
@@ -412,3 +413,23 @@ func TestSqsProvider_Close(t *testing.T) {
 		})
 	}
 }
+
+// The suite runs on a standard queue, because a FIFO queue delivers the messages of a group
+// (RequestClient-Slug) one at a time, while the suite expects the next message of the same
+// slug. The lock and retry checks are not run: SQS hides received messages for the
+// VisibilityTimeout of the receive, and its retries are set by the redrive policy
+// (maxReceiveCount) of the queue, not by the provider.
+func TestSqsProvider_conformance(t *testing.T) {
+	queue, queueURL := "test", "http://sqsurl/test"
+
+	newProvider := func() message.Provider {
+		return &Provider{
+			session:   &session.Session{},
+			sqs:       newFakeSqs(),
+			QueueName: &queue,
+			QueueURL:  &queueURL,
+		}
+	}
+
+	messagetest.TestProvider(t, newProvider, messagetest.Options{})
+}
@@ -39,11 +39,14 @@ func (t TidePayload) BuildPayload(msg message.Message, data map[string]interface
 		return nil, errors.New("no results to send to Tide API")
 	}
 
+	// Sources that were rejected before their files were read have no checksum.
+	checksum, _ := data["checksum"].(string)
+
 	payloadItem := &tide.Item{
 		Title:         fallbackValue(simpleCodeInfo.Name, msg.Title).(string),
 		Description:   fallbackValue(simpleCodeInfo.Description, msg.Content).(string),
 		Version:       simpleCodeInfo.Version,
-		Checksum:      data["checksum"].(string),
+		Checksum:      checksum,
 		Visibility:    msg.Visibility,
 		ProjectType:   fallbackValue(codeInfo.Type, msg.ProjectType).(string),
 		SourceURL:     msg.SourceURL,
@@ -58,6 +61,14 @@ func (t TidePayload) BuildPayload(msg message.Message, data map[string]interface
 		payloadItem.Project = []string{msg.Slug}
 	}
 
+	if manifest, ok := data["manifestFile"].(tide.AuditDetails); ok {
+		payloadItem.Manifest = &manifest
+	}
+
+	if revision, ok := data["revision"].(string); ok {
+		payloadItem.Revision = revision
+	}
+
 	return json.Marshal(payloadItem)
 }
 
@@ -126,6 +126,55 @@ func TestTidePayload_BuildPayload(t *testing.T) {
 			[]byte(`{"title":"","content":"","version":"","checksum":"abcdefg","visibility":"","project_type":"plugin","source_url":"","source_type":"","code_info":{"type":"plugin","details":[],"cloc":{}},"reports":{"phpcs_demo":{"raw":{"type":"mock","filename":"mock","path":"mock"},"parsed":{"type":"mock","filename":"mock","path":"mock"},"summary":{}}},"project":["project-one"]}`),
 			false,
 		},
+		{
+			"Some Results - With Manifest And Revision",
+			fields{
+				&MockTideClient{},
+			},
+			args{
+				data: map[string]interface{}{
+					"info": mockInfo,
+					"phpcs_demo": tide.AuditResult{
+						Raw: tide.AuditDetails{
+							Type:     "mock",
+							FileName: "mock",
+							Path:     "mock",
+						},
+					},
+					"checksum": "abcdefg",
+					"manifestFile": tide.AuditDetails{
+						Type:     "mock",
+						FileName: "abcdefg-manifest.json",
+						Path:     "mock",
+					},
+					"revision": "1234",
+				},
+			},
+			[]byte(`{"title":"","content":"","version":"","checksum":"abcdefg","visibility":"","project_type":"plugin","source_url":"","source_type":"","code_info":{"type":"plugin","details":[],"cloc":{}},"reports":{"phpcs_demo":{"raw":{"type":"mock","filename":"mock","path":"mock"},"parsed":{},"summary":{}}},"manifest":{"type":"mock","filename":"abcdefg-manifest.json","path":"mock"},"revision":"1234"}`),
+			false,
+		},
+		{
+			"Rejected Source - No Checksum",
+			fields{
+				&MockTideClient{},
+			},
+			args{
+				data: map[string]interface{}{
+					"info": tide.CodeInfo{},
+					"ingest": tide.AuditResult{
+						Error: "archive rejected",
+						Extra: map[string]interface{}{
+							"violation": "path_traversal",
+						},
+					},
+				},
+				msg: message.Message{
+					ProjectType: "plugin",
+				},
+			},
+			[]byte(`{"title":"","content":"","version":"","checksum":"","visibility":"","project_type":"plugin","source_url":"","source_type":"","code_info":{"type":"","details":null,"cloc":null},"reports":{"ingest":{"raw":{},"parsed":{},"summary":{},"error":"archive rejected","extra":{"violation":"path_traversal"}}}}`),
+			false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -43,8 +43,10 @@ func (info *Info) Run(errc *chan error) error {
 				// Run the process.
 				// If processing produces an error send it up the error channel.
 				if err := info.Do(); err != nil {
+					// The item is dropped, so it no longer needs its files.
+					releaseSource(*info.Result)
 					// Pass the error up the error channel.
-					*errc <- errors.New("Info Error: " + err.Error())
+					info.sendError(errc, errors.New("Info Error: "+err.Error()))
 					// continue so that the message doesn't get passed along.
 					continue
 				}
@@ -64,6 +66,13 @@ func (info *Info) Do() error {
 
 	result := *info.Result
 
+	// Rejected sources have no files, the payload only needs to know that there is no code info.
+	if rejected(result) {
+		result["info"] = tide.CodeInfo{}
+		info.Result = &result
+		return nil
+	}
+
 	log.Log(info.Message.Title, "Processing CodeInfo")
 
 	// Try to get filesPath from results first.
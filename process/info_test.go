@@ -161,6 +161,25 @@ func TestInfo_Run(t *testing.T) {
 			true,
 			false,
 		},
+		{
+			"Rejected Source",
+			fields{
+				In:  make(<-chan Processor),
+				Out: make(chan Processor),
+			},
+			[]Processor{
+				&Ingest{
+					Process: Process{
+						Message: message.Message{Title: "Rejected Source"},
+						Result: &Result{
+							"ingest": tide.AuditResult{Error: "archive rejected"},
+						},
+					},
+				},
+			},
+			false,
+			false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -208,6 +227,46 @@ func TestInfo_Run(t *testing.T) {
 	}
 }
 
+func TestInfo_Run_releasesFiles(t *testing.T) {
+
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	path := "./testdata/tmp/audit-info"
+	os.MkdirAll(path, os.ModePerm)
+	defer os.RemoveAll("./testdata/tmp")
+
+	// There is no files path to read, so the item is dropped.
+	in := make(chan Processor, 1)
+	in <- &Ingest{
+		Process: Process{
+			Message: message.Message{Title: "Dropped"},
+			Result:  &Result{tempFilesKey: path},
+		},
+	}
+
+	info := &Info{
+		In:  in,
+		Out: make(chan Processor, 1),
+	}
+
+	errc := make(chan error, 1)
+	if err := info.Run(&errc); err != nil {
+		t.Fatalf("Info.Run() error = %v", err)
+	}
+
+	select {
+	case <-errc:
+	case <-time.After(time.Second):
+		t.Fatalf("Info.Run() did not report the error")
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Info.Run() did not remove %v", path)
+	}
+}
+
 func Test_getProjectDetails(t *testing.T) {
 	type args struct {
 		msg  message.Message
@@ -1,29 +1,49 @@
 package process
 
 import (
-	"crypto/sha256"
-	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"io/ioutil"
+	"os"
 
 	"github.com/wptide/pkg/log"
 	"github.com/wptide/pkg/message"
 	"github.com/wptide/pkg/source"
-	"github.com/wptide/pkg/source/zip"
+	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/tide"
+
+	// Register the source kinds that Ingest can handle.
+	_ "github.com/wptide/pkg/source/git"
+	_ "github.com/wptide/pkg/source/local"
+	_ "github.com/wptide/pkg/source/svn"
+	_ "github.com/wptide/pkg/source/tar"
+	_ "github.com/wptide/pkg/source/wporg"
+	_ "github.com/wptide/pkg/source/zip"
 )
 
 // Ingest defines the structure for our Ingest process.
 type Ingest struct {
-	Process                              // Inherits methods from Process.
-	In            <-chan message.Message // Expects a message channel as input.
-	Out           chan Processor         // Send results to an output channel.
-	TempFolder    string                 // Path to a temp folder where files will be extracted.
-	sourceManager source.Source          // Responsible for getting the code to audit.
+	Process                                       // Inherits methods from Process.
+	In                     <-chan message.Message // Expects a message channel as input.
+	Out                    chan Processor         // Send results to an output channel.
+	TempFolder             string                 // Path to a temp folder where files will be extracted.
+	Cache                  *source.Cache          // (Optional) Reuses prepared sources instead of using TempFolder.
+	StorageProvider        storage.Provider       // (Optional) Storage provider to upload the manifest of the files to.
+	PrivateStorageProvider storage.Provider       // (Optional) Storage provider for the manifests of private audits.
+	Limits                 *source.Limits         // (Optional) Limits for archive sources, source.DefaultLimits if not set.
+	sourceManager          source.Source          // Responsible for getting the code to audit.
 }
 
+// Result keys of the files of an item, released by Response (or where the item is dropped) when the item is done.
+const (
+	sourceEntryKey = "sourceEntry" // The cached source.
+	tempFilesKey   = "tempFiles"   // The folder of a source prepared in TempFolder.
+)
+
 // Run executes the process in the pipeline.
 func (ig *Ingest) Run(errc *chan error) error {
 	// If we don't have a temp folder, then we need a fatal.
-	if ig.TempFolder == "" {
+	if ig.TempFolder == "" && ig.Cache == nil {
 		return errors.New("no temp folder provided for processes")
 	}
 	if ig.In == nil {
@@ -41,23 +61,33 @@ func (ig *Ingest) Run(errc *chan error) error {
 				// Init the Result object.
 				ig.Result = &Result{}
 
+				// Get the original message.
+				ig.SetMessage(msg)
+
 				// If message is invalid, skip it, but keep listening on the channel.
 				if err := validateMessage(msg); err != nil {
 					// Pass the error up the error channel.
-					*errc <- errors.New("Ingest Error: " + err.Error())
+					ig.sendError(errc, errors.New("Ingest Error: "+err.Error()))
 
 					// continue so that the message doesn't get passed along.
 					continue
 				}
 
-				// Get the original message.
-				ig.SetMessage(msg)
-
 				// Run the process.
 				// If processing produces an error send it up the error channel.
 				if err := ig.Do(); err != nil {
+					// Other items than rejected sources are dropped, so they no longer need their files.
+					if !rejected(*ig.Result) {
+						releaseSource(*ig.Result)
+					}
+
 					// Pass the error up the error channel.
-					*errc <- errors.New("Ingest Error: " + err.Error())
+					ig.sendError(errc, errors.New("Ingest Error: "+err.Error()))
+
+					// Rejected sources are passed along so that the rejection is reported in the response.
+					if rejected(*ig.Result) {
+						ig.Out <- ig
+					}
 
 					// continue so that the message doesn't get passed along.
 					continue
@@ -79,27 +109,38 @@ func (ig *Ingest) Do() error {
 	log.Log(ig.Message.Title, "Ingesting...")
 
 	// Set the source manager based on message.
-	switch source.GetKind(ig.Message.SourceURL) {
-	case "zip":
-		ig.sourceManager = zip.NewZip(ig.Message.SourceURL)
+	sourceManager, err := source.Resolve(ig.Message.SourceURL, ig.Message.SourceType)
+	if err != nil {
+		return ig.Error(err.Error())
 	}
+	ig.sourceManager = sourceManager
 
-	// Return an error if we don't have a source manager.
-	if ig.sourceManager == nil {
-		return ig.Error("could not get appropriate source manager to handle ingest")
+	if limiter, ok := sourceManager.(source.Limiter); ok && ig.Limits != nil {
+		limiter.SetLimits(*ig.Limits)
 	}
 
-	// Calculate hash of the source url.
-	hasher := sha256.New()
-	hasher.Write([]byte(ig.Message.SourceURL))
+	if ig.Cache != nil {
+		return ig.prepareCached()
+	}
 
 	// Set the path to where we will extract the files.
-	ig.SetFilesPath(ig.TempFolder + "/audit-" + base64.URLEncoding.EncodeToString(hasher.Sum(nil)))
+	// Every item gets its own folder, because items of the same source can be audited at the same time.
+	path, err := ioutil.TempDir(ig.TempFolder, "audit-")
+	if err != nil {
+		return ig.Error("could not create temp folder: " + err.Error())
+	}
+	ig.SetFilesPath(path)
+	(*ig.Result)[tempFilesKey] = path
 
 	// Download/Prepare the files.
-	err := ig.sourceManager.PrepareFiles(ig.GetFilesPath())
+	// Sources that support it stop when the pipeline context is done.
+	if ctxSource, ok := ig.sourceManager.(source.ContextSource); ok && ig.context != nil {
+		err = ctxSource.PrepareFilesContext(ig.context, ig.GetFilesPath())
+	} else {
+		err = ig.sourceManager.PrepareFiles(ig.GetFilesPath())
+	}
 	if err != nil {
-		return err
+		return ig.prepareError(err)
 	}
 
 	// Project checksum.
@@ -115,11 +156,146 @@ func (ig *Ingest) Do() error {
 	result["filesPath"] = ig.GetFilesPath()
 	ig.Result = &result
 
+	ig.recordRevision()
+
+	// Keep the details of every file.
+	var manifest source.Manifest
+	if manifester, ok := ig.sourceManager.(source.Manifester); ok {
+		manifest = manifester.GetManifest()
+	} else if manifest, err = source.NewManifest(ig.GetFilesPath()+"/unzipped", ig.sourceManager.GetFiles(), nil); err != nil {
+		return ig.Error("could not create manifest: " + err.Error())
+	}
+	if err := ig.storeManifest(manifest); err != nil {
+		return err
+	}
+
 	log.Log(ig.Message.Title, "Project checksum: `"+checksum+"`")
 
 	return nil
 }
 
+// prepareCached gets the files from the cache, preparing them if they are not cached yet.
+func (ig *Ingest) prepareCached() error {
+	entry, err := ig.Cache.Prepare(ig.contextOrBackground(), ig.sourceManager, ig.Message.SourceURL)
+	if err != nil {
+		return ig.prepareError(err)
+	}
+
+	ig.SetFilesPath(entry.Path)
+
+	// Populate the result.
+	result := *ig.Result
+	result["checksum"] = entry.Checksum
+	result["files"] = entry.Files
+	result["filesPath"] = entry.Path
+	result[sourceEntryKey] = entry
+	ig.Result = &result
+
+	ig.recordRevision()
+
+	// Sources reused by their ETag are not prepared, the cache keeps their revision.
+	if _, ok := result["revision"]; !ok && entry.GetRevision() != "" {
+		result["revision"] = entry.GetRevision()
+	}
+
+	if err := ig.storeManifest(entry.Manifest); err != nil {
+		return err
+	}
+
+	log.Log(ig.Message.Title, "Project checksum: `"+entry.Checksum+"`")
+
+	return nil
+}
+
+// recordRevision adds the revision of version control sources to the result.
+func (ig *Ingest) recordRevision() {
+	if revisioner, ok := ig.sourceManager.(source.Revisioner); ok && revisioner.GetRevision() != "" {
+		result := *ig.Result
+		result["revision"] = revisioner.GetRevision()
+		ig.Result = &result
+	}
+}
+
+// storeManifest adds the manifest to the result and uploads it if there is a storage provider.
+func (ig *Ingest) storeManifest(manifest source.Manifest) error {
+	result := *ig.Result
+	result["manifest"] = manifest
+	ig.Result = &result
+
+	if ig.StorageProvider == nil && ig.PrivateStorageProvider == nil {
+		return nil
+	}
+
+	provider, err := ig.storageFor(ig.StorageProvider, ig.PrivateStorageProvider)
+	if err != nil {
+		return err
+	}
+	if provider == nil {
+		return nil
+	}
+
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	filename := manifest.Checksum + "-manifest.json"
+	path := ig.GetFilesPath() + "/" + filename
+
+	if err := writeFile(path, manifestJSON, 0644); err != nil {
+		return ig.Error("could not write manifest: " + err.Error())
+	}
+
+	metadata := ig.storageMetadata(manifest.Checksum, "manifest")
+	if err := storage.UploadFile(ig.contextOrBackground(), provider, path, filename, metadata); err != nil {
+		return ig.Error("could not upload manifest: " + err.Error())
+	}
+
+	result["manifestFile"] = tide.AuditDetails{
+		Type:     provider.Kind(),
+		FileName: filename,
+		Path:     provider.CollectionRef(),
+		Encoding: storage.Encoding(provider),
+	}
+
+	return nil
+}
+
+// prepareError handles an error preparing the files.
+func (ig *Ingest) prepareError(err error) error {
+	// Keep rejected archives with the results so that they can be reported in the payload.
+	if archiveErr, ok := err.(*source.ArchiveError); ok {
+		result := *ig.Result
+		result["ingest"] = tide.AuditResult{
+			Error: archiveErr.Error(),
+			Extra: map[string]interface{}{
+				"violation": archiveErr.Violation,
+				"entry":     archiveErr.Entry,
+			},
+		}
+		ig.Result = &result
+	}
+	return err
+}
+
+// rejected reports whether the source of a result was rejected by Ingest, in which case there are no files to audit.
+func rejected(result Result) bool {
+	_, ok := result["ingest"].(tide.AuditResult)
+	return ok
+}
+
+// releaseSource releases the cached source of a result, or removes the files prepared in TempFolder.
+func releaseSource(result Result) {
+	if entry, ok := result[sourceEntryKey].(*source.CacheEntry); ok {
+		entry.Release()
+		delete(result, sourceEntryKey)
+	}
+	if path, ok := result[tempFilesKey].(string); ok {
+		os.RemoveAll(path)
+		delete(result, tempFilesKey)
+	}
+}
+
 // validateMessage ensures that a message to be processed has the minimum requirements.
 func validateMessage(msg message.Message) error {
 
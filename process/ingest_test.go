@@ -1,7 +1,9 @@
 package process
 
 import (
+	"archive/zip"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -14,6 +16,8 @@ import (
 	"github.com/wptide/pkg/log"
 	"github.com/wptide/pkg/message"
 	"github.com/wptide/pkg/source"
+	"github.com/wptide/pkg/source/local"
+	"github.com/wptide/pkg/tide"
 )
 
 type mockSource struct{}
@@ -22,6 +26,55 @@ func (m mockSource) PrepareFiles(dest string) error { return nil }
 func (m mockSource) GetChecksum() string            { return "" }
 func (m mockSource) GetFiles() []string             { return nil }
 
+// revisionSource is a version control source without files.
+type revisionSource struct{}
+
+func (r revisionSource) PrepareFiles(dest string) error { return nil }
+func (r revisionSource) GetChecksum() string            { return "abcdefg" }
+func (r revisionSource) GetFiles() []string             { return nil }
+func (r revisionSource) GetRevision() string            { return "1234" }
+
+// preparedRevisionSource is a version control source with files, which only knows its revision once prepared.
+type preparedRevisionSource struct {
+	revision string
+	files    []string
+}
+
+func (r *preparedRevisionSource) PrepareFiles(dest string) error {
+	folder := dest + "/unzipped"
+	os.MkdirAll(folder, os.ModePerm)
+	r.files = []string{folder + "/plugin.php"}
+	r.revision = "1234"
+	return ioutil.WriteFile(r.files[0], []byte("<?php\n"), 0644)
+}
+func (r *preparedRevisionSource) GetChecksum() string {
+	return source.CombinedChecksum([]string{"plugin"})
+}
+func (r *preparedRevisionSource) GetFiles() []string  { return r.files }
+func (r *preparedRevisionSource) GetRevision() string { return r.revision }
+
+func init() {
+	// Resolves messages with a "fake" source type to mockSource.
+	source.Register(source.Kind{
+		Name: "fake",
+		New: func(url string) source.Source {
+			return mockSource{}
+		},
+	})
+	source.Register(source.Kind{
+		Name: "fake-vcs",
+		New: func(url string) source.Source {
+			return revisionSource{}
+		},
+	})
+	source.Register(source.Kind{
+		Name: "fake-vcs-files",
+		New: func(url string) source.Source {
+			return &preparedRevisionSource{}
+		},
+	})
+}
+
 type mockProcess struct {
 	Process
 	In  <-chan Processor
@@ -37,6 +90,21 @@ var ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http
 	case "/test.zip":
 		http.ServeFile(w, r, "./testdata/test.zip")
 		return
+	case "/test.tar.gz":
+		http.ServeFile(w, r, "./testdata/test.tar.gz")
+		return
+	case "/traversal.zip":
+		z := zip.NewWriter(w)
+		f, _ := z.Create("../../evil.php")
+		f.Write([]byte("<?php\n"))
+		z.Close()
+		return
+	case "/other.zip":
+		z := zip.NewWriter(w)
+		f, _ := z.Create("other/other.php")
+		f.Write([]byte("<?php\n"))
+		z.Close()
+		return
 	case "/api/audits":
 		http.ServeFile(w, r, `{ "message": "Payload received" }`)
 		return
@@ -125,6 +193,13 @@ func TestIngest_process(t *testing.T) {
 	log.SetOutput(&b)
 	defer log.SetOutput(os.Stdout)
 
+	// Local sources can only read from allowed roots.
+	oldRoots := local.AllowedRoots
+	local.AllowedRoots = []string{"./testdata"}
+	defer func() {
+		local.AllowedRoots = oldRoots
+	}()
+
 	// Make a /tmp folder
 	os.Mkdir("./testdata/tmp", os.ModePerm)
 
@@ -135,7 +210,6 @@ func TestIngest_process(t *testing.T) {
 
 	type options struct {
 		tempFolder string
-		sourceMgr  source.Source
 	}
 
 	tests := []struct {
@@ -155,6 +229,28 @@ func TestIngest_process(t *testing.T) {
 			options{},
 			false,
 		},
+		{
+			"Valid Tar Ingest",
+			message.Message{
+				Title:               "Test Tar Ingest",
+				ResponseAPIEndpoint: ts.URL + "/api/audits",
+				SourceURL:           ts.URL + "/test.tar.gz",
+				SourceType:          "tar",
+			},
+			options{},
+			false,
+		},
+		{
+			"Valid Local Ingest",
+			message.Message{
+				Title:               "Test Local Ingest",
+				ResponseAPIEndpoint: ts.URL + "/api/audits",
+				SourceURL:           "./testdata/info/theme/unzipped",
+				SourceType:          "local",
+			},
+			options{},
+			false,
+		},
 		{
 			"No valid source manager",
 			message.Message{
@@ -177,6 +273,17 @@ func TestIngest_process(t *testing.T) {
 			options{},
 			true,
 		},
+		{
+			"Git source not valid",
+			message.Message{
+				Title:               "Invalid Git Source",
+				ResponseAPIEndpoint: ts.URL + "/api/audits",
+				SourceURL:           ts.URL + "/notfound.git#v1.0.0",
+				SourceType:          "git",
+			},
+			options{},
+			true,
+		},
 		{
 			"Empty Checksum",
 			message.Message{
@@ -185,9 +292,7 @@ func TestIngest_process(t *testing.T) {
 				SourceURL:           ts.URL + "/empty.fake", // Use a fake "extension".
 				SourceType:          "fake",
 			},
-			options{
-				sourceMgr: mockSource{},
-			},
+			options{},
 			true,
 		},
 	}
@@ -202,10 +307,6 @@ func TestIngest_process(t *testing.T) {
 				ig.TempFolder = tt.options.tempFolder
 			}
 
-			if tt.options.sourceMgr != nil {
-				ig.sourceManager = tt.options.sourceMgr
-			}
-
 			ig.Message = tt.message
 			if err := ig.Do(); (err != nil) != tt.wantErr {
 				t.Errorf("Ingest.Do() error = %v, wantErr %v", err, tt.wantErr)
@@ -214,6 +315,513 @@ func TestIngest_process(t *testing.T) {
 	}
 }
 
+func TestIngest_archiveError(t *testing.T) {
+
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	// Make a /tmp folder
+	os.Mkdir("./testdata/tmp", os.ModePerm)
+
+	// Clean up after.
+	defer func() {
+		os.RemoveAll("./testdata/tmp")
+	}()
+
+	ig := &Ingest{
+		TempFolder: "./testdata/tmp",
+	}
+	ig.Result = &Result{}
+	ig.Message = message.Message{
+		Title:               "Zip Slip",
+		ResponseAPIEndpoint: ts.URL + "/api/audits",
+		SourceURL:           ts.URL + "/traversal.zip",
+		SourceType:          "zip",
+	}
+
+	if err := ig.Do(); err == nil {
+		t.Fatalf("Ingest.Do() error = nil, want archive error")
+	}
+
+	result := *ig.Result
+	report, ok := result["ingest"].(tide.AuditResult)
+	if !ok {
+		t.Fatalf("Ingest.Do() result[ingest] = %v, want tide.AuditResult", result["ingest"])
+	}
+	if report.Extra["violation"] != source.ViolationPathTraversal {
+		t.Errorf("Ingest.Do() violation = %v, want %v", report.Extra["violation"], source.ViolationPathTraversal)
+	}
+}
+
+func TestIngest_limits(t *testing.T) {
+
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	// Make a /tmp folder
+	os.Mkdir("./testdata/tmp", os.ModePerm)
+
+	// Clean up after.
+	defer func() {
+		os.RemoveAll("./testdata/tmp")
+	}()
+
+	defaults := source.DefaultLimits
+
+	ig := &Ingest{
+		TempFolder: "./testdata/tmp",
+		Limits:     &source.Limits{MaxFiles: 1},
+	}
+	ig.Result = &Result{}
+	ig.Message = message.Message{
+		Title:               "Too Many Files",
+		ResponseAPIEndpoint: ts.URL + "/api/audits",
+		SourceURL:           ts.URL + "/test.zip",
+		SourceType:          "zip",
+	}
+
+	if err := ig.Do(); err == nil {
+		t.Fatalf("Ingest.Do() error = nil, want archive error")
+	}
+
+	report, ok := (*ig.Result)["ingest"].(tide.AuditResult)
+	if !ok {
+		t.Fatalf("Ingest.Do() result[ingest] = %v, want tide.AuditResult", (*ig.Result)["ingest"])
+	}
+	if report.Extra["violation"] != source.ViolationMaxFiles {
+		t.Errorf("Ingest.Do() violation = %v, want %v", report.Extra["violation"], source.ViolationMaxFiles)
+	}
+	if source.DefaultLimits != defaults {
+		t.Errorf("source.DefaultLimits = %v, want %v", source.DefaultLimits, defaults)
+	}
+}
+
+func TestIngest_Run_rejected(t *testing.T) {
+
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	// Make a /tmp folder
+	os.Mkdir("./testdata/tmp", os.ModePerm)
+
+	// Clean up after.
+	defer func() {
+		os.RemoveAll("./testdata/tmp")
+	}()
+
+	// Keep the channel open so that the result is not replaced by another message.
+	in := make(chan message.Message, 1)
+	in <- message.Message{
+		Title:               "Zip Slip",
+		ResponseAPIEndpoint: ts.URL + "/api/audits",
+		SourceURL:           ts.URL + "/traversal.zip",
+		SourceType:          "zip",
+	}
+
+	ig := &Ingest{
+		In:         in,
+		Out:        make(chan Processor, 1),
+		TempFolder: "./testdata/tmp",
+	}
+
+	errc := make(chan error, 1)
+	if err := ig.Run(&errc); err != nil {
+		t.Fatalf("Ingest.Run() error = %v", err)
+	}
+
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Errorf("Ingest.Run() errorChan = nil, want archive error")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Ingest.Run() did not report the rejected archive")
+	}
+
+	select {
+	case out := <-ig.Out:
+		if !rejected(*out.GetResult()) {
+			t.Errorf("Ingest.Run() result = %v, want result[ingest]", *out.GetResult())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Ingest.Run() did not pass the rejected archive along")
+	}
+}
+
+func TestIngest_cache(t *testing.T) {
+
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	// Clean up after.
+	defer func() {
+		os.RemoveAll("./testdata/cache")
+	}()
+
+	cache, err := source.NewCache("./testdata/cache", 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ingest := func(sourceURL, sourceType string) Result {
+		ig := &Ingest{
+			Cache: cache,
+		}
+		ig.Result = &Result{}
+		ig.Message = message.Message{
+			Title:               "Cached",
+			ResponseAPIEndpoint: ts.URL + "/api/audits",
+			SourceURL:           sourceURL,
+			SourceType:          sourceType,
+		}
+		if err := ig.Do(); err != nil {
+			t.Fatalf("Ingest.Do() error = %v", err)
+		}
+		return *ig.Result
+	}
+
+	first := ingest(ts.URL+"/test.zip", "zip")
+	second := ingest(ts.URL+"/test.zip#mirror", "zip")
+
+	if first["filesPath"] != second["filesPath"] || first["checksum"] != second["checksum"] {
+		t.Errorf("Ingest.Do() did not reuse the cached source: %v, %v", first["filesPath"], second["filesPath"])
+	}
+	if cache.Len() != 1 {
+		t.Errorf("Cache.Len() = %d, want 1", cache.Len())
+	}
+
+	// Releasing the items lets the cache evict the tree for other code.
+	releaseSource(first)
+	releaseSource(second)
+	if _, ok := first[sourceEntryKey]; ok {
+		t.Errorf("releaseSource() kept the cache entry in the result")
+	}
+
+	ingest(ts.URL+"/other.zip", "zip")
+	if _, err := os.Stat(first["filesPath"].(string)); !os.IsNotExist(err) {
+		t.Errorf("Cache did not evict %v after release", first["filesPath"])
+	}
+}
+
+func TestIngest_cache_revision(t *testing.T) {
+
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	// Clean up after.
+	defer func() {
+		os.RemoveAll("./testdata/cache")
+	}()
+
+	cache, err := source.NewCache("./testdata/cache", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same ETag every time, so that the second item is a cache hit.
+	repo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+	}))
+	defer repo.Close()
+
+	ingest := func() Result {
+		ig := &Ingest{
+			Cache: cache,
+		}
+		ig.Result = &Result{}
+		ig.Message = message.Message{
+			Title:               "Cached Revision",
+			ResponseAPIEndpoint: ts.URL + "/api/audits",
+			SourceURL:           repo.URL + "/plugin",
+			SourceType:          "fake-vcs-files",
+		}
+		if err := ig.Do(); err != nil {
+			t.Fatalf("Ingest.Do() error = %v", err)
+		}
+		return *ig.Result
+	}
+
+	miss := ingest()
+	hit := ingest()
+
+	if miss["revision"] != "1234" || hit["revision"] != miss["revision"] {
+		t.Errorf("Ingest.Do() result[revision] = %v on a miss and %v on a hit, want %v", miss["revision"], hit["revision"], "1234")
+	}
+	releaseSource(miss)
+	releaseSource(hit)
+}
+
+func TestIngest_tempFiles(t *testing.T) {
+
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	// Make a /tmp folder
+	os.Mkdir("./testdata/tmp", os.ModePerm)
+
+	// Clean up after.
+	defer func() {
+		os.RemoveAll("./testdata/tmp")
+	}()
+
+	ig := &Ingest{
+		TempFolder: "./testdata/tmp",
+	}
+	ig.Result = &Result{}
+	ig.Message = message.Message{
+		Title:               "Temp Files",
+		ResponseAPIEndpoint: ts.URL + "/api/audits",
+		SourceURL:           ts.URL + "/test.zip",
+		SourceType:          "zip",
+	}
+
+	if err := ig.Do(); err != nil {
+		t.Fatalf("Ingest.Do() error = %v", err)
+	}
+
+	result := *ig.Result
+	path, ok := result[tempFilesKey].(string)
+	if !ok || path != ig.GetFilesPath() {
+		t.Fatalf("Ingest.Do() result[%s] = %v, want %v", tempFilesKey, result[tempFilesKey], ig.GetFilesPath())
+	}
+
+	releaseSource(result)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("releaseSource() did not remove %v", path)
+	}
+	if _, ok := result[tempFilesKey]; ok {
+		t.Errorf("releaseSource() kept the temp files in the result")
+	}
+}
+
+func TestIngest_tempFiles_sameSource(t *testing.T) {
+
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	// Make a /tmp folder
+	os.Mkdir("./testdata/tmp", os.ModePerm)
+
+	// Clean up after.
+	defer func() {
+		os.RemoveAll("./testdata/tmp")
+	}()
+
+	ingest := func(title string) Result {
+		ig := &Ingest{
+			TempFolder: "./testdata/tmp",
+		}
+		ig.Result = &Result{}
+		ig.Message = message.Message{
+			Title:               title,
+			ResponseAPIEndpoint: ts.URL + "/api/audits",
+			SourceURL:           ts.URL + "/test.zip",
+			SourceType:          "zip",
+		}
+
+		if err := ig.Do(); err != nil {
+			t.Fatalf("Ingest.Do() error = %v", err)
+		}
+		return *ig.Result
+	}
+
+	first := ingest("First")
+	second := ingest("Second")
+
+	if first[tempFilesKey] == second[tempFilesKey] {
+		t.Fatalf("Ingest.Do() used %v for both items", first[tempFilesKey])
+	}
+
+	// The first item is done while the second one is still audited.
+	releaseSource(first)
+
+	if files, err := ioutil.ReadDir(second["filesPath"].(string) + "/unzipped"); err != nil || len(files) == 0 {
+		t.Errorf("releaseSource() removed the files of another item: %v", err)
+	}
+}
+
+func TestIngest_Run_releasesFiles(t *testing.T) {
+
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	// Make a /tmp folder
+	os.Mkdir("./testdata/tmp", os.ModePerm)
+
+	// Clean up after.
+	defer func() {
+		os.RemoveAll("./testdata/tmp")
+	}()
+
+	// The files are prepared, but the manifest of a private audit can't be stored.
+	in := make(chan message.Message, 1)
+	in <- message.Message{
+		Title:               "Private",
+		ResponseAPIEndpoint: ts.URL + "/api/audits",
+		SourceURL:           ts.URL + "/test.zip",
+		SourceType:          "zip",
+		Visibility:          PrivateVisibility,
+	}
+
+	ig := &Ingest{
+		In:              in,
+		Out:             make(chan Processor, 1),
+		TempFolder:      "./testdata/tmp",
+		StorageProvider: &mockStorage{},
+	}
+
+	errc := make(chan error, 1)
+	if err := ig.Run(&errc); err != nil {
+		t.Fatalf("Ingest.Run() error = %v", err)
+	}
+
+	select {
+	case <-errc:
+	case <-time.After(time.Second):
+		t.Fatalf("Ingest.Run() did not report the error")
+	}
+
+	if len(ig.Out) != 0 {
+		t.Errorf("Ingest.Run() passed the failed item along")
+	}
+
+	files, _ := ioutil.ReadDir("./testdata/tmp")
+	for _, file := range files {
+		t.Errorf("Ingest.Run() left %v in the temp folder", file.Name())
+	}
+}
+
+func TestIngest_manifest(t *testing.T) {
+
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	// Make a /tmp and upload folder.
+	os.Mkdir("./testdata/tmp", os.ModePerm)
+	os.MkdirAll("./testdata/upload", os.ModePerm)
+
+	// Clean up after.
+	defer func() {
+		os.RemoveAll("./testdata/tmp")
+		os.RemoveAll("./testdata/upload")
+	}()
+
+	ig := &Ingest{
+		TempFolder:      "./testdata/tmp",
+		StorageProvider: mockStorage{},
+	}
+	ig.Result = &Result{}
+	ig.Message = message.Message{
+		Title:               "Manifest",
+		ResponseAPIEndpoint: ts.URL + "/api/audits",
+		SourceURL:           ts.URL + "/test.zip",
+		SourceType:          "zip",
+	}
+
+	if err := ig.Do(); err != nil {
+		t.Fatalf("Ingest.Do() error = %v", err)
+	}
+
+	result := *ig.Result
+	manifest, ok := result["manifest"].(source.Manifest)
+	if !ok || len(manifest.Files) != 3 || manifest.Checksum != result["checksum"] {
+		t.Errorf("Ingest.Do() result[manifest] = %v, want 3 files", result["manifest"])
+	}
+
+	want := tide.AuditDetails{
+		Type:     "mock",
+		FileName: manifest.Checksum + "-manifest.json",
+		Path:     "mock-collection",
+	}
+	if got := result["manifestFile"]; got != want {
+		t.Errorf("Ingest.Do() result[manifestFile] = %v, want %v", got, want)
+	}
+	if _, err := os.Stat("./testdata/upload/" + want.FileName); err != nil {
+		t.Errorf("Ingest.Do() did not upload the manifest: %v", err)
+	}
+}
+
+func TestIngest_manifestNoPrivateStorage(t *testing.T) {
+
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	os.Mkdir("./testdata/tmp", os.ModePerm)
+	os.MkdirAll("./testdata/upload", os.ModePerm)
+
+	// Clean up after.
+	defer func() {
+		os.RemoveAll("./testdata/tmp")
+		os.RemoveAll("./testdata/upload")
+	}()
+
+	ig := &Ingest{
+		TempFolder:      "./testdata/tmp",
+		StorageProvider: mockStorage{},
+	}
+	ig.Result = &Result{}
+	ig.Message = message.Message{
+		Title:               "Private Manifest",
+		ResponseAPIEndpoint: ts.URL + "/api/audits",
+		SourceURL:           ts.URL + "/test.zip",
+		SourceType:          "zip",
+		Visibility:          PrivateVisibility,
+	}
+
+	if err := ig.Do(); err == nil {
+		t.Errorf("Ingest.Do() error = nil, want an error without a private storage provider")
+	}
+
+	// The manifest of the private audit is not uploaded in the clear.
+	if uploaded, _ := ioutil.ReadDir("./testdata/upload"); len(uploaded) != 0 {
+		t.Errorf("Ingest.Do() uploaded %d files, want none", len(uploaded))
+	}
+}
+
+func TestIngest_revision(t *testing.T) {
+
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	// Make a /tmp folder
+	os.Mkdir("./testdata/tmp", os.ModePerm)
+
+	// Clean up after.
+	defer func() {
+		os.RemoveAll("./testdata/tmp")
+	}()
+
+	ig := &Ingest{
+		TempFolder: "./testdata/tmp",
+	}
+	ig.Result = &Result{}
+	ig.Message = message.Message{
+		Title:               "Revision",
+		ResponseAPIEndpoint: ts.URL + "/api/audits",
+		SourceURL:           ts.URL + "/plugin",
+		SourceType:          "fake-vcs",
+	}
+
+	if err := ig.Do(); err != nil {
+		t.Fatalf("Ingest.Do() error = %v", err)
+	}
+
+	if got := (*ig.Result)["revision"]; got != "1234" {
+		t.Errorf("Ingest.Do() result[revision] = %v, want %v", got, "1234")
+	}
+}
+
 func TestIngest_Run(t *testing.T) {
 
 	b := bytes.Buffer{}
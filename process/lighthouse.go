@@ -1,9 +1,11 @@
 package process
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/wptide/pkg/log"
@@ -19,11 +21,12 @@ var (
 
 // Lighthouse defines the structure for our Lighthouse process.
 type Lighthouse struct {
-	Process                          // Inherits methods from Process.
-	In              <-chan Processor // Expects a processor channel as input.
-	Out             chan Processor   // Send results to an output channel.
-	TempFolder      string           // Path to a temp folder where reports will be generated.
-	StorageProvider storage.Provider // Storage provider to upload reports to.
+	Process                                 // Inherits methods from Process.
+	In                     <-chan Processor // Expects a processor channel as input.
+	Out                    chan Processor   // Send results to an output channel.
+	TempFolder             string           // Path to a temp folder where reports will be generated.
+	StorageProvider        storage.Provider // Storage provider to upload reports to.
+	PrivateStorageProvider storage.Provider // (Optional) Storage provider for the reports of private audits, e.g. an encrypt.Provider.
 }
 
 // Run runs the process in a pipeline.
@@ -54,7 +57,15 @@ func (lh *Lighthouse) Run(errc *chan error) error {
 				// Assume that the rest of the message is also broken.
 				// Don't pass this down the pipe.
 				if lh.Message.Title == "" {
-					*errc <- errors.New("Lighthouse Error: " + lh.Error("invalid message").Error())
+					// The item is dropped, so it no longer needs its files.
+					releaseSource(*lh.Result)
+					lh.sendError(errc, errors.New("Lighthouse Error: "+lh.Error("invalid message").Error()))
+					continue
+				}
+
+				// Rejected sources have no checksum to store the report with.
+				if rejected(*lh.Result) {
+					lh.Out <- lh
 					continue
 				}
 
@@ -64,7 +75,7 @@ func (lh *Lighthouse) Run(errc *chan error) error {
 					if audit.Type == "lighthouse" {
 						if err := lh.Do(); err != nil {
 							// Pass the error up the error channel.
-							*errc <- errors.New("Lighthouse Error: " + err.Error())
+							lh.sendError(errc, errors.New("Lighthouse Error: "+err.Error()))
 							// Don't break, the message is still useful to other processes.
 						}
 					}
@@ -88,6 +99,11 @@ func (lh *Lighthouse) Do() error {
 		lhRunner = defaultRunner
 	}
 
+	// Don't run the audit if its report can't be stored.
+	if _, err := lh.storageFor(lh.StorageProvider, lh.PrivateStorageProvider); err != nil {
+		return err
+	}
+
 	var results *tide.LighthouseSummary
 
 	// Note: This assumes the shell script `lh` is in $PATH and contains the following command:
@@ -145,21 +161,37 @@ func (lh Lighthouse) uploadToStorage(buffer []byte) (*tide.AuditResult, error) {
 	}
 
 	storageRef := checksum + "-lighthouse-raw.json"
-	filename := strings.TrimRight(lh.TempFolder, "/") + "/" + storageRef
 
-	err := writeFile(filename, buffer, 0644)
+	provider, err := lh.storageFor(lh.StorageProvider, lh.PrivateStorageProvider)
 	if err != nil {
-		return nil, errors.New("could not write lighthouse audit to tempFolder")
+		return nil, err
 	}
+	metadata := lh.storageMetadata(checksum, "lighthouse")
 
-	err = lh.StorageProvider.UploadFile(filename, storageRef)
+	if streamer, ok := provider.(storage.Streamer); ok {
+		// Stream the report straight to storage without a temp file.
+		err = streamer.Put(lh.contextOrBackground(), storageRef, bytes.NewReader(buffer), metadata)
+	} else {
+		filename := strings.TrimRight(lh.TempFolder, "/") + "/" + storageRef
+
+		err = writeFile(filename, buffer, 0644)
+		if err != nil {
+			return nil, errors.New("could not write lighthouse audit to tempFolder")
+		}
+
+		err = storage.UploadFile(lh.contextOrBackground(), provider, filename, storageRef, metadata)
+
+		// The uploaded report is not kept in the temp folder, it may be a private report.
+		os.Remove(filename)
+	}
 
 	if err == nil {
 		results = &tide.AuditResult{
 			Raw: tide.AuditDetails{
-				Type:     lh.StorageProvider.Kind(),
+				Type:     provider.Kind(),
 				FileName: storageRef,
-				Path:     lh.StorageProvider.CollectionRef(),
+				Path:     provider.CollectionRef(),
+				Encoding: storage.Encoding(provider),
 			},
 		}
 	}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -13,6 +14,9 @@ import (
 	"github.com/wptide/pkg/message"
 	"github.com/wptide/pkg/shell"
 	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/storage/compress"
+	"github.com/wptide/pkg/storage/encrypt"
+	"github.com/wptide/pkg/storage/memory"
 )
 
 type mockRunner struct{}
@@ -455,3 +459,161 @@ func exampleLighthouseReport() string {
   ]
 }`
 }
+
+func TestLighthouse_uploadToStorage_streaming(t *testing.T) {
+	provider := memory.NewMemoryStorage("reports")
+	checksum := "5dc1fdd9c8a1bc2ac3a3e8b2ae1b4c0d0f1d4f3a2bbcd0c5b1b1d0d1e9c6e3f2"
+
+	lh := Lighthouse{
+		Process: Process{
+			Message: message.Message{Slug: "twentyseventeen"},
+			Result:  &Result{"checksum": checksum},
+		},
+		TempFolder:      "./testdata/missing",
+		StorageProvider: provider,
+	}
+
+	results, err := lh.uploadToStorage([]byte(`{"lighthouse":true}`))
+	if err != nil {
+		t.Fatalf("Lighthouse.uploadToStorage() error = %v", err)
+	}
+	if results.Raw.Type != "memory" || results.Raw.FileName != checksum+"-lighthouse-raw.json" {
+		t.Errorf("Lighthouse.uploadToStorage() Raw = %+v, want the streamed report", results.Raw)
+	}
+	info, err := provider.Stat(results.Raw.FileName)
+	if err != nil {
+		t.Fatalf("Lighthouse.uploadToStorage() did not store the report: %v", err)
+	}
+
+	want := map[string]string{
+		storage.MetaSlug:     "twentyseventeen",
+		storage.MetaChecksum: checksum,
+		storage.MetaAudit:    "lighthouse",
+	}
+	if !reflect.DeepEqual(info.Metadata, want) {
+		t.Errorf("Lighthouse.uploadToStorage() metadata = %v, want %v", info.Metadata, want)
+	}
+}
+
+func TestLighthouse_uploadToStorage_private(t *testing.T) {
+	public := memory.NewMemoryStorage("reports")
+	stored := memory.NewMemoryStorage("private-reports")
+	private, _ := encrypt.NewProvider(stored, []byte("0123456789abcdef0123456789abcdef"))
+	report := []byte(`{"lighthouse":true}`)
+
+	tests := []struct {
+		name       string
+		visibility string
+		want       storage.Provider
+	}{
+		{"Public", "public", public},
+		{"Private", PrivateVisibility, private},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lh := Lighthouse{
+				Process: Process{
+					Message: message.Message{Visibility: tt.visibility},
+					Result:  &Result{"checksum": "5dc1fdd9c8a1bc2ac3a3e8b2ae1b4c0d0f1d4f3a2bbcd0c5b1b1d0d1e9c6e3f2"},
+				},
+				StorageProvider:        public,
+				PrivateStorageProvider: private,
+			}
+
+			results, err := lh.uploadToStorage(report)
+			if err != nil {
+				t.Fatalf("Lighthouse.uploadToStorage() error = %v", err)
+			}
+			if results.Raw.Path != tt.want.CollectionRef() {
+				t.Errorf("Lighthouse.uploadToStorage() Raw.Path = %q, want %q", results.Raw.Path, tt.want.CollectionRef())
+			}
+
+			r, err := tt.want.(storage.Streamer).Get(context.Background(), results.Raw.FileName)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			defer r.Close()
+			if got, _ := ioutil.ReadAll(r); !bytes.Equal(got, report) {
+				t.Errorf("Get() = %s, want %s", got, report)
+			}
+		})
+	}
+
+	// The private report is not stored in the clear.
+	r, _ := stored.Get(context.Background(), "5dc1fdd9c8a1bc2ac3a3e8b2ae1b4c0d0f1d4f3a2bbcd0c5b1b1d0d1e9c6e3f2-lighthouse-raw.json")
+	defer r.Close()
+	if raw, _ := ioutil.ReadAll(r); bytes.Contains(raw, report) {
+		t.Errorf("Lighthouse.uploadToStorage() stored the private report in the clear")
+	}
+}
+
+func TestLighthouse_noPrivateStorage(t *testing.T) {
+	public := memory.NewMemoryStorage("reports")
+
+	lh := Lighthouse{
+		Process: Process{
+			Message: message.Message{Title: "Private", Visibility: PrivateVisibility},
+			Result:  &Result{"checksum": "5dc1fdd9c8a1bc2ac3a3e8b2ae1b4c0d0f1d4f3a2bbcd0c5b1b1d0d1e9c6e3f2"},
+		},
+		StorageProvider: public,
+	}
+
+	if err := lh.Do(); err == nil {
+		t.Errorf("Lighthouse.Do() error = nil, want an error without a private storage provider")
+	}
+	if _, err := lh.uploadToStorage([]byte(`{"lighthouse":true}`)); err == nil {
+		t.Errorf("Lighthouse.uploadToStorage() error = nil, want an error without a private storage provider")
+	}
+	if objects, _ := public.List(""); len(objects) != 0 {
+		t.Errorf("Lighthouse.uploadToStorage() stored %d reports of a private audit in the clear", len(objects))
+	}
+}
+
+func TestLighthouse_uploadToStorage_tempFile(t *testing.T) {
+	os.MkdirAll("./testdata/tmp", os.ModePerm)
+	os.MkdirAll("./testdata/upload", os.ModePerm)
+
+	// Clean up after.
+	defer func() {
+		os.RemoveAll("./testdata/tmp")
+		os.RemoveAll("./testdata/upload")
+	}()
+
+	checksum := "5dc1fdd9c8a1bc2ac3a3e8b2ae1b4c0d0f1d4f3a2bbcd0c5b1b1d0d1e9c6e3f2"
+	lh := Lighthouse{
+		Process: Process{
+			Result: &Result{"checksum": checksum},
+		},
+		TempFolder:      "./testdata/tmp",
+		StorageProvider: mockStorage{},
+	}
+
+	if _, err := lh.uploadToStorage([]byte(`{"lighthouse":true}`)); err != nil {
+		t.Fatalf("Lighthouse.uploadToStorage() error = %v", err)
+	}
+	if _, err := os.Stat("./testdata/upload/" + checksum + "-lighthouse-raw.json"); err != nil {
+		t.Errorf("Lighthouse.uploadToStorage() did not upload the report: %v", err)
+	}
+	if _, err := os.Stat("./testdata/tmp/" + checksum + "-lighthouse-raw.json"); !os.IsNotExist(err) {
+		t.Errorf("Lighthouse.uploadToStorage() left the report in the temp folder")
+	}
+}
+
+func TestLighthouse_uploadToStorage_encoding(t *testing.T) {
+	provider, _ := compress.NewProvider(memory.NewMemoryStorage("reports"), compress.Gzip)
+
+	lh := Lighthouse{
+		Process: Process{
+			Result: &Result{"checksum": "5dc1fdd9c8a1bc2ac3a3e8b2ae1b4c0d0f1d4f3a2bbcd0c5b1b1d0d1e9c6e3f2"},
+		},
+		StorageProvider: provider,
+	}
+
+	results, err := lh.uploadToStorage([]byte(`{"lighthouse":true}`))
+	if err != nil {
+		t.Fatalf("Lighthouse.uploadToStorage() error = %v", err)
+	}
+	if results.Raw.Encoding != compress.Gzip {
+		t.Errorf("Lighthouse.uploadToStorage() Raw.Encoding = %q, want %q", results.Raw.Encoding, compress.Gzip)
+	}
+}
@@ -48,3 +48,17 @@ func (m mockStorage) UploadFile(filename, reference string) error {
 func (m mockStorage) DownloadFile(reference, filename string) error {
 	return nil
 }
+
+// mockRecorder keeps the failures recorded by reference.
+type mockRecorder struct {
+	failures map[string]string
+	fail     bool
+}
+
+func (m *mockRecorder) FailMessage(ref *string, reason string) error {
+	if m.fail {
+		return errors.New("something went wrong")
+	}
+	m.failures[*ref] = reason
+	return nil
+}
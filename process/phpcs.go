@@ -1,6 +1,7 @@
 package process
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -23,12 +24,13 @@ var (
 
 // Phpcs defines the structure for our Phpcs process.
 type Phpcs struct {
-	Process                          // Inherits methods from Process.
-	In              <-chan Processor // Expects a processor channel as input.
-	Out             chan Processor   // Send results to an output channel.
-	Config          Result           // Additional config.
-	TempFolder      string           // Path to a temp folder where reports will be generated.
-	StorageProvider storage.Provider // Storage provider to upload reports to.
+	Process                                 // Inherits methods from Process.
+	In                     <-chan Processor // Expects a processor channel as input.
+	Out                    chan Processor   // Send results to an output channel.
+	Config                 Result           // Additional config.
+	TempFolder             string           // Path to a temp folder where reports will be generated.
+	StorageProvider        storage.Provider // Storage provider to upload reports to.
+	PrivateStorageProvider storage.Provider // (Optional) Storage provider for the reports of private audits, e.g. an encrypt.Provider.
 }
 
 // Run executes the process in a pipe.
@@ -59,6 +61,12 @@ func (cs *Phpcs) Run(errc *chan error) error {
 
 				result := *cs.Result
 
+				// Rejected sources have no files to audit.
+				if rejected(result) {
+					cs.Out <- cs
+					continue
+				}
+
 				// Run the process.
 				// If processing produces an error send it up the error channel.
 				for _, audit := range cs.Message.Audits {
@@ -67,7 +75,7 @@ func (cs *Phpcs) Run(errc *chan error) error {
 						cs.SetResults(&result)
 						if err := cs.Do(); err != nil {
 							// Pass the error up the error channel.
-							*errc <- errors.New("PHPCS Error: " + err.Error())
+							cs.sendError(errc, errors.New("PHPCS Error: "+err.Error()))
 							// Don't break, the message is still useful to other processes.
 						}
 					}
@@ -107,6 +115,12 @@ func (cs *Phpcs) Do() error {
 		return errors.New("could not determine standard for report")
 	}
 
+	// Don't run the audit if its reports can't be stored.
+	provider, err := cs.provider()
+	if err != nil {
+		return err
+	}
+
 	checksum, ok := result["checksum"].(string)
 	if !ok {
 		return errors.New("could not determine checksum")
@@ -149,11 +163,19 @@ func (cs *Phpcs) Do() error {
 		"--encoding=" + encoding,
 		"--basepath=" + path, // Remove this part from the filenames in PHPCS report.
 		"--report=json",
-		"--report-json=" + filepath,
-		"--parallel=" + strconv.Itoa(parallel),
+	}
+
+	// Providers that can stream take the report from stdout instead of a temp file.
+	streamer, streaming := provider.(storage.Streamer)
+	if !streaming {
+		cmdArgs = append(cmdArgs, "--report-json="+filepath)
+	}
+
+	cmdArgs = append(cmdArgs,
+		"--parallel="+strconv.Itoa(parallel),
 		"-d",              // Required to be before "memory_limit".
 		"memory_limit=-1", // Leave memory handling up to the system.
-	}
+	)
 
 	// @todo fix message to accept array of options.
 	//for _, pair := range audit.Options.RuntimeSet {
@@ -174,12 +196,24 @@ func (cs *Phpcs) Do() error {
 	if len(errorBytes) > 0 {
 		log.Log(cs.Message.Title, fmt.Sprintf("phpcs error:\n %s", strings.TrimSpace(string(errorBytes))))
 	}
-	log.Log(cs.Message.Title, fmt.Sprintf("phpcs output:\n %s", strings.TrimSpace(string(resultBytes))))
+	if !streaming {
+		log.Log(cs.Message.Title, fmt.Sprintf("phpcs output:\n %s", strings.TrimSpace(string(resultBytes))))
+	}
 
 	// We already have a reference to the report file, so lets upload and get the storage reference in a result.
 	log.Log(cs.Message.Title, "Uploading "+standard+" results to remote storage.")
 
-	fType, fFileName, fPath, err := cs.uploadToStorage(filepath, filename)
+	metadata := cs.storageMetadata(checksum, kind)
+	metadata[storage.MetaStandard] = standard
+
+	var report []byte
+	var fType, fFileName, fPath string
+	if streaming {
+		report = resultBytes
+		fType, fFileName, fPath, err = cs.putToStorage(streamer, report, filename, metadata)
+	} else {
+		fType, fFileName, fPath, err = cs.uploadToStorage(provider, filepath, filename, metadata)
+	}
 	if err != nil {
 		return err
 	}
@@ -190,14 +224,17 @@ func (cs *Phpcs) Do() error {
 			Type:     fType,
 			FileName: fFileName,
 			Path:     fPath,
+			Encoding: storage.Encoding(provider),
 		},
 	}
 
-	// `uploadToStorage` already did the error checking.
-	fileReader, _ := fileOpen(filepath)
-	defer fileReader.Close()
+	if !streaming {
+		// `uploadToStorage` already did the error checking.
+		fileReader, _ := fileOpen(filepath)
+		defer fileReader.Close()
 
-	report, _ := ioutil.ReadAll(fileReader)
+		report, _ = ioutil.ReadAll(fileReader)
+	}
 
 	var phpcsResults *tide.PhpcsResults
 	err = json.Unmarshal(report, &phpcsResults)
@@ -217,14 +254,20 @@ func (cs *Phpcs) Do() error {
 		resultsJSON, _ := json.Marshal(compatResults)
 
 		fname := checksum + "-" + kind + "-parsed.json"
-		fpath := pathPrefix + fname
 
-		err = writeFile(fpath, resultsJSON, os.ModePerm)
-		if err != nil {
-			return err
-		}
+		var fType, fFileName, fPath string
+		if streaming {
+			fType, fFileName, fPath, err = cs.putToStorage(streamer, resultsJSON, fname, metadata)
+		} else {
+			fpath := pathPrefix + fname
+
+			err = writeFile(fpath, resultsJSON, os.ModePerm)
+			if err != nil {
+				return err
+			}
 
-		fType, fFileName, fPath, err := cs.uploadToStorage(fpath, fname)
+			fType, fFileName, fPath, err = cs.uploadToStorage(provider, fpath, fname, metadata)
+		}
 		if err != nil {
 			return err
 		}
@@ -233,6 +276,7 @@ func (cs *Phpcs) Do() error {
 			Type:     fType,
 			FileName: fFileName,
 			Path:     fPath,
+			Encoding: storage.Encoding(provider),
 		}
 
 		auditResults.CompatibleVersions = compatibleVersions
@@ -249,13 +293,30 @@ func (cs *Phpcs) Do() error {
 	return nil
 }
 
-func (cs Phpcs) uploadToStorage(filepath, filename string) (fType, fFileName, fPath string, err error) {
-	err = cs.StorageProvider.UploadFile(filepath, filename)
+// provider returns the storage provider for the reports of the current message.
+func (cs Phpcs) provider() (storage.Provider, error) {
+	return cs.storageFor(cs.StorageProvider, cs.PrivateStorageProvider)
+}
+
+func (cs Phpcs) uploadToStorage(provider storage.Provider, filepath, filename string, metadata map[string]string) (fType, fFileName, fPath string, err error) {
+	err = storage.UploadFile(cs.contextOrBackground(), provider, filepath, filename, metadata)
+
+	if err == nil {
+		fType = provider.Kind()
+		fFileName = filename
+		fPath = provider.CollectionRef()
+	}
+
+	return fType, fFileName, fPath, err
+}
+
+func (cs Phpcs) putToStorage(streamer storage.Streamer, data []byte, filename string, metadata map[string]string) (fType, fFileName, fPath string, err error) {
+	err = streamer.Put(cs.contextOrBackground(), filename, bytes.NewReader(data), metadata)
 
 	if err == nil {
-		fType = cs.StorageProvider.Kind()
+		fType = streamer.Kind()
 		fFileName = filename
-		fPath = cs.StorageProvider.CollectionRef()
+		fPath = streamer.CollectionRef()
 	}
 
 	return fType, fFileName, fPath, err
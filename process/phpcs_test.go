@@ -6,6 +6,7 @@ import (
 	"errors"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -14,6 +15,8 @@ import (
 	"github.com/wptide/pkg/message"
 	"github.com/wptide/pkg/shell"
 	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/storage/memory"
+	"github.com/wptide/pkg/tide"
 )
 
 type mockPhpcsRunner struct{}
@@ -82,6 +85,16 @@ func (m mockPhpcsRunner) Run(name string, arg ...string) ([]byte, []byte, int, e
 		return []byte(msg), nil, 0, nil
 	}
 
+	if basepath == "./testdata/info/stream/unzipped" {
+		// Streaming providers read the report from stdout.
+		for _, a := range arg {
+			if strings.HasPrefix(a, "--report-json=") {
+				return nil, nil, 1, errors.New("unexpected report file")
+			}
+		}
+		return []byte(examplePhpcsPhpCompatibilityReport()), nil, 0, nil
+	}
+
 	return nil, nil, 1, errors.New("something went wrong")
 }
 
@@ -680,6 +693,91 @@ func TestPhpcs_Run(t *testing.T) {
 	}
 }
 
+func TestPhpcs_Do_streaming(t *testing.T) {
+	oldRunner := phpcsRunner
+	phpcsRunner = &mockPhpcsRunner{}
+	defer func() { phpcsRunner = oldRunner }()
+
+	provider := memory.NewMemoryStorage("reports")
+	checksum := "5dc1fdd9c8a1bc2ac3a3e8b2ae1b4c0d0f1d4f3a2bbcd0c5b1b1d0d1e9c6e3f2"
+
+	cs := &Phpcs{
+		Process: Process{
+			Message: message.Message{Title: "Streamed Phpcompat", Slug: "dummy-plugin"},
+			Result: &Result{
+				"checksum":  checksum,
+				"filesPath": "./testdata/info/stream",
+				"phpcsCurrentAudit": &message.Audit{
+					Type:    "phpcs",
+					Options: &message.AuditOption{Standard: "phpcompatibility"},
+				},
+			},
+		},
+		TempFolder:      "./testdata/missing",
+		StorageProvider: provider,
+	}
+
+	if err := cs.Do(); err != nil {
+		t.Fatalf("Phpcs.Do() error = %v", err)
+	}
+
+	auditResults, _ := (*cs.Result)["phpcs_phpcompatibility"].(tide.AuditResult)
+	if auditResults.Raw.Type != "memory" || auditResults.Raw.FileName != checksum+"-phpcs_phpcompatibility-raw.json" {
+		t.Errorf("Phpcs.Do() Raw = %+v, want the streamed report", auditResults.Raw)
+	}
+	if auditResults.Summary.PhpcsSummary == nil {
+		t.Errorf("Phpcs.Do() Summary = %+v, want a summary of the report", auditResults.Summary)
+	}
+
+	wantMetadata := map[string]string{
+		storage.MetaSlug:     "dummy-plugin",
+		storage.MetaChecksum: checksum,
+		storage.MetaAudit:    "phpcs_phpcompatibility",
+		storage.MetaStandard: "phpcompatibility",
+	}
+	for _, reference := range []string{auditResults.Raw.FileName, auditResults.Parsed.FileName} {
+		info, err := provider.Stat(reference)
+		if err != nil {
+			t.Errorf("Phpcs.Do() did not store %q", reference)
+			continue
+		}
+		if info.ContentType != "application/json" || !reflect.DeepEqual(info.Metadata, wantMetadata) {
+			t.Errorf("Phpcs.Do() stored %q with %q %v, want application/json %v", reference, info.ContentType, info.Metadata, wantMetadata)
+		}
+	}
+}
+
+func TestPhpcs_Do_noPrivateStorage(t *testing.T) {
+	oldRunner := phpcsRunner
+	phpcsRunner = &mockPhpcsRunner{}
+	defer func() { phpcsRunner = oldRunner }()
+
+	provider := memory.NewMemoryStorage("reports")
+
+	cs := &Phpcs{
+		Process: Process{
+			Message: message.Message{Title: "Private Phpcompat", Slug: "dummy-plugin", Visibility: PrivateVisibility},
+			Result: &Result{
+				"checksum":  "5dc1fdd9c8a1bc2ac3a3e8b2ae1b4c0d0f1d4f3a2bbcd0c5b1b1d0d1e9c6e3f2",
+				"filesPath": "./testdata/info/stream",
+				"phpcsCurrentAudit": &message.Audit{
+					Type:    "phpcs",
+					Options: &message.AuditOption{Standard: "phpcompatibility"},
+				},
+			},
+		},
+		TempFolder:      "./testdata/missing",
+		StorageProvider: provider,
+	}
+
+	if err := cs.Do(); err == nil {
+		t.Errorf("Phpcs.Do() error = nil, want an error without a private storage provider")
+	}
+	if objects, _ := provider.List(""); len(objects) != 0 {
+		t.Errorf("Phpcs.Do() stored %d reports of a private audit in the clear", len(objects))
+	}
+}
+
 func examplePhpcsWordPressReport() string {
 	return `{"totals":{"errors":19,"warnings":0,"fixable":12},"files":{"dummy-plugin.php":{"errors":19,"warnings":0,"messages":[{"message":"Class file names should be based on the class name with \"class-\" prepended. Expected class-hello.php, but found dummy-plugin.php.","source":"WordPress.Files.FileName.InvalidClassFileName","severity":5,"type":"ERROR","line":1,"column":1,"fixable":false},{"message":"You must use \"\/**\" style comments for a class comment","source":"Squiz.Commenting.ClassComment.WrongStyle","severity":5,"type":"ERROR","line":35,"column":1,"fixable":false},{"message":"You must use \"\/**\" style comments for a member variable comment","source":"Squiz.Commenting.VariableComment.WrongStyle","severity":5,"type":"ERROR","line":38,"column":13,"fixable":false},{"message":"Tabs must be used to indent lines; spaces are not allowed","source":"Generic.WhiteSpace.DisallowSpaceIndent.SpacesUsed","severity":5,"type":"ERROR","line":40,"column":1,"fixable":true},{"message":"No space after opening parenthesis is prohibited","source":"WordPress.WhiteSpace.ControlStructureSpacing.NoSpaceAfterOpenParenthesis","severity":5,"type":"ERROR","line":41,"column":12,"fixable":true},{"message":"You must use \"\/**\" style comments for a function comment","source":"Squiz.Commenting.FunctionComment.WrongStyle","severity":5,"type":"ERROR","line":41,"column":12,"fixable":false},{"message":"Expected 1 spaces between opening bracket and argument \"$addressee\"; 0 found","source":"Squiz.Functions.FunctionDeclarationArgumentSpacing.SpacingAfterOpen","severity":5,"type":"ERROR","line":41,"column":33,"fixable":true},{"message":"String \"World\" does not require double quotes; use single quotes instead","source":"Squiz.Strings.DoubleQuoteUsage.NotRequired","severity":5,"type":"ERROR","line":41,"column":46,"fixable":true},{"message":"No space before closing parenthesis is prohibited","source":"WordPress.WhiteSpace.ControlStructureSpacing.NoSpaceBeforeCloseParenthesis","severity":5,"type":"ERROR","line":41,"column":53,"fixable":true},{"message":"PHP syntax error: syntax error, unexpected '='","source":"Generic.PHP.Syntax.PHPSyntax","severity":5,"type":"ERROR","line":42,"column":1,"fixable":false},{"message":"Expected 1 space before \"-\"; 0 found","source":"WordPress.WhiteSpace.OperatorSpacing.NoSpaceBefore","severity":5,"type":"ERROR","line":42,"column":14,"fixable":true},{"message":"Expected 1 space after \"-\"; 0 found","source":"WordPress.WhiteSpace.OperatorSpacing.NoSpaceAfter","severity":5,"type":"ERROR","line":42,"column":14,"fixable":true},{"message":"You must use \"\/**\" style comments for a function comment","source":"Squiz.Commenting.FunctionComment.WrongStyle","severity":5,"type":"ERROR","line":46,"column":12,"fixable":false},{"message":"String \"Hello \" does not require double quotes; use single quotes instead","source":"Squiz.Strings.DoubleQuoteUsage.NotRequired","severity":5,"type":"ERROR","line":47,"column":14,"fixable":true},{"message":"Expected next thing to be an escaping function (see Codex for 'Data Validation'), not '$this'","source":"WordPress.XSS.EscapeOutput.OutputNotEscaped","severity":5,"type":"ERROR","line":47,"column":25,"fixable":false},{"message":"Expected 1 spaces after opening bracket; 0 found","source":"PEAR.Functions.FunctionCallSignature.SpaceAfterOpenBracket","severity":5,"type":"ERROR","line":53,"column":16,"fixable":true},{"message":"Expected 1 spaces before closing bracket; 0 found","source":"PEAR.Functions.FunctionCallSignature.SpaceBeforeCloseBracket","severity":5,"type":"ERROR","line":53,"column":16,"fixable":true},{"message":"String \"Mundo\" does not require double quotes; use single quotes instead","source":"Squiz.Strings.DoubleQuoteUsage.NotRequired","severity":5,"type":"ERROR","line":53,"column":22,"fixable":true},{"message":"File must end with a newline character","source":"Generic.Files.EndFileNewline.NotFound","severity":5,"type":"ERROR","line":55,"column":18,"fixable":true}]}}}`
 }
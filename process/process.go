@@ -9,7 +9,9 @@ import (
 	"os"
 	"os/exec"
 
+	"github.com/wptide/pkg/log"
 	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/storage"
 )
 
 var (
@@ -23,15 +25,19 @@ var (
 	fileOpen = os.Open
 )
 
+// PrivateVisibility is the Message.Visibility of audits whose reports must not be stored in the clear.
+const PrivateVisibility = "private"
+
 // Result is an interface map of the processed results.
 type Result map[string]interface{}
 
 // Process is the base for all processes.
 type Process struct {
-	context   context.Context
-	Message   message.Message // Keeps track of the original message.
-	Result    *Result         // Passes along a Result object.
-	FilesPath string          // Path of files to audit.
+	context         context.Context
+	Message         message.Message         // Keeps track of the original message.
+	Result          *Result                 // Passes along a Result object.
+	FilesPath       string                  // Path of files to audit.
+	FailureRecorder message.FailureRecorder // (Optional) Records the errors of the process on the queue message.
 }
 
 // Run is a default implementation with an error nag. Not required, but serves as an example.
@@ -45,11 +51,62 @@ func (p *Process) SetContext(ctx context.Context) {
 	p.context = ctx
 }
 
+// contextOrBackground returns the process context, or an empty context if none was set.
+func (p Process) contextOrBackground() context.Context {
+	if p.context == nil {
+		return context.Background()
+	}
+	return p.context
+}
+
+// storageFor returns the private provider for messages with a private visibility, and the provider otherwise.
+// Private audits without a private provider are an error, so that their files are never stored in the clear.
+func (p Process) storageFor(provider, private storage.Provider) (storage.Provider, error) {
+	if p.Message.Visibility != PrivateVisibility {
+		return provider, nil
+	}
+	if private == nil {
+		return nil, p.Error("no private storage provider for a private audit")
+	}
+	return private, nil
+}
+
+// storageMetadata returns the metadata stored with the JSON reports of an audit, used to apply retention policies.
+func (p Process) storageMetadata(checksum, audit string) map[string]string {
+	return map[string]string{
+		storage.ContentType:  "application/json",
+		storage.MetaSlug:     p.Message.Slug,
+		storage.MetaChecksum: checksum,
+		storage.MetaAudit:    audit,
+	}
+}
+
 // Error returns a new process error.
 func (p Process) Error(msg string) error {
 	return errors.New(p.Message.Title + ": " + msg)
 }
 
+// RecordFailure records the reason on the queue message of the process, so that it is kept with the
+// message if the message ends up dead.
+func (p Process) RecordFailure(recorder message.FailureRecorder, reason string) error {
+	if p.Message.ExternalRef == nil {
+		return p.Error("no queue message reference")
+	}
+	return recorder.FailMessage(p.Message.ExternalRef, reason)
+}
+
+// sendError passes the error up the error channel, and records it on the queue message if there is a FailureRecorder.
+func (p Process) sendError(errc *chan error, err error) {
+	*errc <- err
+
+	if p.FailureRecorder == nil || p.Message.ExternalRef == nil {
+		return
+	}
+	if recordErr := p.RecordFailure(p.FailureRecorder, err.Error()); recordErr != nil {
+		log.Log(p.Message.Title, "could not record failure: "+recordErr.Error())
+	}
+}
+
 // SetMessage is used to set the Message for this process (used for copying the message).
 func (p *Process) SetMessage(msg message.Message) {
 	p.Message = msg
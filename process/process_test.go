@@ -1,11 +1,17 @@
 package process
 
 import (
+	"bytes"
 	"context"
+	"os"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/wptide/pkg/log"
 	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/message/memory"
+	"github.com/wptide/pkg/payload"
 )
 
 func generateProcs(ctx context.Context, procs []Processor) <-chan Processor {
@@ -58,3 +64,151 @@ func TestProcess_Run(t *testing.T) {
 		})
 	}
 }
+
+func TestProcess_RecordFailure(t *testing.T) {
+	ref := "abc123"
+
+	tests := []struct {
+		name    string
+		msg     message.Message
+		fail    bool
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			"Record Failure",
+			message.Message{Title: "Test Plugin", ExternalRef: &ref},
+			false,
+			map[string]string{"abc123": "PHPCS Error: something went wrong"},
+			false,
+		},
+		{
+			"No Reference",
+			message.Message{Title: "Test Plugin"},
+			false,
+			map[string]string{},
+			true,
+		},
+		{
+			"Recorder Error",
+			message.Message{Title: "Test Plugin", ExternalRef: &ref},
+			true,
+			map[string]string{},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Process{Message: tt.msg}
+			recorder := &mockRecorder{failures: make(map[string]string), fail: tt.fail}
+
+			if err := p.RecordFailure(recorder, "PHPCS Error: something went wrong"); (err != nil) != tt.wantErr {
+				t.Errorf("Process.RecordFailure() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(recorder.failures, tt.want) {
+				t.Errorf("Process.RecordFailure() recorded %v, want %v", recorder.failures, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcess_sendError(t *testing.T) {
+
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	// Make a /tmp folder
+	os.Mkdir("./testdata/tmp", os.ModePerm)
+
+	// Clean up after.
+	defer func() {
+		os.RemoveAll("./testdata/tmp")
+	}()
+
+	tests := []struct {
+		name       string
+		msg        message.Message
+		wantReason string
+	}{
+		{
+			"Ingest Error",
+			message.Message{
+				Title:               "Invalid Source",
+				ResponseAPIEndpoint: "http://test.local/api/audits",
+				SourceURL:           "http://test.local/test.rar",
+				SourceType:          "rar",
+				PayloadType:         "mock",
+			},
+			`Ingest Error: Invalid Source: could not find a source for "http://test.local/test.rar" (type "rar"), supported kinds: fake, fake-vcs, fake-vcs-files, git, local, svn, tar, wporg, zip`,
+		},
+		{
+			"Response Error",
+			message.Message{
+				Title:               "Send Fail",
+				ResponseAPIEndpoint: "http://test.local/sendfail",
+				SourceURL:           ts.URL + "/repo.git",
+				SourceType:          "fake-vcs",
+				PayloadType:         "mock",
+			},
+			"Response Error: something went wrong",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A single attempt, so that the message is dead once its lock expires.
+			queue := memory.NewWithOptions(10*time.Millisecond, 1)
+			msg := tt.msg
+			if err := queue.SendMessage(&msg); err != nil {
+				t.Fatal(err)
+			}
+			received, err := queue.GetNextMessage()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			in := make(chan message.Message, 1)
+			in <- *received
+
+			ingest := &Ingest{
+				Process:    Process{FailureRecorder: queue},
+				In:         in,
+				Out:        make(chan Processor),
+				TempFolder: "./testdata/tmp",
+			}
+			response := &Response{
+				Process: Process{FailureRecorder: queue},
+				In:      ingest.Out,
+				Payloaders: map[string]payload.Payloader{
+					"mock": MockPayloader{},
+				},
+			}
+
+			errc := make(chan error, 1)
+			for _, proc := range []Processor{ingest, response} {
+				if err := proc.Run(&errc); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			select {
+			case <-errc:
+			case <-time.After(time.Second):
+				t.Fatalf("the pipeline did not report an error")
+			}
+
+			time.Sleep(20 * time.Millisecond)
+
+			dead, err := queue.DeadMessages()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(dead) != 1 {
+				t.Fatalf("DeadMessages() = %d messages, want 1", len(dead))
+			}
+			if dead[0].LastError != tt.wantReason {
+				t.Errorf("DeadMessages() LastError = %v, want %v", dead[0].LastError, tt.wantReason)
+			}
+		})
+	}
+}
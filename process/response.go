@@ -3,17 +3,30 @@ package process
 import (
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/wptide/pkg/log"
 	"github.com/wptide/pkg/payload"
+	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/tide"
 )
 
+// DefaultLinkTTL is how long download links in payloads work if Response.LinkTTL is not set.
+const DefaultLinkTTL = 24 * time.Hour
+
+// Using a variable so that we can mock it in tests.
+var now = time.Now
+
 // Response defines the structure for a Response process.
 // This determines where the processed results will be sent.
+// As the last process to need the files, it releases the cached source of the item.
 type Response struct {
-	Process                                 // Inherits methods from Process.
-	In         <-chan Processor             // Expects a processor channel as input.
-	Out        chan Processor               // (Optional) Send results to an output channel.
-	Payloaders map[string]payload.Payloader // A map of "Payloader"s for different services.
+	Process                                      // Inherits methods from Process.
+	In              <-chan Processor             // Expects a processor channel as input.
+	Out             chan Processor               // (Optional) Send results to an output channel.
+	Payloaders      map[string]payload.Payloader // A map of "Payloader"s for different services.
+	StorageProvider storage.Provider             // (Optional) Adds download links to reports if it is a storage.Signer.
+	LinkTTL         time.Duration                // (Optional) How long download links work, DefaultLinkTTL if not set.
 }
 
 // Run executes the process in a pipe.
@@ -39,7 +52,7 @@ func (res *Response) Run(errc *chan error) error {
 				// If processing produces an error send it up the error channel.
 				if err := res.Do(); err != nil {
 					// Pass the error up the error channel.
-					*errc <- errors.New("Response Error: " + err.Error())
+					res.sendError(errc, errors.New("Response Error: "+err.Error()))
 					// Don't break, the message is still useful to other processes.
 				}
 
@@ -60,6 +73,9 @@ func (res *Response) Do() error {
 
 	result := *res.Result
 
+	// The item is done with the files once the response is sent (or failed).
+	defer releaseSource(result)
+
 	payloadType := res.Message.PayloadType
 	if payloadType == "" {
 		// This is temporary, in future there will be no fallback.
@@ -73,6 +89,8 @@ func (res *Response) Do() error {
 		return errors.New("Could not find a valid payload generator for task")
 	}
 
+	res.signLinks(result)
+
 	p, err := payloader.BuildPayload(res.Message, result)
 	if err != nil {
 		return err
@@ -91,3 +109,52 @@ func (res *Response) Do() error {
 
 	return nil
 }
+
+// signLinks adds expiring download links to the stored reports in the result.
+// Reports of private audits are not linked.
+func (res *Response) signLinks(result Result) {
+	if res.Message.Visibility == PrivateVisibility {
+		return
+	}
+
+	signer, ok := res.StorageProvider.(storage.Signer)
+	if !ok {
+		return
+	}
+
+	ttl := res.LinkTTL
+	if ttl <= 0 {
+		ttl = DefaultLinkTTL
+	}
+
+	for key, value := range result {
+		switch v := value.(type) {
+		case tide.AuditResult:
+			v.Raw = res.signDetails(signer, v.Raw, ttl)
+			v.Parsed = res.signDetails(signer, v.Parsed, ttl)
+			result[key] = v
+		case tide.AuditDetails:
+			result[key] = res.signDetails(signer, v, ttl)
+		}
+	}
+}
+
+// signDetails adds a link to a file stored with the signer.
+func (res *Response) signDetails(signer storage.Signer, details tide.AuditDetails, ttl time.Duration) tide.AuditDetails {
+	if details.FileName == "" || details.Type != signer.Kind() || details.Path != signer.CollectionRef() {
+		return details
+	}
+
+	expires := now().Add(ttl)
+	link, err := signer.SignedURL(details.FileName, ttl)
+	if err != nil {
+		// The payload is still useful without the link.
+		log.Log(res.Message.Title, "could not sign a link to "+details.FileName+": "+err.Error())
+		return details
+	}
+
+	details.URL = link
+	details.Expires = expires.Unix()
+
+	return details
+}
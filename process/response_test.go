@@ -5,12 +5,15 @@ import (
 	"context"
 	"errors"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/wptide/pkg/log"
 	"github.com/wptide/pkg/message"
 	"github.com/wptide/pkg/payload"
+	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/tide"
 )
 
 type MockPayloader struct{}
@@ -231,3 +234,173 @@ func TestResponse_Run(t *testing.T) {
 		})
 	}
 }
+
+// signingStorage signs links for a mock storage.
+type signingStorage struct {
+	mockStorage
+}
+
+func (s signingStorage) SignedURL(reference string, ttl time.Duration) (string, error) {
+	if reference == "fail.json" {
+		return "", errors.New("something went wrong")
+	}
+	return "https://links.local/" + reference + "?ttl=" + ttl.String(), nil
+}
+
+func TestResponse_Do_releasesFiles(t *testing.T) {
+
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	tests := []struct {
+		name     string
+		endpoint string
+		wantErr  bool
+	}{
+		{
+			"Payload Sent",
+			"http://test.local/api/audits",
+			false,
+		},
+		{
+			"Payload Send Fail",
+			"http://test.local/sendfail",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "./testdata/tmp/audit-response"
+			os.MkdirAll(path+"/unzipped", os.ModePerm)
+			defer os.RemoveAll("./testdata/tmp")
+
+			res := &Response{
+				Payloaders: map[string]payload.Payloader{
+					"mock": MockPayloader{},
+				},
+			}
+			res.Message = message.Message{
+				Title:               "Test",
+				PayloadType:         "mock",
+				ResponseAPIEndpoint: tt.endpoint,
+			}
+			res.Result = &Result{tempFilesKey: path}
+
+			if err := res.Do(); (err != nil) != tt.wantErr {
+				t.Errorf("Response.Do() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("Response.Do() did not remove %v", path)
+			}
+		})
+	}
+}
+
+func TestResponse_signLinks(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	oldNow := now
+	now = func() time.Time { return time.Unix(1500000000, 0) }
+	defer func() { now = oldNow }()
+
+	stored := func(filename string) tide.AuditDetails {
+		return tide.AuditDetails{Type: "mock", FileName: filename, Path: "mock-collection"}
+	}
+	linked := func(filename string, ttl time.Duration) tide.AuditDetails {
+		details := stored(filename)
+		details.URL = "https://links.local/" + filename + "?ttl=" + ttl.String()
+		details.Expires = 1500000000 + int64(ttl.Seconds())
+		return details
+	}
+
+	result := func() Result {
+		return Result{
+			"phpcs_wordpress": tide.AuditResult{Raw: stored("raw.json")},
+			"phpcs_phpcompatibility": tide.AuditResult{
+				Raw:    stored("fail.json"),
+				Parsed: stored("parsed.json"),
+			},
+			"lighthouse":   tide.AuditResult{Raw: tide.AuditDetails{Type: "s3", FileName: "lh.json", Path: "bucket"}},
+			"manifestFile": stored("manifest.json"),
+			"checksum":     "abc",
+		}
+	}
+
+	tests := []struct {
+		name       string
+		provider   storage.Provider
+		ttl        time.Duration
+		visibility string
+		want       Result
+	}{
+		{
+			"Signing Provider",
+			signingStorage{},
+			time.Hour,
+			"public",
+			Result{
+				"phpcs_wordpress": tide.AuditResult{Raw: linked("raw.json", time.Hour)},
+				"phpcs_phpcompatibility": tide.AuditResult{
+					Raw:    stored("fail.json"),
+					Parsed: linked("parsed.json", time.Hour),
+				},
+				"lighthouse":   tide.AuditResult{Raw: tide.AuditDetails{Type: "s3", FileName: "lh.json", Path: "bucket"}},
+				"manifestFile": linked("manifest.json", time.Hour),
+				"checksum":     "abc",
+			},
+		},
+		{
+			"Default TTL",
+			signingStorage{},
+			0,
+			"",
+			Result{
+				"phpcs_wordpress": tide.AuditResult{Raw: linked("raw.json", DefaultLinkTTL)},
+				"phpcs_phpcompatibility": tide.AuditResult{
+					Raw:    stored("fail.json"),
+					Parsed: linked("parsed.json", DefaultLinkTTL),
+				},
+				"lighthouse":   tide.AuditResult{Raw: tide.AuditDetails{Type: "s3", FileName: "lh.json", Path: "bucket"}},
+				"manifestFile": linked("manifest.json", DefaultLinkTTL),
+				"checksum":     "abc",
+			},
+		},
+		{
+			"Provider Without Links",
+			mockStorage{},
+			time.Hour,
+			"public",
+			result(),
+		},
+		{
+			"No Provider",
+			nil,
+			time.Hour,
+			"public",
+			result(),
+		},
+		{
+			"Private Audit",
+			signingStorage{},
+			time.Hour,
+			PrivateVisibility,
+			result(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &Response{StorageProvider: tt.provider, LinkTTL: tt.ttl}
+			res.Message.Visibility = tt.visibility
+
+			got := result()
+			res.signLinks(got)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Response.signLinks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
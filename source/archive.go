@@ -0,0 +1,216 @@
+package source
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Violations reported by ArchiveError.
+const (
+	ViolationPathTraversal = "path_traversal"
+	ViolationMaxBytes      = "max_bytes"
+	ViolationMaxFiles      = "max_files"
+	ViolationMaxRatio      = "max_ratio"
+	ViolationMaxDepth      = "max_depth"
+	ViolationSymlink       = "symlink"
+)
+
+// SymlinkPolicy decides what happens to symlinks in an archive.
+type SymlinkPolicy int
+
+// Symlink policies.
+const (
+	SymlinkSkip    SymlinkPolicy = iota // Leave symlinks out of the extracted files.
+	SymlinkReject                       // Reject the archive.
+	SymlinkExtract                      // Create symlinks that point inside the destination.
+)
+
+// Limits restricts what an archive may contain. A zero value disables a limit.
+type Limits struct {
+	MaxBytes int64         // Maximum total uncompressed size of all files.
+	MaxFiles int           // Maximum number of files.
+	MaxRatio float64       // Maximum uncompressed to compressed size of a single file, or of all files of a compressed stream.
+	MaxDepth int           // Maximum number of folders in a path.
+	Symlinks SymlinkPolicy // What to do with symlinks.
+}
+
+// Limiter is implemented by sources that can use other Limits than DefaultLimits.
+type Limiter interface {
+	SetLimits(limits Limits)
+}
+
+var (
+	// DefaultLimits are used by archive sources unless other limits are given.
+	DefaultLimits = Limits{
+		MaxBytes: 512 << 20,
+		MaxFiles: 20000,
+		MaxRatio: 200,
+		MaxDepth: 32,
+		Symlinks: SymlinkSkip,
+	}
+
+	// Files smaller than this are not checked against MaxRatio,
+	// small text files can legitimately compress very well.
+	ratioThreshold int64 = 1 << 20
+)
+
+// ArchiveError is returned when an archive is rejected for breaking Limits or for unsafe entries.
+type ArchiveError struct {
+	Violation string // One of the Violation constants.
+	Entry     string // Name of the offending entry in the archive.
+	Detail    string
+}
+
+// Error implements the error interface.
+func (e *ArchiveError) Error() string {
+	msg := "archive rejected (" + e.Violation + "): " + e.Entry
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+// Extraction checks the entries of an archive against Limits while it gets extracted.
+type Extraction struct {
+	Limits      Limits
+	Destination string
+	files       int
+	bytes       int64
+	compressed  int64 // Bytes read from the Stream.
+}
+
+// NewExtraction returns an Extraction of an archive into destination.
+func NewExtraction(destination string, limits Limits) *Extraction {
+	return &Extraction{
+		Limits:      limits,
+		Destination: destination,
+	}
+}
+
+// Path returns where an entry (relative to the archive root) will be extracted.
+// It rejects absolute paths, paths that escape the destination and paths that are too deep.
+func (x *Extraction) Path(name string) (string, error) {
+	clean := filepath.Clean("/" + filepath.FromSlash(strings.Replace(name, `\`, "/", -1)))
+	if isAbs(name) || hasParentRef(name) {
+		return "", &ArchiveError{ViolationPathTraversal, name, "path leaves the destination"}
+	}
+
+	depth := strings.Count(clean, string(filepath.Separator)) - 1
+	if x.Limits.MaxDepth > 0 && depth > x.Limits.MaxDepth {
+		return "", &ArchiveError{ViolationMaxDepth, name, fmt.Sprintf("more than %d folders deep", x.Limits.MaxDepth)}
+	}
+
+	return filepath.Join(x.Destination, clean), nil
+}
+
+// File registers a file entry and checks the number of files.
+func (x *Extraction) File(name string) error {
+	x.files++
+	if x.Limits.MaxFiles > 0 && x.files > x.Limits.MaxFiles {
+		return &ArchiveError{ViolationMaxFiles, name, fmt.Sprintf("more than %d files", x.Limits.MaxFiles)}
+	}
+	return nil
+}
+
+// Symlink decides whether a symlink entry at path (as returned by Path) pointing
+// to target should be created. It returns false if the symlink should be skipped.
+func (x *Extraction) Symlink(name, path, target string) (bool, error) {
+	switch x.Limits.Symlinks {
+	case SymlinkReject:
+		return false, &ArchiveError{ViolationSymlink, name, "symlinks are not allowed"}
+	case SymlinkExtract:
+		resolved := filepath.Join(filepath.Dir(path), target)
+		if filepath.IsAbs(target) || !within(x.Destination, resolved) {
+			return false, &ArchiveError{ViolationSymlink, name, "symlink points outside the destination"}
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// Reader wraps the content of a file entry so that reading it fails once the
+// file breaks MaxRatio or all files together break MaxBytes. Sizes in archive
+// headers can't be trusted, so the limits are applied to what actually gets read.
+// Pass a compressed size of 0 if it is not known, MaxRatio then applies to all
+// files together against the bytes read from the Stream.
+func (x *Extraction) Reader(name string, r io.Reader, compressed int64) io.Reader {
+	return &limitedReader{
+		x:          x,
+		name:       name,
+		r:          r,
+		compressed: compressed,
+	}
+}
+
+// Stream wraps the compressed data of an archive whose entries have no compressed
+// size of their own, like a tar.gz, and counts the bytes read from it.
+func (x *Extraction) Stream(r io.Reader) io.Reader {
+	return &countingReader{x: x, r: r}
+}
+
+type countingReader struct {
+	x *Extraction
+	r io.Reader
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.x.compressed += int64(n)
+	return n, err
+}
+
+type limitedReader struct {
+	x          *Extraction
+	name       string
+	r          io.Reader
+	compressed int64
+	read       int64
+}
+
+// Read returns no data with a violation, io.ReadFull and io.CopyN drop errors that come with all the bytes they asked for.
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	l.x.bytes += int64(n)
+
+	limits := l.x.Limits
+	if limits.MaxBytes > 0 && l.x.bytes > limits.MaxBytes {
+		return 0, &ArchiveError{ViolationMaxBytes, l.name, fmt.Sprintf("more than %d bytes uncompressed", limits.MaxBytes)}
+	}
+
+	read, compressed := l.read, l.compressed
+	if compressed == 0 {
+		read, compressed = l.x.bytes, l.x.compressed
+	}
+	if limits.MaxRatio > 0 && compressed > 0 && read > ratioThreshold &&
+		float64(read)/float64(compressed) > limits.MaxRatio {
+		return 0, &ArchiveError{ViolationMaxRatio, l.name, fmt.Sprintf("compression ratio above %.0f", limits.MaxRatio)}
+	}
+
+	return n, err
+}
+
+// hasParentRef reports whether a slash or backslash separated path contains "..".
+func hasParentRef(name string) bool {
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if part == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// isAbs reports whether an entry name is absolute on any platform.
+func isAbs(name string) bool {
+	return strings.HasPrefix(name, "/") || strings.HasPrefix(name, `\`) ||
+		(len(name) > 1 && name[1] == ':')
+}
+
+// within reports whether path is inside root.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
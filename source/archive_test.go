@@ -0,0 +1,142 @@
+package source
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestExtraction_Path(t *testing.T) {
+	tests := []struct {
+		name          string
+		limits        Limits
+		entry         string
+		want          string
+		wantViolation string
+	}{
+		{"Simple", DefaultLimits, "plugin.php", "/dest/plugin.php", ""},
+		{"Nested", DefaultLimits, "inc/admin/plugin.php", "/dest/inc/admin/plugin.php", ""},
+		{"Root Folder", DefaultLimits, "", "/dest", ""},
+		{"Dot Segments", DefaultLimits, "./inc/./plugin.php", "/dest/inc/plugin.php", ""},
+		{"Parent", DefaultLimits, "../plugin.php", "", ViolationPathTraversal},
+		{"Nested Parent", DefaultLimits, "inc/../../plugin.php", "", ViolationPathTraversal},
+		{"Backslash Parent", DefaultLimits, `inc\..\..\plugin.php`, "", ViolationPathTraversal},
+		{"Absolute", DefaultLimits, "/etc/passwd", "", ViolationPathTraversal},
+		{"Windows Absolute", DefaultLimits, `C:\Windows\evil.dll`, "", ViolationPathTraversal},
+		{"Depth Allowed", Limits{MaxDepth: 2}, "a/b/plugin.php", "/dest/a/b/plugin.php", ""},
+		{"Too Deep", Limits{MaxDepth: 2}, "a/b/c/plugin.php", "", ViolationMaxDepth},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x := NewExtraction("/dest", tt.limits)
+			got, err := x.Path(tt.entry)
+			if tt.wantViolation != "" {
+				if e, ok := err.(*ArchiveError); !ok || e.Violation != tt.wantViolation {
+					t.Errorf("Extraction.Path() error = %v, want violation %v", err, tt.wantViolation)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Extraction.Path() error = %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Extraction.Path() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtraction_Reader(t *testing.T) {
+
+	oldThreshold := ratioThreshold
+	ratioThreshold = 10
+	defer func() {
+		ratioThreshold = oldThreshold
+	}()
+
+	tests := []struct {
+		name          string
+		limits        Limits
+		size          int
+		compressed    int64
+		wantViolation string
+	}{
+		{"Within Limits", Limits{MaxBytes: 100, MaxRatio: 10}, 50, 10, ""},
+		{"Too Many Bytes", Limits{MaxBytes: 100}, 101, 0, ViolationMaxBytes},
+		{"Ratio", Limits{MaxRatio: 10}, 200, 10, ViolationMaxRatio},
+		{"Ratio Unknown Compressed Size", Limits{MaxRatio: 10}, 200, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x := NewExtraction("/dest", tt.limits)
+			r := x.Reader("file.php", bytes.NewReader(make([]byte, tt.size)), tt.compressed)
+			_, err := ioutil.ReadAll(r)
+			if tt.wantViolation == "" {
+				if err != nil {
+					t.Errorf("Extraction.Reader() error = %v", err)
+				}
+				return
+			}
+			if e, ok := err.(*ArchiveError); !ok || e.Violation != tt.wantViolation {
+				t.Errorf("Extraction.Reader() error = %v, want violation %v", err, tt.wantViolation)
+			}
+		})
+	}
+}
+
+func TestExtraction_Stream(t *testing.T) {
+
+	oldThreshold := ratioThreshold
+	ratioThreshold = 10
+	defer func() {
+		ratioThreshold = oldThreshold
+	}()
+
+	tests := []struct {
+		name          string
+		streamed      int
+		size          int
+		wantViolation string
+	}{
+		{"Within Ratio", 50, 200, ""},
+		{"Ratio", 10, 200, ViolationMaxRatio},
+		{"No Stream", 0, 200, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x := NewExtraction("/dest", Limits{MaxRatio: 10})
+			ioutil.ReadAll(x.Stream(bytes.NewReader(make([]byte, tt.streamed))))
+
+			// The entries of the stream have no compressed size of their own.
+			_, err := ioutil.ReadAll(x.Reader("file.php", bytes.NewReader(make([]byte, tt.size)), 0))
+			if tt.wantViolation == "" {
+				if err != nil {
+					t.Errorf("Extraction.Reader() error = %v", err)
+				}
+				return
+			}
+			if e, ok := err.(*ArchiveError); !ok || e.Violation != tt.wantViolation {
+				t.Errorf("Extraction.Reader() error = %v, want violation %v", err, tt.wantViolation)
+			}
+		})
+	}
+}
+
+func TestExtraction_File(t *testing.T) {
+	x := NewExtraction("/dest", Limits{MaxFiles: 2})
+	for i, want := range []bool{false, false, true} {
+		err := x.File("file.php")
+		if (err != nil) != want {
+			t.Errorf("Extraction.File() call %d error = %v, wantErr %v", i+1, err, want)
+		}
+	}
+}
+
+func TestArchiveError_Error(t *testing.T) {
+	err := &ArchiveError{ViolationPathTraversal, "../evil.php", "path leaves the destination"}
+	want := "archive rejected (path_traversal): ../evil.php: path leaves the destination"
+	if got := err.Error(); got != want {
+		t.Errorf("ArchiveError.Error() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,317 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache keeps prepared sources on disk so that messages for the same code
+// don't download and extract it again. A cached tree is reused when the url
+// and its ETag match a previous download, or when a fresh download has the
+// same checksum as a tree that is already cached.
+//
+// Trees that are not in use are evicted, least recently used first, once the
+// cache holds more than MaxBytes or MaxEntries.
+type Cache struct {
+	root       string
+	maxBytes   int64
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*CacheEntry // By checksum.
+	urls    map[string]*CacheEntry // By url.
+	size    int64
+}
+
+// CacheEntry is a prepared source in the cache.
+type CacheEntry struct {
+	Checksum string   // Combined checksum of the files.
+	Path     string   // Folder containing the "unzipped" files.
+	Files    []string // Files as returned by Source.GetFiles, moved into Path.
+	Manifest Manifest // Details of the files.
+	Size     int64    // Bytes used on disk.
+	URL      string   // Url the files were last prepared from.
+	ETag     string   // ETag of the url, if the server sent one.
+	Revision string   // Revision prepared from the url, for version control sources. Use GetRevision to read it.
+
+	cache    *Cache
+	refs     int
+	lastUsed time.Time
+}
+
+var (
+	// Using a variable so that we can mock it in tests.
+	headETag = etag
+
+	// Folders created by the cache.
+	checksumFolder = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	stagingPrefix  = "tmp-"
+)
+
+// NewCache returns a Cache storing its trees in root. A zero maxBytes or
+// maxEntries disables that limit. Folders left in root by a previous Cache
+// are removed, as their contents are unknown.
+func NewCache(root string, maxBytes int64, maxEntries int) (*Cache, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	infos, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		if checksumFolder.MatchString(info.Name()) || strings.HasPrefix(info.Name(), stagingPrefix) {
+			if err := os.RemoveAll(filepath.Join(root, info.Name())); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &Cache{
+		root:       root,
+		maxBytes:   maxBytes,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*CacheEntry),
+		urls:       make(map[string]*CacheEntry),
+	}, nil
+}
+
+// Prepare returns the cached tree for location, preparing src if there is none.
+// The entry stays in the cache until it is released with CacheEntry.Release.
+func (c *Cache) Prepare(ctx context.Context, src Source, location string) (*CacheEntry, error) {
+
+	tag := headETag(ctx, location)
+
+	// Reuse the tree of a previous download of the same url and ETag.
+	c.mu.Lock()
+	if e, ok := c.urls[location]; ok && tag != "" && e.ETag == tag {
+		c.acquire(e)
+		c.mu.Unlock()
+		return e, nil
+	}
+	c.mu.Unlock()
+
+	staging, err := ioutil.TempDir(c.root, stagingPrefix)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	if ctxSource, ok := src.(ContextSource); ok {
+		err = ctxSource.PrepareFilesContext(ctx, staging)
+	} else {
+		err = src.PrepareFiles(staging)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	checksum := src.GetChecksum()
+	if !checksumFolder.MatchString(checksum) {
+		return nil, errors.New("could not cache source without a valid checksum")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	revision := ""
+	if revisioner, ok := src.(Revisioner); ok {
+		revision = revisioner.GetRevision()
+	}
+
+	// Different url or a changed ETag, but the same code.
+	if e, ok := c.entries[checksum]; ok {
+		c.remember(e, location, tag, revision)
+		c.acquire(e)
+		return e, nil
+	}
+
+	e, err := c.store(staging, checksum, src)
+	if err != nil {
+		return nil, err
+	}
+	c.remember(e, location, tag, revision)
+	c.acquire(e)
+	c.evict()
+
+	return e, nil
+}
+
+// Release marks the entry as no longer in use, so that it can be evicted.
+func (e *CacheEntry) Release() {
+	c := e.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e.refs > 0 {
+		e.refs--
+	}
+	c.evict()
+}
+
+// GetRevision returns the revision prepared from the url of the entry, so that sources reused
+// by their ETag have the same revision as when they were prepared.
+func (e *CacheEntry) GetRevision() string {
+	c := e.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return e.Revision
+}
+
+// Size returns the bytes used by the cached trees.
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// Len returns the number of cached trees.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// store moves the "unzipped" files of a staging folder into the cache.
+// Downloaded archives and other leftovers stay behind to be removed with the staging folder.
+func (c *Cache) store(staging, checksum string, src Source) (*CacheEntry, error) {
+	files := src.GetFiles()
+
+	path := filepath.Join(c.root, checksum)
+	if err := os.Mkdir(path, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	from := filepath.Join(staging, "unzipped")
+	to := filepath.Join(path, "unzipped")
+	if err := os.Rename(from, to); err != nil {
+		os.RemoveAll(path)
+		return nil, err
+	}
+
+	moved := make([]string, len(files))
+	for i, file := range files {
+		rel, err := filepath.Rel(from, file)
+		if err != nil || !within(from, file) {
+			os.RemoveAll(path)
+			return nil, errors.New("file outside of the prepared folder: " + file)
+		}
+		moved[i] = filepath.Join(to, rel)
+	}
+
+	// Paths in the manifest are relative, so they don't change with the move.
+	var manifest Manifest
+	if manifester, ok := src.(Manifester); ok {
+		manifest = manifester.GetManifest()
+	} else {
+		built, err := NewManifest(to, moved, nil)
+		if err != nil {
+			os.RemoveAll(path)
+			return nil, err
+		}
+		manifest = built
+	}
+
+	e := &CacheEntry{
+		Checksum: checksum,
+		Path:     path,
+		Files:    moved,
+		Manifest: manifest,
+		Size:     diskUsage(path),
+		cache:    c,
+	}
+	c.entries[checksum] = e
+	c.size += e.Size
+
+	return e, nil
+}
+
+// remember records that location (with an ETag and revision) resolved to the entry.
+func (c *Cache) remember(e *CacheEntry, location, tag, revision string) {
+	if old, ok := c.urls[location]; ok && old != e && old.URL == location {
+		old.URL, old.ETag, old.Revision = "", "", ""
+	}
+	e.URL, e.ETag, e.Revision = location, tag, revision
+	c.urls[location] = e
+}
+
+func (c *Cache) acquire(e *CacheEntry) {
+	e.refs++
+	e.lastUsed = time.Now()
+}
+
+// evict removes the least recently used entries that are not in use until the cache is within its limits.
+func (c *Cache) evict() {
+	for (c.maxBytes > 0 && c.size > c.maxBytes) || (c.maxEntries > 0 && len(c.entries) > c.maxEntries) {
+		var oldest *CacheEntry
+		for _, e := range c.entries {
+			if e.refs == 0 && (oldest == nil || e.lastUsed.Before(oldest.lastUsed)) {
+				oldest = e
+			}
+		}
+		if oldest == nil {
+			// Everything is in use.
+			return
+		}
+
+		os.RemoveAll(oldest.Path)
+		delete(c.entries, oldest.Checksum)
+		if c.urls[oldest.URL] == oldest {
+			delete(c.urls, oldest.URL)
+		}
+		c.size -= oldest.Size
+	}
+}
+
+// diskUsage returns the size of the regular files under path.
+func diskUsage(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// etag returns the ETag of a http(s) url, or an empty string if there is none.
+func etag(ctx context.Context, location string) string {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		return ""
+	}
+
+	req, err := DefaultDownloader.NewRequest(http.MethodHead, location)
+	if err != nil {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	resp, err := DefaultDownloader.Do(req.WithContext(ctx))
+	if err != nil {
+		return ""
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return ""
+	}
+
+	return resp.Header.Get("ETag")
+}
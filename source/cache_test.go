@@ -0,0 +1,299 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fileSource writes a single file and uses its content as the checksum.
+type fileSource struct {
+	content  string
+	prepared *int
+	err      error
+	files    []string
+}
+
+func (f *fileSource) PrepareFiles(dest string) error {
+	*f.prepared++
+	if f.err != nil {
+		return f.err
+	}
+
+	folder := filepath.Join(dest, "unzipped")
+	if err := os.MkdirAll(folder, os.ModePerm); err != nil {
+		return err
+	}
+	file := filepath.Join(folder, "plugin.php")
+	f.files = []string{file}
+
+	// Leftovers like a downloaded archive are not cached.
+	ioutil.WriteFile(filepath.Join(dest, "source.zip"), []byte("archive"), 0644)
+
+	return ioutil.WriteFile(file, []byte(f.content), 0644)
+}
+
+func (f *fileSource) GetChecksum() string {
+	return CombinedChecksum([]string{f.content})
+}
+
+func (f *fileSource) GetFiles() []string {
+	return f.files
+}
+
+// revisionSource is a fileSource from version control, which only knows its revision once prepared.
+type revisionSource struct {
+	fileSource
+	revision string
+	got      string
+}
+
+func (r *revisionSource) PrepareFiles(dest string) error {
+	r.got = r.revision
+	return r.fileSource.PrepareFiles(dest)
+}
+
+func (r *revisionSource) GetRevision() string {
+	return r.got
+}
+
+func TestCache_Prepare(t *testing.T) {
+
+	root, err := ioutil.TempDir("", "tide-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	// A leftover from a previous run and a folder the cache doesn't own.
+	leftover := filepath.Join(root, CombinedChecksum([]string{"old"}))
+	os.MkdirAll(leftover, os.ModePerm)
+	os.MkdirAll(filepath.Join(root, "keep"), os.ModePerm)
+
+	cache, err := NewCache(root, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+		t.Errorf("NewCache() did not remove %s", leftover)
+	}
+	if _, err := os.Stat(filepath.Join(root, "keep")); err != nil {
+		t.Errorf("NewCache() removed a folder it doesn't own: %v", err)
+	}
+
+	etags := map[string]string{}
+	oldHeadETag := headETag
+	headETag = func(ctx context.Context, location string) string {
+		return etags[location]
+	}
+	defer func() {
+		headETag = oldHeadETag
+	}()
+
+	prepared := 0
+	prepare := func(content, location string) *CacheEntry {
+		e, err := cache.Prepare(context.Background(), &fileSource{content: content, prepared: &prepared}, location)
+		if err != nil {
+			t.Fatalf("Cache.Prepare() error = %v", err)
+		}
+		return e
+	}
+
+	etags["http://example.local/a.zip"] = `"v1"`
+	a := prepare("a", "http://example.local/a.zip")
+	if prepared != 1 {
+		t.Errorf("Cache.Prepare() prepared %d times, want 1", prepared)
+	}
+	if got, _ := ioutil.ReadFile(filepath.Join(a.Path, "unzipped", "plugin.php")); string(got) != "a" {
+		t.Errorf("Cache.Prepare() path content = %q, want %q", got, "a")
+	}
+	if len(a.Files) != 1 || a.Files[0] != filepath.Join(a.Path, "unzipped", "plugin.php") {
+		t.Errorf("Cache.Prepare() files = %v, want files in %s", a.Files, a.Path)
+	}
+	if entry, ok := a.Manifest.Find("plugin.php"); !ok || entry.Size != 1 {
+		t.Errorf("Cache.Prepare() manifest = %v, want plugin.php", a.Manifest)
+	}
+	if _, err := os.Stat(filepath.Join(a.Path, "source.zip")); !os.IsNotExist(err) {
+		t.Errorf("Cache.Prepare() kept the downloaded archive")
+	}
+
+	// Same url and ETag, no need to prepare again.
+	if again := prepare("a", "http://example.local/a.zip"); again != a || prepared != 1 {
+		t.Errorf("Cache.Prepare() did not reuse the url, prepared %d times", prepared)
+	}
+
+	// Changed ETag but the same code, the tree is reused.
+	etags["http://example.local/a.zip"] = `"v2"`
+	if again := prepare("a", "http://example.local/a.zip"); again != a || prepared != 2 {
+		t.Errorf("Cache.Prepare() did not reuse the checksum, prepared %d times", prepared)
+	}
+
+	// Another url with the same code.
+	if mirror := prepare("a", "http://mirror.local/a.zip"); mirror != a || prepared != 3 {
+		t.Errorf("Cache.Prepare() did not reuse the checksum of another url, prepared %d times", prepared)
+	}
+	if cache.Len() != 1 {
+		t.Errorf("Cache.Len() = %d, want 1", cache.Len())
+	}
+
+	a.Release()
+	a.Release()
+	a.Release()
+	a.Release()
+
+	b := prepare("b", "http://example.local/b.zip")
+	b.Release()
+	c := prepare("c", "http://example.local/c.zip")
+
+	// Two entries allowed, a was used least recently.
+	if cache.Len() != 2 {
+		t.Errorf("Cache.Len() = %d, want 2", cache.Len())
+	}
+	if _, err := os.Stat(a.Path); !os.IsNotExist(err) {
+		t.Errorf("Cache.Prepare() did not evict %s", a.Path)
+	}
+	if _, err := os.Stat(b.Path); err != nil {
+		t.Errorf("Cache.Prepare() evicted %s: %v", b.Path, err)
+	}
+
+	// Everything in use, nothing can be evicted.
+	prepare("b", "http://example.local/b.zip")
+	d := prepare("d", "http://example.local/d.zip")
+	if cache.Len() != 3 {
+		t.Errorf("Cache.Len() = %d, want 3", cache.Len())
+	}
+
+	// Releasing makes room again.
+	d.Release()
+	if cache.Len() != 2 {
+		t.Errorf("Cache.Len() = %d, want 2", cache.Len())
+	}
+	if _, err := os.Stat(c.Path); err != nil {
+		t.Errorf("CacheEntry.Release() evicted an entry in use: %v", err)
+	}
+
+	infos, _ := ioutil.ReadDir(root)
+	for _, info := range infos {
+		if strings.HasPrefix(info.Name(), stagingPrefix) {
+			t.Errorf("Cache.Prepare() left staging folder %s", info.Name())
+		}
+	}
+}
+
+func TestCache_Prepare_revision(t *testing.T) {
+
+	root, err := ioutil.TempDir("", "tide-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	cache, err := NewCache(root, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldHeadETag := headETag
+	headETag = func(ctx context.Context, location string) string {
+		return `"v1"`
+	}
+	defer func() {
+		headETag = oldHeadETag
+	}()
+
+	prepared := 0
+	prepare := func(location string) *CacheEntry {
+		src := &revisionSource{fileSource: fileSource{content: "a", prepared: &prepared}, revision: "1234"}
+		e, err := cache.Prepare(context.Background(), src, location)
+		if err != nil {
+			t.Fatalf("Cache.Prepare() error = %v", err)
+		}
+		return e
+	}
+
+	if got := prepare("https://example.local/plugin.git").GetRevision(); got != "1234" {
+		t.Errorf("CacheEntry.GetRevision() = %q, want %q", got, "1234")
+	}
+
+	// Reused by its ETag, the source is not prepared and doesn't know its revision.
+	if got := prepare("https://example.local/plugin.git").GetRevision(); got != "1234" || prepared != 1 {
+		t.Errorf("CacheEntry.GetRevision() = %q after %d prepares, want %q after 1", got, prepared, "1234")
+	}
+}
+
+func TestCache_maxBytes(t *testing.T) {
+
+	root, err := ioutil.TempDir("", "tide-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	cache, err := NewCache(root, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prepared := 0
+	for _, content := range []string{"12345", "67890", "abcde"} {
+		e, err := cache.Prepare(context.Background(), &fileSource{content: content, prepared: &prepared}, "/"+content)
+		if err != nil {
+			t.Fatalf("Cache.Prepare() error = %v", err)
+		}
+		e.Release()
+	}
+
+	if cache.Size() != 10 || cache.Len() != 2 {
+		t.Errorf("Cache size = %d bytes in %d entries, want 10 bytes in 2 entries", cache.Size(), cache.Len())
+	}
+}
+
+func TestCache_Prepare_errors(t *testing.T) {
+
+	root, err := ioutil.TempDir("", "tide-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	cache, err := NewCache(root, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prepared := 0
+	tests := []struct {
+		name string
+		src  Source
+	}{
+		{
+			"Prepare Error",
+			&fileSource{content: "a", prepared: &prepared, err: errors.New("something went wrong")},
+		},
+		{
+			"No Checksum",
+			noChecksumSource{&fileSource{content: "a", prepared: &prepared}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := cache.Prepare(context.Background(), tt.src, "/code"); err == nil {
+				t.Errorf("Cache.Prepare() error = nil, want error")
+			}
+			if infos, _ := ioutil.ReadDir(root); len(infos) != 0 {
+				t.Errorf("Cache.Prepare() left %d folders behind", len(infos))
+			}
+		})
+	}
+}
+
+// noChecksumSource is a fileSource that fails to calculate a checksum.
+type noChecksumSource struct {
+	*fileSource
+}
+
+func (n noChecksumSource) GetChecksum() string { return "" }
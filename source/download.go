@@ -0,0 +1,196 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// ContextSource is implemented by sources that can stop preparing files when a context is done.
+type ContextSource interface {
+	Source
+	PrepareFilesContext(ctx context.Context, dest string) error
+}
+
+// Downloader fetches remote files for sources.
+type Downloader struct {
+	Client   *http.Client           // Client to use, http.DefaultClient if nil.
+	Timeout  time.Duration          // Timeout of a single attempt, including reading the body.
+	Retries  int                    // Number of retries after a network error or a 5xx response.
+	Backoff  time.Duration          // Wait before the first retry, doubled for every retry after.
+	MaxBytes int64                  // Maximum size of a download.
+	Headers  map[string]http.Header // Extra headers by host, e.g. an Authorization header for a private server.
+}
+
+// StatusError is returned when the server does not respond with a 2xx status.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+// Error implements the error interface.
+func (e *StatusError) Error() string {
+	return "could not download " + e.URL + ": " + e.Status
+}
+
+var (
+	// DefaultDownloader is used by sources that download files.
+	DefaultDownloader = &Downloader{
+		Timeout:  5 * time.Minute,
+		Retries:  3,
+		Backoff:  time.Second,
+		MaxBytes: 512 << 20,
+	}
+
+	// File system operation variables.
+	createFile = os.Create
+	renameFile = os.Rename
+
+	// ErrTooLarge is returned when a download is larger than MaxBytes.
+	ErrTooLarge = errors.New("download is larger than the maximum size")
+)
+
+// Download saves the url to destination.
+//
+// A SHA-256 checksum of the download can be given as a URL fragment
+// (e.g. "https://example.com/plugin.zip#sha256=<hex>"), the download
+// fails if it doesn't match.
+func (d *Downloader) Download(ctx context.Context, location, destination string) error {
+	u, err := url.Parse(location)
+	if err != nil {
+		return err
+	}
+
+	var expected string
+	if strings.HasPrefix(u.Fragment, "sha256=") {
+		expected = strings.ToLower(strings.TrimPrefix(u.Fragment, "sha256="))
+	}
+	u.Fragment = ""
+
+	backoff := d.Backoff
+	for attempt := 0; ; attempt++ {
+		err = d.attempt(ctx, u, destination, expected)
+		if err == nil || attempt >= d.Retries || !retryable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// attempt makes a single request and writes the response to destination.
+func (d *Downloader) attempt(ctx context.Context, u *url.URL, destination, expected string) error {
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+
+	req, err := d.NewRequest(http.MethodGet, u.String())
+	if err != nil {
+		return err
+	}
+
+	resp, err := d.client().Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &StatusError{u.String(), resp.StatusCode, resp.Status}
+	}
+
+	if d.MaxBytes > 0 && resp.ContentLength > d.MaxBytes {
+		return ErrTooLarge
+	}
+
+	// Write to a temporary file so that a failed download doesn't leave a partial file behind.
+	partial := destination + ".part"
+	out, err := createFile(partial)
+	if err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	var body io.Reader = resp.Body
+	if d.MaxBytes > 0 {
+		body = io.LimitReader(resp.Body, d.MaxBytes+1)
+	}
+
+	written, err := io.Copy(io.MultiWriter(out, h), body)
+	out.Close()
+
+	if err == nil && d.MaxBytes > 0 && written > d.MaxBytes {
+		err = ErrTooLarge
+	}
+	if err == nil && expected != "" {
+		if actual := hex.EncodeToString(h.Sum(nil)); actual != expected {
+			err = fmt.Errorf("checksum mismatch for %s: got sha256 %s, want %s", u.String(), actual, expected)
+		}
+	}
+	if err != nil {
+		os.Remove(partial)
+		return err
+	}
+
+	return renameFile(partial, destination)
+}
+
+// NewRequest returns a request with the extra headers configured for the host of the url.
+func (d *Downloader) NewRequest(method, location string) (*http.Request, error) {
+	req, err := http.NewRequest(method, location, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range d.Headers[req.URL.Host] {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	return req, nil
+}
+
+// Do sends a request with the downloader's client.
+func (d *Downloader) Do(req *http.Request) (*http.Response, error) {
+	return d.client().Do(req)
+}
+
+func (d *Downloader) client() *http.Client {
+	if d.Client != nil {
+		return d.Client
+	}
+	return http.DefaultClient
+}
+
+// retryable reports whether a failed attempt is worth repeating.
+func retryable(err error) bool {
+	if statusErr, ok := err.(*StatusError); ok {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	if err == context.DeadlineExceeded {
+		return true
+	}
+	_, ok := err.(net.Error)
+	return ok
+}
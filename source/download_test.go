@@ -0,0 +1,237 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDownloader_Download(t *testing.T) {
+
+	content := []byte("PK\x03\x04 plugin content")
+	sum := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	var flaky int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/plugin.zip":
+			w.Write(content)
+		case "/private.zip":
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write(content)
+		case "/flaky.zip":
+			// Fail the first two attempts.
+			if atomic.AddInt32(&flaky, 1) <= 2 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.Write(content)
+		case "/unavailable.zip":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/slow.zip":
+			time.Sleep(200 * time.Millisecond)
+			w.Write(content)
+		case "/large.zip":
+			// Don't announce the size.
+			w.(http.Flusher).Flush()
+			w.Write([]byte(strings.Repeat("a", 2048)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	root, err := ioutil.TempDir("", "tide-download")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Clean up after.
+	defer os.RemoveAll(root)
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name       string
+		downloader *Downloader
+		ctx        context.Context
+		url        string
+		createFile func(string) (*os.File, error)
+		wantStatus int
+		wantErr    bool
+	}{
+		{
+			"Success",
+			&Downloader{},
+			context.Background(),
+			server.URL + "/plugin.zip",
+			nil,
+			0,
+			false,
+		},
+		{
+			"Checksum Match",
+			&Downloader{},
+			context.Background(),
+			server.URL + "/plugin.zip#sha256=" + strings.ToUpper(sum),
+			nil,
+			0,
+			false,
+		},
+		{
+			"Checksum Mismatch",
+			&Downloader{},
+			context.Background(),
+			server.URL + "/plugin.zip#sha256=" + strings.Repeat("0", 64),
+			nil,
+			0,
+			true,
+		},
+		{
+			"Not Found",
+			&Downloader{Retries: 3},
+			context.Background(),
+			server.URL + "/missing.zip",
+			nil,
+			http.StatusNotFound,
+			true,
+		},
+		{
+			"Custom Headers",
+			&Downloader{
+				Headers: map[string]http.Header{
+					host: {"Authorization": []string{"Bearer secret"}},
+				},
+			},
+			context.Background(),
+			server.URL + "/private.zip",
+			nil,
+			0,
+			false,
+		},
+		{
+			"Headers For Other Host",
+			&Downloader{
+				Headers: map[string]http.Header{
+					"example.local": {"Authorization": []string{"Bearer secret"}},
+				},
+			},
+			context.Background(),
+			server.URL + "/private.zip",
+			nil,
+			http.StatusUnauthorized,
+			true,
+		},
+		{
+			"Retry Server Errors",
+			&Downloader{Retries: 2, Backoff: time.Millisecond},
+			context.Background(),
+			server.URL + "/flaky.zip",
+			nil,
+			0,
+			false,
+		},
+		{
+			"Retries Exhausted",
+			&Downloader{Retries: 1, Backoff: time.Millisecond},
+			context.Background(),
+			server.URL + "/unavailable.zip",
+			nil,
+			http.StatusServiceUnavailable,
+			true,
+		},
+		{
+			"Timeout",
+			&Downloader{Timeout: 50 * time.Millisecond},
+			context.Background(),
+			server.URL + "/slow.zip",
+			nil,
+			0,
+			true,
+		},
+		{
+			"Cancelled",
+			&Downloader{Retries: 3, Backoff: time.Millisecond},
+			cancelled,
+			server.URL + "/plugin.zip",
+			nil,
+			0,
+			true,
+		},
+		{
+			"Too Large",
+			&Downloader{MaxBytes: 1024},
+			context.Background(),
+			server.URL + "/large.zip",
+			nil,
+			0,
+			true,
+		},
+		{
+			"Create File Error",
+			&Downloader{},
+			context.Background(),
+			server.URL + "/plugin.zip",
+			func(string) (*os.File, error) {
+				return nil, errors.New("something went wrong")
+			},
+			0,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			if tt.createFile != nil {
+				oldCreateFile := createFile
+				createFile = tt.createFile
+				defer func() {
+					createFile = oldCreateFile
+				}()
+			}
+
+			destination := filepath.Join(root, "source.zip")
+			os.Remove(destination)
+
+			err := tt.downloader.Download(tt.ctx, tt.url, destination)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Downloader.Download() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantStatus != 0 {
+				statusErr, ok := err.(*StatusError)
+				if !ok || statusErr.StatusCode != tt.wantStatus {
+					t.Errorf("Downloader.Download() error = %v, want status %d", err, tt.wantStatus)
+				}
+			}
+
+			got, readErr := ioutil.ReadFile(destination)
+			if tt.wantErr {
+				if readErr == nil {
+					t.Errorf("Downloader.Download() left a file behind after an error")
+				}
+				return
+			}
+			if string(got) != string(content) {
+				t.Errorf("Downloader.Download() wrote %q, want %q", got, content)
+			}
+		})
+	}
+}
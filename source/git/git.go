@@ -0,0 +1,206 @@
+package git
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wptide/pkg/shell"
+	"github.com/wptide/pkg/source"
+)
+
+// Git describes a git repository source.
+type Git struct {
+	url      string
+	ref      string
+	dest     string
+	files    []string
+	checksum string
+	manifest source.Manifest
+	commit   string
+	runner   shell.Runner
+}
+
+var (
+	// File system operation variables.
+	removeAll = os.RemoveAll
+	fileOpen  = os.Open
+	ioCopy    = io.Copy
+
+	// Default paths.
+	checkoutFolder = "unzipped"
+)
+
+func init() {
+	source.Register(source.Kind{
+		Name:       "git",
+		Schemes:    []string{"git", "git+ssh", "ssh"},
+		Extensions: []string{".git"},
+		New: func(url string) source.Source {
+			return NewGit(url)
+		},
+	})
+}
+
+// PrepareFiles clones the repository into a given destination, checks out the
+// requested ref and extracts info about the tracked files.
+func (m *Git) PrepareFiles(dest string) error {
+
+	// Prepare destination.
+	m.dest = dest
+	if _, err := os.Stat(m.dest); os.IsNotExist(err) {
+		os.Mkdir(m.dest, os.ModePerm)
+	}
+
+	if m.runner == nil {
+		m.runner = &shell.Command{}
+	}
+
+	checkout := m.dest + "/" + checkoutFolder
+
+	// A previous checkout will prevent the clone, so start clean.
+	if err := removeAll(checkout); err != nil {
+		return err
+	}
+
+	// Don't let a ref be mistaken for a command line option.
+	if strings.HasPrefix(m.ref, "-") {
+		return errors.New("invalid ref: " + m.ref)
+	}
+
+	if err := m.git("clone", "--quiet", "--no-checkout", "--", m.url, checkout); err != nil {
+		return err
+	}
+
+	ref := m.ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	// Forcing the checkout populates the working tree even if ref is already HEAD.
+	if err := m.git("-C", checkout, "checkout", "--quiet", "--force", ref); err != nil {
+		return err
+	}
+
+	commit, _, _, err := m.runner.Run("git", "-C", checkout, "rev-parse", "HEAD")
+	if err != nil {
+		return errors.New("could not resolve commit for ref: " + ref)
+	}
+	m.commit = strings.TrimSpace(string(commit))
+
+	tracked, _, _, err := m.runner.Run("git", "-C", checkout, "ls-files", "-z")
+	if err != nil {
+		return errors.New("could not list tracked files")
+	}
+
+	var checksums []string
+	m.files, checksums, err = hashFiles(checkout, strings.Split(string(tracked), "\x00"))
+	if err != nil {
+		return err
+	}
+
+	// The repository metadata is not part of the code to audit.
+	if err := removeAll(checkout + "/.git"); err != nil {
+		return err
+	}
+
+	// Calculate checksum - uses same technique as Tide Audit Server.
+	m.checksum = source.CombinedChecksum(checksums)
+
+	// Keep the details of every file.
+	m.manifest, err = source.NewManifest(checkout, m.files, checksums)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetChecksum returns the combined checksum for the tracked files.
+func (m Git) GetChecksum() string {
+	return m.checksum
+}
+
+// GetFiles returns the tracked files in the checkout.
+func (m Git) GetFiles() []string {
+	return m.files
+}
+
+// GetManifest returns the details of the files in the tracked files.
+func (m Git) GetManifest() source.Manifest {
+	return m.manifest
+}
+
+// GetRevision returns the commit hash that was checked out.
+func (m Git) GetRevision() string {
+	return m.commit
+}
+
+// NewGit returns a new Git source.
+//
+// A branch, tag or commit can be given as a URL fragment,
+// e.g. "https://github.com/wptide/example.git#v1.0.0".
+// Without a fragment the default branch of the remote is used.
+func NewGit(url string) *Git {
+	ref := ""
+	if i := strings.LastIndex(url, "#"); i != -1 {
+		url, ref = url[:i], url[i+1:]
+	}
+
+	return &Git{
+		url: url,
+		ref: ref,
+	}
+}
+
+// git runs a git command and turns a failure into an error containing git's own output.
+func (m *Git) git(arg ...string) error {
+	_, stdErr, _, err := m.runner.Run("git", arg...)
+	if err != nil {
+		msg := strings.TrimSpace(string(stdErr))
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("git %s: %s", arg[0], msg)
+	}
+	return nil
+}
+
+// hashFiles calculates the checksum of each regular file in the list of
+// tracked files (relative to root).
+func hashFiles(root string, tracked []string) (filenames, checksums []string, err error) {
+	for _, name := range tracked {
+		if name == "" {
+			continue
+		}
+
+		path := filepath.Join(root, name)
+
+		// Skip symlinks and submodules.
+		info, err := os.Lstat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		file, err := fileOpen(path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		h := sha256.New()
+		if _, err := ioCopy(h, file); err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		file.Close()
+
+		filenames = append(filenames, path)
+		checksums = append(checksums, fmt.Sprintf("%x", h.Sum(nil)))
+	}
+
+	return filenames, checksums, nil
+}
@@ -0,0 +1,257 @@
+package git
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/wptide/pkg/source"
+	"github.com/wptide/pkg/source/sourcetest"
+)
+
+// newTestRepo creates a bare repository with two commits on the default branch,
+// a "v1.0.0" tag on the first commit and a "feature" branch with an extra file.
+// It returns the path to the bare repository and the hash of the tagged commit.
+func newTestRepo(t *testing.T, root string) (string, string) {
+	work := filepath.Join(root, "work")
+	bare := filepath.Join(root, "repo.git")
+
+	run := func(dir string, arg ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Tide", "-c", "user.email=tide@example.local"}, arg...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v: %s", arg, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	write := func(name, content string) {
+		path := filepath.Join(work, name)
+		os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	os.MkdirAll(work, os.ModePerm)
+	run(work, "init", "--quiet")
+
+	write("plugin.php", "<?php\n/* Plugin Name: Git Plugin */\n")
+	write("inc/functions.php", "<?php\n")
+	run(work, "add", ".")
+	run(work, "commit", "--quiet", "-m", "First")
+	run(work, "tag", "v1.0.0")
+	tagged := run(work, "rev-parse", "HEAD")
+
+	write("readme.txt", "=== Git Plugin ===\n")
+	run(work, "add", ".")
+	run(work, "commit", "--quiet", "-m", "Second")
+
+	run(work, "checkout", "--quiet", "-b", "feature")
+	write("feature.php", "<?php\n")
+	run(work, "add", ".")
+	run(work, "commit", "--quiet", "-m", "Feature")
+	run(work, "checkout", "--quiet", "-")
+
+	run(root, "clone", "--quiet", "--bare", work, bare)
+
+	return bare, tagged
+}
+
+func checksumOf(contents ...string) string {
+	var sums []string
+	for _, c := range contents {
+		sums = append(sums, fmt.Sprintf("%x", sha256.Sum256([]byte(c))))
+	}
+	return source.CombinedChecksum(sums)
+}
+
+func TestGit_PrepareFiles(t *testing.T) {
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	root, err := ioutil.TempDir("", "tide-git")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Clean up after.
+	defer os.RemoveAll(root)
+
+	repo, tagged := newTestRepo(t, root)
+	dest := filepath.Join(root, "dest")
+
+	plugin := "<?php\n/* Plugin Name: Git Plugin */\n"
+	functions := "<?php\n"
+	readme := "=== Git Plugin ===\n"
+
+	errorRemoveAll := func(path string) error {
+		return errors.New("something went wrong")
+	}
+
+	tests := []struct {
+		name         string
+		url          string
+		removeAll    func(string) error
+		wantFiles    []string
+		wantChecksum string
+		wantErr      bool
+	}{
+		{
+			"Default Branch",
+			repo,
+			nil,
+			[]string{"inc/functions.php", "plugin.php", "readme.txt"},
+			checksumOf(plugin, functions, readme),
+			false,
+		},
+		{
+			"Tag",
+			repo + "#v1.0.0",
+			nil,
+			[]string{"inc/functions.php", "plugin.php"},
+			checksumOf(plugin, functions),
+			false,
+		},
+		{
+			"Commit",
+			repo + "#" + tagged,
+			nil,
+			[]string{"inc/functions.php", "plugin.php"},
+			checksumOf(plugin, functions),
+			false,
+		},
+		{
+			"Branch",
+			repo + "#feature",
+			nil,
+			[]string{"feature.php", "inc/functions.php", "plugin.php", "readme.txt"},
+			checksumOf(plugin, functions, readme, functions),
+			false,
+		},
+		{
+			"Unknown Ref",
+			repo + "#v9.9.9",
+			nil,
+			nil,
+			"",
+			true,
+		},
+		{
+			"Option As Ref",
+			repo + "#--orphan",
+			nil,
+			nil,
+			"",
+			true,
+		},
+		{
+			"Unknown Repository",
+			filepath.Join(root, "missing.git"),
+			nil,
+			nil,
+			"",
+			true,
+		},
+		{
+			"Error Cleaning Destination",
+			repo,
+			errorRemoveAll,
+			nil,
+			"",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			if tt.removeAll != nil {
+				oldRemoveAll := removeAll
+				removeAll = tt.removeAll
+				defer func() {
+					removeAll = oldRemoveAll
+				}()
+			}
+
+			m := NewGit(tt.url)
+			err := m.PrepareFiles(dest)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Git.PrepareFiles() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var gotFiles []string
+			for _, file := range m.GetFiles() {
+				rel, _ := filepath.Rel(filepath.Join(dest, "unzipped"), file)
+				gotFiles = append(gotFiles, filepath.ToSlash(rel))
+			}
+			sort.Strings(gotFiles)
+
+			if !reflect.DeepEqual(gotFiles, tt.wantFiles) {
+				t.Errorf("Git.GetFiles() = %v, want %v", gotFiles, tt.wantFiles)
+			}
+			if got := m.GetChecksum(); got != tt.wantChecksum {
+				t.Errorf("Git.GetChecksum() = %v, want %v", got, tt.wantChecksum)
+			}
+			if _, err := os.Stat(filepath.Join(dest, "unzipped", ".git")); !os.IsNotExist(err) {
+				t.Errorf("Git.PrepareFiles() left repository metadata in the checkout")
+			}
+			sourcetest.CheckManifest(t, m, filepath.Join(dest, "unzipped"))
+		})
+	}
+
+	t.Run("Resolved Commit", func(t *testing.T) {
+		m := NewGit(repo + "#v1.0.0")
+		if err := m.PrepareFiles(dest); err != nil {
+			t.Fatalf("Git.PrepareFiles() error = %v", err)
+		}
+		if got := m.GetRevision(); got != tagged {
+			t.Errorf("Git.GetRevision() = %v, want %v", got, tagged)
+		}
+	})
+}
+
+func TestNewGit(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want *Git
+	}{
+		{
+			"Without Ref",
+			"https://github.com/wptide/example.git",
+			&Git{
+				url: "https://github.com/wptide/example.git",
+			},
+		},
+		{
+			"With Ref",
+			"https://github.com/wptide/example.git#v1.0.0",
+			&Git{
+				url: "https://github.com/wptide/example.git",
+				ref: "v1.0.0",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewGit(tt.url); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewGit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package local
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wptide/pkg/source"
+)
+
+// Local describes code that is already on disk, given as a directory path or a file:// URL.
+//
+// The checksum is the one of a zip of the same tree without its version control folders,
+// like the archives made by `git archive`, GitHub or wordpress.org. A zip that keeps
+// .git, .svn or .hg folders has a different checksum.
+type Local struct {
+	url      string
+	link     bool
+	roots    []string
+	dest     string
+	files    []string
+	checksum string
+	manifest source.Manifest
+}
+
+var (
+	// File system operation variables.
+	makeDirectoryAll = os.MkdirAll
+	removeAll        = os.RemoveAll
+	symlink          = os.Symlink
+	fileOpen         = os.Open
+	openFile         = os.OpenFile
+	ioCopy           = io.Copy
+
+	// AllowedRoots are the directories that local sources may read from, after resolving symlinks.
+	// No directory can be read if it is empty, so workers must opt in to read local paths from messages.
+	AllowedRoots []string
+
+	// Version control folders are not part of the code to audit.
+	skipFolders = map[string]bool{
+		".git": true,
+		".svn": true,
+		".hg":  true,
+	}
+)
+
+func init() {
+	source.Register(source.Kind{
+		Name:    "local",
+		Aliases: []string{"file", "directory"},
+		Schemes: []string{"file"},
+		New: func(url string) source.Source {
+			return NewLocal(url, false)
+		},
+	})
+}
+
+// PrepareFiles copies (or links) the directory to a given destination and extracts info about its files.
+func (m *Local) PrepareFiles(dest string) error {
+
+	root, err := Path(m.url)
+	if err != nil {
+		return err
+	}
+
+	root, err = allowedPath(root, m.allowedRoots())
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New("not a directory: " + root)
+	}
+
+	// Prepare destination.
+	m.dest = dest
+	if _, err := os.Stat(m.dest); os.IsNotExist(err) {
+		os.Mkdir(m.dest, os.ModePerm)
+	}
+
+	target := m.dest + "/unzipped"
+
+	// Start clean so that stale files don't end up in the audit.
+	if err := removeAll(target); err != nil {
+		return err
+	}
+
+	if m.link {
+		if err := symlink(root, target); err != nil {
+			return err
+		}
+	}
+
+	var checksums []string
+	m.files, checksums, err = walk(root, target, !m.link)
+	if err != nil {
+		return err
+	}
+
+	// Calculate checksum - uses same technique as Tide Audit Server.
+	m.checksum = source.CombinedChecksum(checksums)
+
+	// Keep the details of every file.
+	m.manifest, err = source.NewManifest(target, m.files, checksums)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetChecksum returns the combined checksum for the directory.
+func (m Local) GetChecksum() string {
+	return m.checksum
+}
+
+// GetFiles returns the files contained in the directory.
+func (m Local) GetFiles() []string {
+	return m.files
+}
+
+// GetManifest returns the details of the files in the directory.
+func (m Local) GetManifest() source.Manifest {
+	return m.manifest
+}
+
+// SetAllowedRoots replaces AllowedRoots for this source.
+func (m *Local) SetAllowedRoots(roots []string) {
+	m.roots = roots
+}
+
+// allowedRoots returns the roots set for this source, or AllowedRoots.
+func (m Local) allowedRoots() []string {
+	if m.roots != nil {
+		return m.roots
+	}
+	return AllowedRoots
+}
+
+// NewLocal returns a new Local source.
+// If link is true the destination becomes a symlink to the directory instead of a copy.
+func NewLocal(url string, link bool) *Local {
+	return &Local{
+		url:  url,
+		link: link,
+	}
+}
+
+// IsLocal reports whether the url is a file:// URL.
+func IsLocal(url string) bool {
+	return strings.HasPrefix(url, "file://")
+}
+
+// Path converts a file:// URL into an absolute path. Plain paths are made absolute.
+func Path(location string) (string, error) {
+	if IsLocal(location) {
+		u, err := url.Parse(location)
+		if err != nil {
+			return "", err
+		}
+		if u.Host != "" && u.Host != "localhost" {
+			return "", errors.New("unsupported file host: " + u.Host)
+		}
+		location = u.Path
+	}
+
+	if location == "" {
+		return "", errors.New("empty path")
+	}
+
+	return filepath.Abs(location)
+}
+
+// allowedPath resolves the symlinks in path and returns the result if it is inside one of the roots.
+func allowedPath(path string, roots []string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+
+	if len(roots) == 0 {
+		return "", errors.New("no allowed roots for local sources: " + path)
+	}
+
+	for _, root := range roots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		abs, err = filepath.EvalSymlinks(abs)
+		if err != nil {
+			continue
+		}
+
+		rel, err := filepath.Rel(abs, resolved)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+
+	return "", errors.New("not in an allowed root: " + path)
+}
+
+// walk calculates the checksum of every regular file under root. If copy is true
+// the files are also copied to destination, otherwise they are expected to be
+// reachable at destination already.
+func walk(root, destination string, copy bool) (filenames, checksums []string, err error) {
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(destination, rel)
+
+		if info.IsDir() {
+			if skipFolders[info.Name()] {
+				return filepath.SkipDir
+			}
+			if copy {
+				return makeDirectoryAll(target, info.Mode().Perm()|0700)
+			}
+			return nil
+		}
+
+		// Skip symlinks, sockets and devices.
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		sum, err := hashFile(path, target, info.Mode(), copy)
+		if err != nil {
+			return err
+		}
+
+		filenames = append(filenames, target)
+		checksums = append(checksums, sum)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return filenames, checksums, nil
+}
+
+// hashFile returns the checksum of a file, copying it to target if required.
+func hashFile(path, target string, mode os.FileMode, copy bool) (string, error) {
+	sourceFile, err := fileOpen(path)
+	if err != nil {
+		return "", err
+	}
+	defer sourceFile.Close()
+
+	h := sha256.New()
+	var out io.Writer = h
+
+	if copy {
+		targetFile, err := openFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+		if err != nil {
+			return "", err
+		}
+		defer targetFile.Close()
+		out = io.MultiWriter(targetFile, h)
+	}
+
+	if _, err := ioCopy(out, sourceFile); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
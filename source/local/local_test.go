@@ -0,0 +1,517 @@
+package local
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wptide/pkg/source/sourcetest"
+	sourcezip "github.com/wptide/pkg/source/zip"
+)
+
+// Same combined checksum as source/zip/testdata/test.zip, which holds the same files.
+const wantChecksum = "a28f162ea0ea0050602d9da97a56cb9e154048047bbcc74aa2033807a47479f5"
+
+// allowTestdata lets local sources read the testdata folder, and returns a func to reset AllowedRoots.
+func allowTestdata() func() {
+	oldRoots := AllowedRoots
+	AllowedRoots = []string{"./testdata"}
+	return func() {
+		AllowedRoots = oldRoots
+	}
+}
+
+func TestLocal_PrepareFiles(t *testing.T) {
+
+	defer allowTestdata()()
+
+	dest := "./testdata/download"
+
+	// Version control folders must not change the checksum.
+	os.MkdirAll("./testdata/dummy-theme/.git", os.ModePerm)
+	ioutil.WriteFile("./testdata/dummy-theme/.git/HEAD", []byte("ref: refs/heads/master\n"), 0644)
+
+	// Clean up after.
+	defer func() {
+		os.RemoveAll(dest)
+		os.RemoveAll("./testdata/dummy-theme/.git")
+	}()
+
+	abs, _ := filepath.Abs("./testdata/dummy-theme")
+
+	errorSymlink := func(oldname, newname string) error {
+		return errors.New("something went wrong")
+	}
+
+	errorRemoveAll := func(path string) error {
+		return errors.New("something went wrong")
+	}
+
+	errorCopy := func(dst io.Writer, src io.Reader) (written int64, err error) {
+		return 0, errors.New("something went wrong")
+	}
+
+	errorOpenFile := func(name string, flag int, perm os.FileMode) (*os.File, error) {
+		return nil, errors.New("something went wrong")
+	}
+
+	errorMakeDirectoryAll := func(path string, perm os.FileMode) error {
+		return errors.New("something went wrong")
+	}
+
+	wantFiles := []string{
+		"testdata/download/unzipped/function.php",
+		"testdata/download/unzipped/script.js",
+		"testdata/download/unzipped/style.css",
+	}
+
+	type mocks struct {
+		symlink          func(oldname, newname string) error
+		removeAll        func(path string) error
+		ioCopy           func(dst io.Writer, src io.Reader) (written int64, err error)
+		openFile         func(name string, flag int, perm os.FileMode) (*os.File, error)
+		makeDirectoryAll func(path string, perm os.FileMode) error
+	}
+	tests := []struct {
+		name         string
+		url          string
+		link         bool
+		mocks        mocks
+		wantFiles    []string
+		wantChecksum string
+		wantErr      bool
+	}{
+		{
+			"Plain Directory",
+			"./testdata/dummy-theme",
+			false,
+			mocks{},
+			wantFiles,
+			wantChecksum,
+			false,
+		},
+		{
+			"File URL",
+			"file://" + abs,
+			false,
+			mocks{},
+			wantFiles,
+			wantChecksum,
+			false,
+		},
+		{
+			"File URL - Symlink",
+			"file://" + abs,
+			true,
+			mocks{},
+			wantFiles,
+			wantChecksum,
+			false,
+		},
+		{
+			"File URL - Remote Host",
+			"file://example.local" + abs,
+			false,
+			mocks{},
+			nil,
+			"",
+			true,
+		},
+		{
+			"Missing Directory",
+			"./testdata/missing",
+			false,
+			mocks{},
+			nil,
+			"",
+			true,
+		},
+		{
+			"Not A Directory",
+			"./testdata/dummy-theme/style.css",
+			false,
+			mocks{},
+			nil,
+			"",
+			true,
+		},
+		{
+			"Empty Path",
+			"file://",
+			false,
+			mocks{},
+			nil,
+			"",
+			true,
+		},
+		{
+			"Symlink Error",
+			"./testdata/dummy-theme",
+			true,
+			mocks{
+				symlink: errorSymlink,
+			},
+			nil,
+			"",
+			true,
+		},
+		{
+			"Clean Destination Error",
+			"./testdata/dummy-theme",
+			false,
+			mocks{
+				removeAll: errorRemoveAll,
+			},
+			nil,
+			"",
+			true,
+		},
+		{
+			"Copy Error",
+			"./testdata/dummy-theme",
+			false,
+			mocks{
+				ioCopy: errorCopy,
+			},
+			nil,
+			"",
+			true,
+		},
+		{
+			"Open Target Error",
+			"./testdata/dummy-theme",
+			false,
+			mocks{
+				openFile: errorOpenFile,
+			},
+			nil,
+			"",
+			true,
+		},
+		{
+			"Create Directory Error",
+			"./testdata/dummy-theme",
+			false,
+			mocks{
+				makeDirectoryAll: errorMakeDirectoryAll,
+			},
+			nil,
+			"",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			if tt.mocks.symlink != nil {
+				oldSymlink := symlink
+				symlink = tt.mocks.symlink
+				defer func() {
+					symlink = oldSymlink
+				}()
+			}
+
+			if tt.mocks.removeAll != nil {
+				oldRemoveAll := removeAll
+				removeAll = tt.mocks.removeAll
+				defer func() {
+					removeAll = oldRemoveAll
+				}()
+			}
+
+			if tt.mocks.ioCopy != nil {
+				oldCopy := ioCopy
+				ioCopy = tt.mocks.ioCopy
+				defer func() {
+					ioCopy = oldCopy
+				}()
+			}
+
+			if tt.mocks.openFile != nil {
+				oldOpenFile := openFile
+				openFile = tt.mocks.openFile
+				defer func() {
+					openFile = oldOpenFile
+				}()
+			}
+
+			if tt.mocks.makeDirectoryAll != nil {
+				oldMakeDirectoryAll := makeDirectoryAll
+				makeDirectoryAll = tt.mocks.makeDirectoryAll
+				defer func() {
+					makeDirectoryAll = oldMakeDirectoryAll
+				}()
+			}
+
+			m := NewLocal(tt.url, tt.link)
+			err := m.PrepareFiles(dest)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Local.PrepareFiles() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got := m.GetChecksum(); got != tt.wantChecksum {
+				t.Errorf("Local.GetChecksum() = %v, want %v", got, tt.wantChecksum)
+			}
+			if got := m.GetFiles(); !reflect.DeepEqual(got, tt.wantFiles) {
+				t.Errorf("Local.GetFiles() = %v, want %v", got, tt.wantFiles)
+			}
+
+			// Every reported file must be readable from the destination.
+			for _, file := range m.GetFiles() {
+				if _, err := os.Stat(file); err != nil {
+					t.Errorf("Local.PrepareFiles() file not in destination: %v", err)
+				}
+			}
+			if err == nil {
+				sourcetest.CheckManifest(t, m, filepath.Join(dest, "unzipped"))
+			}
+		})
+	}
+}
+
+func TestLocal_PrepareFiles_allowedRoots(t *testing.T) {
+
+	dest := "./testdata/download"
+
+	// A symlink inside an allowed root that points out of it.
+	os.MkdirAll("./testdata/allowed", os.ModePerm)
+	abs, _ := filepath.Abs("./testdata/dummy-theme")
+	os.Symlink(abs, "./testdata/allowed/escape")
+
+	// Clean up after.
+	defer func() {
+		os.RemoveAll(dest)
+		os.RemoveAll("./testdata/allowed")
+	}()
+
+	tests := []struct {
+		name         string
+		url          string
+		roots        []string
+		defaultRoots []string
+		wantErr      bool
+	}{
+		{
+			"No Roots",
+			"./testdata/dummy-theme",
+			nil,
+			nil,
+			true,
+		},
+		{
+			"Empty Roots",
+			"./testdata/dummy-theme",
+			[]string{},
+			[]string{"./testdata"},
+			true,
+		},
+		{
+			"Inside Root",
+			"./testdata/dummy-theme",
+			[]string{"./testdata/allowed", "./testdata"},
+			nil,
+			false,
+		},
+		{
+			"Root Itself",
+			"file://" + abs,
+			[]string{"./testdata/dummy-theme"},
+			nil,
+			false,
+		},
+		{
+			"Outside Root",
+			"./testdata/dummy-theme",
+			[]string{"./testdata/allowed"},
+			nil,
+			true,
+		},
+		{
+			"Sibling With Root Prefix",
+			"./testdata/dummy-theme",
+			[]string{"./testdata/dummy"},
+			nil,
+			true,
+		},
+		{
+			"Symlink Out Of Root",
+			"./testdata/allowed/escape",
+			[]string{"./testdata/allowed"},
+			nil,
+			true,
+		},
+		{
+			"Default Roots",
+			"./testdata/dummy-theme",
+			nil,
+			[]string{"./testdata"},
+			false,
+		},
+		{
+			"Outside Default Roots",
+			"./testdata/dummy-theme",
+			nil,
+			[]string{"./testdata/allowed"},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldRoots := AllowedRoots
+			AllowedRoots = tt.defaultRoots
+			defer func() {
+				AllowedRoots = oldRoots
+			}()
+
+			m := NewLocal(tt.url, false)
+			m.SetAllowedRoots(tt.roots)
+
+			err := m.PrepareFiles(dest)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Local.PrepareFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLocal_PrepareFiles_versionControl(t *testing.T) {
+
+	defer allowTestdata()()
+
+	dest := "./testdata/download"
+
+	os.MkdirAll("./testdata/dummy-theme/.git", os.ModePerm)
+	ioutil.WriteFile("./testdata/dummy-theme/.git/HEAD", []byte("ref: refs/heads/master\n"), 0644)
+
+	// Clean up after.
+	defer func() {
+		os.RemoveAll(dest)
+		os.RemoveAll("./testdata/dummy-theme/.git")
+	}()
+
+	// Serves zips of the tree, with or without its .git folder.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		z := zip.NewWriter(w)
+		filepath.Walk("./testdata/dummy-theme", func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			if r.URL.Path == "/without-git.zip" && strings.Contains(path, ".git") {
+				return nil
+			}
+			f, _ := z.Create(path)
+			contents, _ := ioutil.ReadFile(path)
+			f.Write(contents)
+			return nil
+		})
+		z.Close()
+	}))
+	defer ts.Close()
+
+	zipChecksum := func(name string) string {
+		z := sourcezip.NewZip(ts.URL + "/" + name)
+		if err := z.PrepareFiles(dest + "-" + name); err != nil {
+			t.Fatalf("Zip.PrepareFiles() error = %v", err)
+		}
+		defer os.RemoveAll(dest + "-" + name)
+		return z.GetChecksum()
+	}
+
+	m := NewLocal("./testdata/dummy-theme", false)
+	if err := m.PrepareFiles(dest); err != nil {
+		t.Fatalf("Local.PrepareFiles() error = %v", err)
+	}
+
+	if got, want := m.GetChecksum(), zipChecksum("without-git.zip"); got != want {
+		t.Errorf("Local.GetChecksum() = %v, want the checksum of a zip without .git %v", got, want)
+	}
+	if got, other := m.GetChecksum(), zipChecksum("with-git.zip"); got == other {
+		t.Errorf("Local.GetChecksum() = %v, want it to differ from a zip with .git", got)
+	}
+}
+
+func TestIsLocal(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want bool
+	}{
+		{"File URL", "file:///srv/plugin", true},
+		{"Plain Path", "/srv/plugin", false},
+		{"HTTP URL", "http://example.local/plugin.zip", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsLocal(tt.url); got != tt.want {
+				t.Errorf("IsLocal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		location string
+		want     string
+		wantErr  bool
+	}{
+		{"File URL", "file:///srv/plugin", "/srv/plugin", false},
+		{"File URL - Localhost", "file://localhost/srv/plugin", "/srv/plugin", false},
+		{"File URL - Escaped", "file:///srv/my%20plugin", "/srv/my plugin", false},
+		{"File URL - Remote Host", "file://example.local/srv/plugin", "", true},
+		{"Plain Path", "/srv/plugin/", "/srv/plugin", false},
+		{"Empty", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Path(tt.location)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Path() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Path() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewLocal(t *testing.T) {
+	type args struct {
+		url  string
+		link bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want *Local
+	}{
+		{
+			"Get new *Local",
+			args{
+				"file:///srv/plugin",
+				true,
+			},
+			&Local{
+				url:  "file:///srv/plugin",
+				link: true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewLocal(tt.args.url, tt.args.link); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewLocal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package source
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Manifest lists the files of a prepared source.
+type Manifest struct {
+	Checksum string          `json:"checksum"` // Combined checksum, as returned by Source.GetChecksum.
+	Files    []ManifestEntry `json:"files"`    // Sorted by path.
+}
+
+// ManifestEntry describes a single file of a Manifest.
+type ManifestEntry struct {
+	Path   string      `json:"path"` // Slash separated path, relative to the "unzipped" folder.
+	Size   int64       `json:"size"`
+	Mode   os.FileMode `json:"mode"`
+	SHA256 string      `json:"sha256"`
+}
+
+// Manifester is implemented by sources that keep a Manifest of the files they prepared.
+type Manifester interface {
+	GetManifest() Manifest
+}
+
+// NewManifest returns the Manifest of files under root, using the checksums that were
+// calculated while preparing them. If checksums is nil the files are hashed again.
+func NewManifest(root string, files, checksums []string) (Manifest, error) {
+	if checksums != nil && len(checksums) != len(files) {
+		return Manifest{}, errors.New("manifest needs a checksum for every file")
+	}
+
+	var manifest Manifest
+	var sums []string
+	for i, file := range files {
+		rel, err := filepath.Rel(root, file)
+		if err != nil || !within(root, file) {
+			return Manifest{}, errors.New("file outside of the manifest root: " + file)
+		}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			return Manifest{}, err
+		}
+
+		var sum string
+		if checksums != nil {
+			sum = checksums[i]
+		} else if sum, err = hashFile(file); err != nil {
+			return Manifest{}, err
+		}
+
+		manifest.Files = append(manifest.Files, ManifestEntry{
+			Path:   filepath.ToSlash(rel),
+			Size:   info.Size(),
+			Mode:   info.Mode(),
+			SHA256: sum,
+		})
+		sums = append(sums, sum)
+	}
+
+	sort.Slice(manifest.Files, func(i, j int) bool {
+		return manifest.Files[i].Path < manifest.Files[j].Path
+	})
+	manifest.Checksum = CombinedChecksum(sums)
+
+	return manifest, nil
+}
+
+// Find returns the entry for a slash separated path.
+func (m Manifest) Find(path string) (ManifestEntry, bool) {
+	i := sort.Search(len(m.Files), func(i int) bool {
+		return m.Files[i].Path >= path
+	})
+	if i < len(m.Files) && m.Files[i].Path == path {
+		return m.Files[i], true
+	}
+	return ManifestEntry{}, false
+}
+
+// Diff returns the paths that were added, removed or changed in other compared to m.
+func (m Manifest) Diff(other Manifest) (added, removed, changed []string) {
+	for _, entry := range other.Files {
+		old, ok := m.Find(entry.Path)
+		switch {
+		case !ok:
+			added = append(added, entry.Path)
+		case old.SHA256 != entry.SHA256 || old.Mode != entry.Mode:
+			changed = append(changed, entry.Path)
+		}
+	}
+	for _, entry := range m.Files {
+		if _, ok := other.Find(entry.Path); !ok {
+			removed = append(removed, entry.Path)
+		}
+	}
+	return added, removed, changed
+}
+
+// hashFile returns the SHA-256 of a file.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
@@ -0,0 +1,132 @@
+package source
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNewManifest(t *testing.T) {
+
+	root, err := ioutil.TempDir("", "tide-manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	os.MkdirAll(filepath.Join(root, "theme", "inc"), os.ModePerm)
+	style := filepath.Join(root, "theme", "style.css")
+	functions := filepath.Join(root, "theme", "inc", "functions.php")
+	ioutil.WriteFile(style, []byte("/* Theme Name: Dummy */"), 0644)
+	ioutil.WriteFile(functions, []byte("<?php\n"), 0755)
+
+	// Known checksums are used as they are, without hashing the file again.
+	styleSum := "8a9a77a9c6ba1d6bf2c28d5e26f6e8ffd2bc2d0e2b0ac1c1e0e55a4a4e53b2a0"
+	functionsSum, _ := hashFile(functions)
+
+	want := Manifest{
+		Checksum: CombinedChecksum([]string{styleSum, functionsSum}),
+		Files: []ManifestEntry{
+			{"theme/inc/functions.php", 6, 0755, functionsSum},
+			{"theme/style.css", 23, 0644, styleSum},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		files     []string
+		checksums []string
+		want      Manifest
+		wantErr   bool
+	}{
+		{
+			"Known Checksums",
+			[]string{style, functions},
+			[]string{styleSum, functionsSum},
+			want,
+			false,
+		},
+		{
+			"Missing Checksums",
+			[]string{style, functions},
+			[]string{styleSum},
+			Manifest{},
+			true,
+		},
+		{
+			"Outside Root",
+			[]string{filepath.Join(root, "..", "evil.php")},
+			nil,
+			Manifest{},
+			true,
+		},
+		{
+			"Missing File",
+			[]string{filepath.Join(root, "missing.php")},
+			nil,
+			Manifest{},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewManifest(root, tt.files, tt.checksums)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewManifest() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewManifest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("Hash Files", func(t *testing.T) {
+		got, err := NewManifest(root, []string{functions}, nil)
+		if err != nil {
+			t.Fatalf("NewManifest() error = %v", err)
+		}
+		if len(got.Files) != 1 || got.Files[0].SHA256 != functionsSum {
+			t.Errorf("NewManifest() = %v, want sha256 %v", got, functionsSum)
+		}
+	})
+}
+
+func TestManifest_Diff(t *testing.T) {
+	old := Manifest{
+		Files: []ManifestEntry{
+			{"a.php", 1, 0644, "1"},
+			{"b.php", 1, 0644, "2"},
+			{"c.php", 1, 0644, "3"},
+			{"d.sh", 1, 0644, "4"},
+		},
+	}
+	current := Manifest{
+		Files: []ManifestEntry{
+			{"a.php", 1, 0644, "1"},
+			{"c.php", 2, 0644, "5"},
+			{"d.sh", 1, 0755, "4"},
+			{"e.php", 1, 0644, "6"},
+		},
+	}
+
+	added, removed, changed := old.Diff(current)
+	if !reflect.DeepEqual(added, []string{"e.php"}) {
+		t.Errorf("Manifest.Diff() added = %v, want %v", added, []string{"e.php"})
+	}
+	if !reflect.DeepEqual(removed, []string{"b.php"}) {
+		t.Errorf("Manifest.Diff() removed = %v, want %v", removed, []string{"b.php"})
+	}
+	if !reflect.DeepEqual(changed, []string{"c.php", "d.sh"}) {
+		t.Errorf("Manifest.Diff() changed = %v, want %v", changed, []string{"c.php", "d.sh"})
+	}
+
+	if entry, ok := current.Find("c.php"); !ok || entry.SHA256 != "5" {
+		t.Errorf("Manifest.Find() = %v, %v, want c.php", entry, ok)
+	}
+	if _, ok := current.Find("b.php"); ok {
+		t.Errorf("Manifest.Find() found a removed file")
+	}
+}
@@ -0,0 +1,227 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Factory returns a new Source for a url.
+type Factory func(url string) Source
+
+// Kind describes a Source implementation and how to recognise the urls it handles.
+type Kind struct {
+	Name       string   // Name of the kind, also matched against the message source type (e.g. "zip").
+	Aliases    []string // Other source types handled by this kind (e.g. "tgz").
+	Schemes    []string // URL schemes handled by this kind (e.g. "file").
+	Extensions []string // File extensions handled by this kind, including the dot (e.g. ".tar.gz").
+	MimeTypes  []string // Content types handled by this kind (e.g. "application/zip").
+	New        Factory  // Creates the Source.
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []Kind
+
+	// Using a variable so that we can mock it in tests.
+	sniffURL = sniff
+
+	// Content types that don't say anything about the content.
+	genericTypes = map[string]bool{
+		"":                         true,
+		"application/octet-stream": true,
+		"binary/octet-stream":      true,
+		"text/plain":               true,
+	}
+)
+
+// Register makes a Source implementation available to Resolve.
+// It panics if the kind has no name or factory, or if the name is already registered.
+func Register(kind Kind) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if kind.Name == "" || kind.New == nil {
+		panic("source: Register kind requires a name and a factory")
+	}
+
+	for _, k := range registry {
+		if k.Name == kind.Name {
+			panic("source: Register called twice for kind " + kind.Name)
+		}
+	}
+
+	registry = append(registry, kind)
+}
+
+// Kinds returns the sorted names of the registered kinds.
+func Kinds() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	var names []string
+	for _, k := range registry {
+		names = append(names, k.Name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Resolve returns a Source for the url, using the first of these that matches a registered kind:
+//   - the url scheme (other than http and https),
+//   - the source type (e.g. Message.SourceType),
+//   - the file extension of the url path or of a query value (e.g. "?file=plugin.zip"),
+//   - the content type or filename of the download.
+func Resolve(location string, sourceType string) (Source, error) {
+	registryMu.RLock()
+	kinds := make([]Kind, len(registry))
+	copy(kinds, registry)
+	registryMu.RUnlock()
+
+	u, err := url.Parse(location)
+	if err != nil {
+		u = &url.URL{Path: location}
+	}
+	scheme := strings.ToLower(u.Scheme)
+	remote := scheme == "http" || scheme == "https"
+
+	if scheme != "" && !remote {
+		if k, ok := matchScheme(kinds, scheme); ok {
+			return k.New(location), nil
+		}
+	}
+
+	if k, ok := matchType(kinds, strings.ToLower(sourceType)); ok {
+		return k.New(location), nil
+	}
+
+	candidates := []string{u.Path}
+	for _, values := range u.Query() {
+		candidates = append(candidates, values...)
+	}
+	if k, ok := matchExtension(kinds, candidates...); ok {
+		return k.New(location), nil
+	}
+
+	if remote {
+		contentType, filename, err := sniffURL(location)
+		if err == nil {
+			if k, ok := matchMimeType(kinds, contentType); ok {
+				return k.New(location), nil
+			}
+			if k, ok := matchExtension(kinds, filename); ok {
+				return k.New(location), nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("could not find a source for %q (type %q), supported kinds: %s",
+		location, sourceType, strings.Join(Kinds(), ", "))
+}
+
+func matchScheme(kinds []Kind, scheme string) (Kind, bool) {
+	for _, k := range kinds {
+		for _, s := range k.Schemes {
+			if s == scheme {
+				return k, true
+			}
+		}
+	}
+	return Kind{}, false
+}
+
+func matchType(kinds []Kind, sourceType string) (Kind, bool) {
+	if sourceType == "" {
+		return Kind{}, false
+	}
+	for _, k := range kinds {
+		if k.Name == sourceType {
+			return k, true
+		}
+		for _, a := range k.Aliases {
+			if a == sourceType {
+				return k, true
+			}
+		}
+	}
+	return Kind{}, false
+}
+
+// matchExtension finds the kind with the longest extension matching one of the names,
+// so that ".tar.gz" wins over ".gz".
+func matchExtension(kinds []Kind, names ...string) (Kind, bool) {
+	var found Kind
+	longest := 0
+	for _, name := range names {
+		name = strings.ToLower(path.Base(name))
+		for _, k := range kinds {
+			for _, ext := range k.Extensions {
+				if len(ext) > longest && strings.HasSuffix(name, ext) {
+					found, longest = k, len(ext)
+				}
+			}
+		}
+	}
+	return found, longest > 0
+}
+
+func matchMimeType(kinds []Kind, contentType string) (Kind, bool) {
+	if contentType == "" {
+		return Kind{}, false
+	}
+	for _, k := range kinds {
+		for _, m := range k.MimeTypes {
+			if m == contentType {
+				return k, true
+			}
+		}
+	}
+	return Kind{}, false
+}
+
+// sniff requests the start of a download and returns its content type and filename.
+// If the server doesn't send a useful content type it is detected from the content.
+func sniff(location string) (contentType, filename string, err error) {
+	req, err := DefaultDownloader.NewRequest(http.MethodGet, location)
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Range", "bytes=0-511")
+
+	if DefaultDownloader.Timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultDownloader.Timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+
+	resp, err := DefaultDownloader.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return "", "", fmt.Errorf("could not sniff %q: %s", location, resp.Status)
+	}
+
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		filename = params["filename"]
+	}
+
+	contentType, _, _ = mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if genericTypes[contentType] {
+		head, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		contentType, _, _ = mime.ParseMediaType(http.DetectContentType(head))
+	}
+
+	return contentType, filename, nil
+}
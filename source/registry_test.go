@@ -0,0 +1,267 @@
+package source
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// kindSource is a Source that remembers which kind created it.
+type kindSource struct {
+	kind string
+	url  string
+}
+
+func (k kindSource) PrepareFiles(dest string) error { return nil }
+func (k kindSource) GetChecksum() string            { return "" }
+func (k kindSource) GetFiles() []string             { return nil }
+
+func testKind(name string) Kind {
+	return Kind{
+		Name: name,
+		New: func(url string) Source {
+			return kindSource{name, url}
+		},
+	}
+}
+
+// withKinds replaces the registry for the duration of a test.
+func withKinds(kinds ...Kind) func() {
+	registryMu.Lock()
+	old := registry
+	registry = kinds
+	registryMu.Unlock()
+
+	return func() {
+		registryMu.Lock()
+		registry = old
+		registryMu.Unlock()
+	}
+}
+
+var sniffServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/typed":
+		w.Header().Set("Content-Type", "application/x-gzip")
+		w.Write([]byte{0x1f, 0x8b})
+	case "/attachment":
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="plugin.tar.gz"`)
+		w.Write([]byte("not sniffable"))
+	case "/untyped":
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte("PK\x03\x04 a zip file"))
+	case "/html":
+		w.Write([]byte("<html><body>Download</body></html>"))
+	default:
+		http.NotFound(w, r)
+	}
+}))
+
+func TestResolve(t *testing.T) {
+
+	zipKind := testKind("zip")
+	zipKind.Extensions = []string{".zip"}
+	zipKind.MimeTypes = []string{"application/zip"}
+
+	gzKind := testKind("gz")
+	gzKind.Extensions = []string{".gz"}
+
+	tarKind := testKind("tar")
+	tarKind.Aliases = []string{"tgz"}
+	tarKind.Extensions = []string{".tar.gz", ".tgz"}
+	tarKind.MimeTypes = []string{"application/x-gzip"}
+
+	localKind := testKind("local")
+	localKind.Schemes = []string{"file"}
+
+	defer withKinds(zipKind, gzKind, tarKind, localKind)()
+
+	tests := []struct {
+		name       string
+		url        string
+		sourceType string
+		sniff      func(string) (string, string, error)
+		wantKind   string
+		wantErr    bool
+	}{
+		{
+			"Extension",
+			"http://example.local/plugin.zip",
+			"",
+			nil,
+			"zip",
+			false,
+		},
+		{
+			"Longest Extension",
+			"http://example.local/plugin.tar.gz",
+			"",
+			nil,
+			"tar",
+			false,
+		},
+		{
+			"Extension In Query",
+			"https://example.com/download?file=plugin.zip&v=2",
+			"",
+			nil,
+			"zip",
+			false,
+		},
+		{
+			"Source Type",
+			"https://example.com/download",
+			"zip",
+			nil,
+			"zip",
+			false,
+		},
+		{
+			"Source Type Alias",
+			"https://example.com/download",
+			"TGZ",
+			nil,
+			"tar",
+			false,
+		},
+		{
+			"Source Type Wins Over Extension",
+			"http://example.local/plugin.zip",
+			"tar",
+			nil,
+			"tar",
+			false,
+		},
+		{
+			"Scheme Wins Over Source Type",
+			"file:///srv/plugin.zip",
+			"zip",
+			nil,
+			"local",
+			false,
+		},
+		{
+			"Sniffed Content Type",
+			sniffServer.URL + "/typed",
+			"",
+			nil,
+			"tar",
+			false,
+		},
+		{
+			"Sniffed Filename",
+			sniffServer.URL + "/attachment",
+			"",
+			nil,
+			"tar",
+			false,
+		},
+		{
+			"Sniffed Content",
+			sniffServer.URL + "/untyped",
+			"",
+			nil,
+			"zip",
+			false,
+		},
+		{
+			"Unknown Content",
+			sniffServer.URL + "/html",
+			"",
+			nil,
+			"",
+			true,
+		},
+		{
+			"Not Found",
+			sniffServer.URL + "/missing",
+			"",
+			nil,
+			"",
+			true,
+		},
+		{
+			"Sniff Error",
+			"http://example.local/download",
+			"",
+			func(string) (string, string, error) {
+				return "", "", errors.New("something went wrong")
+			},
+			"",
+			true,
+		},
+		{
+			"Unknown Scheme",
+			"svn://example.local/plugin",
+			"",
+			nil,
+			"",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			if tt.sniff != nil {
+				oldSniff := sniffURL
+				sniffURL = tt.sniff
+				defer func() {
+					sniffURL = oldSniff
+				}()
+			}
+
+			got, err := Resolve(tt.url, tt.sourceType)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Resolve() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				if !strings.Contains(err.Error(), "gz, local, tar, zip") {
+					t.Errorf("Resolve() error = %v, want supported kinds listed", err)
+				}
+				return
+			}
+
+			src := got.(kindSource)
+			if src.kind != tt.wantKind {
+				t.Errorf("Resolve() kind = %v, want %v", src.kind, tt.wantKind)
+			}
+			if src.url != tt.url {
+				t.Errorf("Resolve() url = %v, want %v", src.url, tt.url)
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer withKinds()()
+
+	Register(testKind("zip"))
+	Register(testKind("git"))
+
+	if got := strings.Join(Kinds(), ","); got != "git,zip" {
+		t.Errorf("Kinds() = %v, want %v", got, "git,zip")
+	}
+
+	tests := []struct {
+		name string
+		kind Kind
+	}{
+		{"Duplicate Name", testKind("zip")},
+		{"No Name", testKind("")},
+		{"No Factory", Kind{Name: "tar"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register() did not panic")
+				}
+			}()
+			Register(tt.kind)
+		})
+	}
+}
@@ -1,6 +1,10 @@
 package source
 
 import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -11,7 +15,13 @@ type Source interface {
 	GetFiles() []string
 }
 
+// Revisioner is implemented by version control sources that know which revision they prepared.
+type Revisioner interface {
+	GetRevision() string
+}
+
 // GetKind uses basic string manipulation to get the type of source file.
+// Use Resolve to find the Source for a url.
 func GetKind(url string) string {
 	var kind string
 	ts := strings.Split(url, ".")
@@ -20,3 +30,14 @@ func GetKind(url string) string {
 	}
 	return kind
 }
+
+// CombinedChecksum calculates a single checksum from a list of file checksums.
+// A sorted copy of the list is used so that the result does not depend on file order,
+// and sums still lines up with the files it was calculated from.
+// Uses the same technique as Tide Audit Server.
+func CombinedChecksum(sums []string) string {
+	sorted := append([]string(nil), sums...)
+	sort.Strings(sorted)
+	jsonChecksums, _ := json.Marshal(sorted)
+	return fmt.Sprintf("%x", sha256.Sum256(jsonChecksums))
+}
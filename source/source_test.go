@@ -30,3 +30,49 @@ func TestGetKind(t *testing.T) {
 		})
 	}
 }
+
+func TestCombinedChecksum(t *testing.T) {
+	type args struct {
+		sums []string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			"Sorted",
+			args{
+				[]string{
+					"27dd8ed44a83ff94d557f9fd0412ed5a8cbca69ea04922d88c01184a07300a5a",
+					"2c8b08da5ce60398e1f19af0e5dccc744df274b826abe585eaba68c525434806",
+					"f6936912184481f5edd4c304ce27c5a1a827804fc7f329f43d273b8621870776",
+				},
+			},
+			"5a0c0a95d189c266ca1ed43767dd98f3fb513ce3434e2b08f34828ac11e79a94",
+		},
+		{
+			"Unsorted",
+			args{
+				[]string{
+					"f6936912184481f5edd4c304ce27c5a1a827804fc7f329f43d273b8621870776",
+					"27dd8ed44a83ff94d557f9fd0412ed5a8cbca69ea04922d88c01184a07300a5a",
+					"2c8b08da5ce60398e1f19af0e5dccc744df274b826abe585eaba68c525434806",
+				},
+			},
+			"5a0c0a95d189c266ca1ed43767dd98f3fb513ce3434e2b08f34828ac11e79a94",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sums := append([]string(nil), tt.args.sums...)
+			if got := CombinedChecksum(tt.args.sums); got != tt.want {
+				t.Errorf("CombinedChecksum() = %v, want %v", got, tt.want)
+			}
+			// The sums must still line up with the files they were calculated from.
+			if !reflect.DeepEqual(tt.args.sums, sums) {
+				t.Errorf("CombinedChecksum() reordered the sums to %v", tt.args.sums)
+			}
+		})
+	}
+}
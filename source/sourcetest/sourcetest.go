@@ -0,0 +1,44 @@
+// Package sourcetest checks the files prepared by sources.
+package sourcetest
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/wptide/pkg/source"
+)
+
+// CheckManifest fails the test unless every entry of the manifest of src describes the file
+// at its path under root, and the manifest checksum is the checksum of its entries.
+func CheckManifest(t *testing.T, src source.Manifester, root string) {
+	t.Helper()
+
+	manifest := src.GetManifest()
+	if len(manifest.Files) == 0 {
+		t.Errorf("GetManifest() has no files")
+	}
+
+	var sums []string
+	for _, entry := range manifest.Files {
+		data, err := ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(entry.Path)))
+		if err != nil {
+			t.Errorf("GetManifest() entry %q: %v", entry.Path, err)
+			continue
+		}
+
+		if sum := fmt.Sprintf("%x", sha256.Sum256(data)); entry.SHA256 != sum {
+			t.Errorf("GetManifest() entry %q sha256 = %v, want %v", entry.Path, entry.SHA256, sum)
+		}
+		if entry.Size != int64(len(data)) {
+			t.Errorf("GetManifest() entry %q size = %d, want %d", entry.Path, entry.Size, len(data))
+		}
+		sums = append(sums, entry.SHA256)
+	}
+
+	if want := source.CombinedChecksum(sums); manifest.Checksum != want {
+		t.Errorf("GetManifest() checksum = %v, want %v", manifest.Checksum, want)
+	}
+}
@@ -0,0 +1,229 @@
+package svn
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/wptide/pkg/shell"
+	"github.com/wptide/pkg/source"
+)
+
+// Svn describes a Subversion repository laid out with trunk/, tags/ and branches/
+// folders, like the plugin repositories on plugins.svn.wordpress.org.
+type Svn struct {
+	url      string
+	path     string
+	revision string
+	dest     string
+	files    []string
+	checksum string
+	manifest source.Manifest
+	runner   shell.Runner
+}
+
+var (
+	// File system operation variables.
+	removeAll = os.RemoveAll
+	fileOpen  = os.Open
+	ioCopy    = io.Copy
+
+	// Default paths.
+	exportFolder = "unzipped"
+)
+
+func init() {
+	source.Register(source.Kind{
+		Name:    "svn",
+		Aliases: []string{"subversion"},
+		Schemes: []string{"svn", "svn+ssh"},
+		New: func(url string) source.Source {
+			return NewSvn(url)
+		},
+	})
+}
+
+// PrepareFiles exports trunk, a tag or a branch into a given destination and extracts info about its files.
+func (m *Svn) PrepareFiles(dest string) error {
+
+	// Prepare destination.
+	m.dest = dest
+	if _, err := os.Stat(m.dest); os.IsNotExist(err) {
+		os.Mkdir(m.dest, os.ModePerm)
+	}
+
+	if m.runner == nil {
+		m.runner = &shell.Command{}
+	}
+
+	export := m.dest + "/" + exportFolder
+
+	// Export refuses to write into an existing folder, so start clean.
+	if err := removeAll(export); err != nil {
+		return err
+	}
+
+	// Pin the revision first so that the export and the recorded revision match.
+	peg := m.revision
+	if peg == "" {
+		peg = "HEAD"
+	}
+	target := m.url + "/" + m.path + "@" + peg
+
+	info, err := m.svn("info", "--show-item", "revision", "--", target)
+	if err != nil {
+		return err
+	}
+	revision := strings.TrimSpace(string(info))
+	if _, err := strconv.Atoi(revision); err != nil {
+		return errors.New("could not resolve revision for " + target)
+	}
+
+	// Externals can point anywhere, they are not part of the code to audit.
+	target = m.url + "/" + m.path + "@" + revision
+	if _, err := m.svn("export", "--quiet", "--force", "--ignore-externals", "--", target, export); err != nil {
+		return err
+	}
+	m.revision = revision
+
+	var checksums []string
+	m.files, checksums, err = hashFiles(export)
+	if err != nil {
+		return err
+	}
+
+	// Calculate checksum - uses same technique as Tide Audit Server.
+	m.checksum = source.CombinedChecksum(checksums)
+
+	// Keep the details of every file.
+	m.manifest, err = source.NewManifest(export, m.files, checksums)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetChecksum returns the combined checksum for the exported files.
+func (m Svn) GetChecksum() string {
+	return m.checksum
+}
+
+// GetFiles returns the exported files.
+func (m Svn) GetFiles() []string {
+	return m.files
+}
+
+// GetManifest returns the details of the exported files.
+func (m Svn) GetManifest() source.Manifest {
+	return m.manifest
+}
+
+// GetRevision returns the revision that was exported.
+func (m Svn) GetRevision() string {
+	return m.revision
+}
+
+// NewSvn returns a new Svn source for the root of a repository.
+//
+// What to export can be given as a URL fragment: "trunk" (the default),
+// "tags/<version>" or "branches/<name>", optionally followed by "@<revision>",
+// e.g. "https://plugins.svn.wordpress.org/akismet#tags/5.3" or
+// "svn://svn.example.com/plugin#trunk@1234".
+func NewSvn(url string) *Svn {
+	fragment := ""
+	if i := strings.LastIndex(url, "#"); i != -1 {
+		url, fragment = url[:i], url[i+1:]
+	}
+
+	path, revision := fragment, ""
+	if i := strings.LastIndex(fragment, "@"); i != -1 {
+		path, revision = fragment[:i], fragment[i+1:]
+	}
+	if path == "" {
+		path = "trunk"
+	}
+
+	return &Svn{
+		url:      strings.TrimSuffix(url, "/"),
+		path:     strings.Trim(path, "/"),
+		revision: revision,
+	}
+}
+
+// svn runs a non-interactive svn command and turns a failure into an error containing svn's own output.
+func (m *Svn) svn(command string, arg ...string) ([]byte, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+
+	args := append([]string{command, "--non-interactive"}, arg...)
+	stdOut, stdErr, _, err := m.runner.Run("svn", args...)
+	if err != nil {
+		msg := strings.TrimSpace(string(stdErr))
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("svn %s: %s", command, msg)
+	}
+	return stdOut, nil
+}
+
+// validate rejects paths and revisions that svn could misread.
+func (m *Svn) validate() error {
+	if m.path != "trunk" && !strings.HasPrefix(m.path, "tags/") && !strings.HasPrefix(m.path, "branches/") {
+		return errors.New("invalid svn path, expected trunk, tags/<version> or branches/<name>: " + m.path)
+	}
+	for _, part := range strings.Split(m.path, "/") {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, "@") {
+			return errors.New("invalid svn path: " + m.path)
+		}
+	}
+	if m.revision != "" {
+		if _, err := strconv.Atoi(m.revision); err != nil && m.revision != "HEAD" {
+			return errors.New("invalid svn revision: " + m.revision)
+		}
+	}
+	return nil
+}
+
+// hashFiles calculates the checksum of each regular file under root.
+func hashFiles(root string) (filenames, checksums []string, err error) {
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Skip folders and svn:special files (symlinks).
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		file, err := fileOpen(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		h := sha256.New()
+		if _, err := ioCopy(h, file); err != nil {
+			return err
+		}
+
+		filenames = append(filenames, path)
+		checksums = append(checksums, fmt.Sprintf("%x", h.Sum(nil)))
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return filenames, checksums, nil
+}
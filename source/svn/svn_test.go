@@ -0,0 +1,222 @@
+package svn
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wptide/pkg/source"
+	"github.com/wptide/pkg/source/sourcetest"
+)
+
+// mockRunner pretends to be svn, exporting a small plugin at revision 1234.
+type mockRunner struct {
+	calls    [][]string
+	failInfo bool
+	badInfo  bool
+}
+
+func (m *mockRunner) Run(name string, arg ...string) ([]byte, []byte, int, error) {
+	m.calls = append(m.calls, append([]string{name}, arg...))
+
+	switch arg[0] {
+	case "info":
+		if m.failInfo {
+			return nil, []byte("svn: E170000: URL doesn't exist"), 1, errors.New("exit status 1")
+		}
+		if m.badInfo {
+			return []byte("not a revision\n"), nil, 0, nil
+		}
+		return []byte("1234\n"), nil, 0, nil
+	case "export":
+		dest := arg[len(arg)-1]
+		os.MkdirAll(filepath.Join(dest, "inc"), os.ModePerm)
+		ioutil.WriteFile(filepath.Join(dest, "plugin.php"), []byte("<?php\n/* Plugin Name: Svn Plugin */\n"), 0644)
+		ioutil.WriteFile(filepath.Join(dest, "inc", "functions.php"), []byte("<?php\n"), 0644)
+		ioutil.WriteFile(filepath.Join(dest, "readme.txt"), []byte("Stable tag: 1.0\n"), 0644)
+		os.Symlink("plugin.php", filepath.Join(dest, "link.php"))
+		return nil, nil, 0, nil
+	}
+
+	return nil, nil, 1, errors.New("unknown command")
+}
+
+func TestNewSvn(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want *Svn
+	}{
+		{
+			"Trunk",
+			"https://plugins.svn.wordpress.org/akismet/",
+			&Svn{url: "https://plugins.svn.wordpress.org/akismet", path: "trunk"},
+		},
+		{
+			"Tag",
+			"https://plugins.svn.wordpress.org/akismet#tags/5.3",
+			&Svn{url: "https://plugins.svn.wordpress.org/akismet", path: "tags/5.3"},
+		},
+		{
+			"Trunk At Revision",
+			"svn://svn.example.local/plugin#trunk@1234",
+			&Svn{url: "svn://svn.example.local/plugin", path: "trunk", revision: "1234"},
+		},
+		{
+			"Revision Only",
+			"svn://svn.example.local/plugin#@1234",
+			&Svn{url: "svn://svn.example.local/plugin", path: "trunk", revision: "1234"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewSvn(tt.url); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewSvn() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSvn_PrepareFiles(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		url          string
+		runner       *mockRunner
+		wantCalls    [][]string
+		wantRevision string
+		wantErr      bool
+	}{
+		{
+			"Trunk",
+			"https://plugins.svn.wordpress.org/svn-plugin",
+			&mockRunner{},
+			[][]string{
+				{"svn", "info", "--non-interactive", "--show-item", "revision", "--", "https://plugins.svn.wordpress.org/svn-plugin/trunk@HEAD"},
+				{"svn", "export", "--non-interactive", "--quiet", "--force", "--ignore-externals", "--", "https://plugins.svn.wordpress.org/svn-plugin/trunk@1234", "DEST/unzipped"},
+			},
+			"1234",
+			false,
+		},
+		{
+			"Tag At Revision",
+			"https://plugins.svn.wordpress.org/svn-plugin#tags/1.0@1200",
+			&mockRunner{},
+			[][]string{
+				{"svn", "info", "--non-interactive", "--show-item", "revision", "--", "https://plugins.svn.wordpress.org/svn-plugin/tags/1.0@1200"},
+				{"svn", "export", "--non-interactive", "--quiet", "--force", "--ignore-externals", "--", "https://plugins.svn.wordpress.org/svn-plugin/tags/1.0@1234", "DEST/unzipped"},
+			},
+			"1234",
+			false,
+		},
+		{
+			"Missing Path",
+			"https://plugins.svn.wordpress.org/svn-plugin#tags/9.9",
+			&mockRunner{failInfo: true},
+			nil,
+			"",
+			true,
+		},
+		{
+			"Invalid Revision Output",
+			"https://plugins.svn.wordpress.org/svn-plugin",
+			&mockRunner{badInfo: true},
+			nil,
+			"",
+			true,
+		},
+		{
+			"Invalid Path",
+			"https://plugins.svn.wordpress.org/svn-plugin#../../other",
+			&mockRunner{},
+			nil,
+			"",
+			true,
+		},
+		{
+			"Not A Layout Path",
+			"https://plugins.svn.wordpress.org/svn-plugin#assets",
+			&mockRunner{},
+			nil,
+			"",
+			true,
+		},
+		{
+			"Invalid Revision",
+			"https://plugins.svn.wordpress.org/svn-plugin#trunk@--force",
+			&mockRunner{},
+			nil,
+			"",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			dest, err := ioutil.TempDir("", "tide-svn")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dest)
+
+			m := NewSvn(tt.url)
+			m.runner = tt.runner
+
+			err = m.PrepareFiles(dest)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Svn.PrepareFiles() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+
+			for _, call := range tt.wantCalls {
+				for i := range call {
+					call[i] = strings.Replace(call[i], "DEST", dest, 1)
+				}
+			}
+			if !reflect.DeepEqual(tt.runner.calls, tt.wantCalls) {
+				t.Errorf("Svn.PrepareFiles() calls = %v, want %v", tt.runner.calls, tt.wantCalls)
+			}
+
+			if got := m.GetRevision(); got != tt.wantRevision {
+				t.Errorf("Svn.GetRevision() = %v, want %v", got, tt.wantRevision)
+			}
+
+			wantFiles := []string{
+				filepath.Join(dest, "unzipped", "inc", "functions.php"),
+				filepath.Join(dest, "unzipped", "plugin.php"),
+				filepath.Join(dest, "unzipped", "readme.txt"),
+			}
+			if got := m.GetFiles(); !reflect.DeepEqual(got, wantFiles) {
+				t.Errorf("Svn.GetFiles() = %v, want %v", got, wantFiles)
+			}
+
+			wantChecksum := "141847671bdd66ecc3682e018d298e93c241f83223c0cedf1671ec3e179d05d8"
+			if got := m.GetChecksum(); got != wantChecksum {
+				t.Errorf("Svn.GetChecksum() = %v, want %v", got, wantChecksum)
+			}
+			if m.GetManifest().Checksum != m.GetChecksum() {
+				t.Errorf("Svn.GetManifest() checksum = %v, want %v", m.GetManifest().Checksum, m.GetChecksum())
+			}
+			sourcetest.CheckManifest(t, m, filepath.Join(dest, "unzipped"))
+		})
+	}
+}
+
+func TestSvn_Resolve(t *testing.T) {
+	for _, location := range []string{"svn://svn.example.local/plugin#trunk", "svn+ssh://svn.example.local/plugin"} {
+		got, err := source.Resolve(location, "")
+		if err != nil {
+			t.Fatalf("source.Resolve() error = %v", err)
+		}
+		if _, ok := got.(*Svn); !ok {
+			t.Errorf("source.Resolve() = %T, want *Svn", got)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package tar
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wptide/pkg/source"
+)
+
+// Tar describes a tar archive, optionally compressed with gzip or bzip2.
+type Tar struct {
+	url        string
+	dest       string
+	files      []string
+	checksum   string
+	manifest   source.Manifest
+	limits     *source.Limits
+	downloader *source.Downloader
+}
+
+var (
+	// File system operation variables.
+	makeDirectoryAll = os.MkdirAll
+	ioCopy           = io.Copy
+	openFile         = os.OpenFile
+	symlink          = os.Symlink
+
+	// Default paths.
+	sourceFilename = "source.tar"
+
+	// Magic numbers of supported compression formats.
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+)
+
+func init() {
+	source.Register(source.Kind{
+		Name:       "tar",
+		Aliases:    []string{"tgz", "tar.gz", "tar.bz2"},
+		Extensions: []string{".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".tbz"},
+		MimeTypes: []string{
+			"application/x-tar",
+			"application/x-gtar",
+			"application/gzip",
+			"application/x-gzip",
+			"application/x-bzip2",
+		},
+		New: func(url string) source.Source {
+			return NewTar(url)
+		},
+	})
+}
+
+// PrepareFiles downloads a tar archive to a given destination and extracts info about the files in the archive.
+func (m *Tar) PrepareFiles(dest string) error {
+	return m.PrepareFilesContext(context.Background(), dest)
+}
+
+// PrepareFilesContext is PrepareFiles with a context to cancel the download.
+func (m *Tar) PrepareFilesContext(ctx context.Context, dest string) error {
+
+	// Prepare destination.
+	m.dest = dest
+	if _, err := os.Stat(m.dest); os.IsNotExist(err) {
+		os.Mkdir(m.dest, os.ModePerm)
+	}
+
+	downloader := source.DefaultDownloader
+	if m.downloader != nil {
+		downloader = m.downloader
+	}
+
+	err := downloader.Download(ctx, m.url, m.dest+"/"+sourceFilename)
+	if err != nil {
+		return err
+	}
+
+	limits := source.DefaultLimits
+	if m.limits != nil {
+		limits = *m.limits
+	}
+
+	var checksums []string
+	m.files, checksums, err = untar(m.dest+"/"+sourceFilename, m.dest+"/unzipped", limits)
+	if err != nil {
+		return err
+	}
+
+	// Calculate checksum - uses same technique as Tide Audit Server.
+	m.checksum = source.CombinedChecksum(checksums)
+
+	// Keep the details of every file.
+	m.manifest, err = source.NewManifest(m.dest+"/unzipped", m.files, checksums)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetChecksum returns the combined checksum for the tar archive.
+func (m Tar) GetChecksum() string {
+	return m.checksum
+}
+
+// GetFiles returns the files contained in the tar archive.
+func (m Tar) GetFiles() []string {
+	return m.files
+}
+
+// GetManifest returns the details of the files in the tar archive.
+func (m Tar) GetManifest() source.Manifest {
+	return m.manifest
+}
+
+// SetLimits replaces source.DefaultLimits for this tar archive.
+func (m *Tar) SetLimits(limits source.Limits) {
+	m.limits = &limits
+}
+
+// SetDownloader replaces source.DefaultDownloader for this tar archive.
+func (m *Tar) SetDownloader(downloader *source.Downloader) {
+	m.downloader = downloader
+}
+
+// NewTar returns a new Tar source.
+func NewTar(url string) *Tar {
+	return &Tar{
+		url: url,
+	}
+}
+
+// openArchive opens the uncompressed stream of a tar archive, detecting gzip or bzip2 compression from the content.
+// The bytes read from the file are counted by the extraction, so that MaxRatio applies to the whole stream.
+func openArchive(archive string, extraction *source.Extraction) (io.Reader, io.Closer, error) {
+	file, err := os.Open(archive)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	buffered := bufio.NewReader(extraction.Stream(file))
+	magic, _ := buffered.Peek(3)
+
+	var reader io.Reader = buffered
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		reader = gz
+	case bytes.HasPrefix(magic, bzip2Magic):
+		reader = bzip2.NewReader(buffered)
+	}
+
+	return reader, file, nil
+}
+
+// rootFolder finds the shortest folder in the archive. Its contents get extracted
+// straight into the destination, the same way as zip archives. The scan decompresses
+// the whole archive, so it stops once the archive breaks the limits. The headers of
+// the entries count towards MaxBytes as well.
+func rootFolder(archive string, limits source.Limits) (string, error) {
+	extraction := source.NewExtraction("", limits)
+
+	stream, closer, err := openArchive(archive, extraction)
+	if err != nil {
+		return "", err
+	}
+	defer closer.Close()
+
+	reader := tar.NewReader(extraction.Reader(filepath.Base(archive), stream, 0))
+
+	rootPath := ""
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if header.Typeflag == tar.TypeReg || header.Typeflag == tar.TypeRegA {
+			if err := extraction.File(header.Name); err != nil {
+				return "", err
+			}
+		}
+
+		path := header.Name
+		if header.Typeflag != tar.TypeDir {
+			continue
+		}
+		if len(path) < len(rootPath) || rootPath == "" {
+			rootPath = path
+		}
+	}
+
+	return rootPath, nil
+}
+
+// untar will un-compress a tar archive,
+// moving all files and folders to a destination directory.
+// Entries that break the limits or would be written outside
+// of the destination return a *source.ArchiveError. The size
+// of compressed entries is unknown, so MaxRatio applies to the
+// files together against the compressed archive.
+func untar(archive, destination string, limits source.Limits) (filenames, checksums []string, err error) {
+	rootPath, err := rootFolder(archive, limits)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	extraction := source.NewExtraction(destination, limits)
+
+	stream, closer, err := openArchive(archive, extraction)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer closer.Close()
+
+	reader := tar.NewReader(stream)
+
+	if err := makeDirectoryAll(destination, 0755); err != nil {
+		return nil, nil, err
+	}
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		path, err := extraction.Path(strings.TrimPrefix(header.Name, rootPath))
+		if err != nil {
+			return nil, nil, err
+		}
+		mode := header.FileInfo().Mode()
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			makeDirectoryAll(path, mode.Perm()|0700)
+			continue
+		case tar.TypeSymlink:
+			create, err := extraction.Symlink(header.Name, path, header.Linkname)
+			if err != nil {
+				return nil, nil, err
+			}
+			if create {
+				if err := symlink(header.Linkname, path); err != nil {
+					return nil, nil, err
+				}
+			}
+			continue
+		case tar.TypeReg, tar.TypeRegA:
+		default:
+			// Skip hard links, devices and pax/global headers.
+			continue
+		}
+
+		if err := extraction.File(header.Name); err != nil {
+			return nil, nil, err
+		}
+
+		// Archives don't always contain entries for parent folders.
+		if err := makeDirectoryAll(filepath.Dir(path), 0755); err != nil {
+			return nil, nil, err
+		}
+
+		filenames = append(filenames, path)
+
+		targetFile, err := openFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Write the file and calculate its checksum in one pass.
+		h := sha256.New()
+		limited := extraction.Reader(header.Name, reader, 0)
+		if _, err := ioCopy(io.MultiWriter(targetFile, h), limited); err != nil {
+			targetFile.Close()
+			return nil, nil, err
+		}
+		targetFile.Close()
+
+		checksums = append(checksums, fmt.Sprintf("%x", h.Sum(nil)))
+	}
+
+	return filenames, checksums, nil
+}
@@ -0,0 +1,512 @@
+package tar
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/wptide/pkg/source"
+	"github.com/wptide/pkg/source/sourcetest"
+)
+
+var fileServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.String() {
+	case "/test.tar.gz":
+		w.Header().Set("Content-Type", "application/gzip")
+		http.ServeFile(w, r, "./testdata/test.tar.gz")
+	case "/test.tar.bz2":
+		w.Header().Set("Content-Type", "application/x-bzip2")
+		http.ServeFile(w, r, "./testdata/test.tar.bz2")
+	case "/error.tar.gz":
+		w.Write([]byte{0x1f, 0x8b, 0x00})
+	default:
+		http.NotFound(w, r)
+	}
+}))
+
+// Same checksums as the files in source/zip/testdata/test.zip, so both
+// archives produce the same combined checksum.
+var wantChecksums = []string{
+	"09679b8abb88b21dd1cf166e1d2745df7882a879d2b8672548f6dc0dc9572fe6",
+	"64a43b6ce686b50bbd7eb91b2b1346ed66e7053d42f7f7b9d5562d55a25d1321",
+	"9a8549c5d1f384593788dc25b1c236f8450534e8cb95833003786fef8201b92b",
+}
+
+func TestTar_GetChecksum(t *testing.T) {
+
+	checksum := "5a0c0a95d189c266ca1ed43767dd98f3fb513ce3434e2b08f34828ac11e79a94"
+
+	// Should be impossible to fail.
+	t.Run("Get Checksum", func(t *testing.T) {
+		m := Tar{
+			checksum: checksum,
+		}
+		if got := m.GetChecksum(); got != checksum {
+			t.Errorf("Tar.GetChecksum() = %v, want %v", got, checksum)
+		}
+	})
+}
+
+func TestTar_GetFiles(t *testing.T) {
+
+	files := []string{
+		"file1.txt",
+		"file2.txt",
+		"file3.txt",
+	}
+
+	// Should be impossible to fail.
+	t.Run("Get Files", func(t *testing.T) {
+		m := Tar{
+			files: files,
+		}
+		if got := m.GetFiles(); !reflect.DeepEqual(got, files) {
+			t.Errorf("Tar.GetFiles() = %v, want %v", got, files)
+		}
+	})
+}
+
+func Test_untar(t *testing.T) {
+
+	// Clean up after.
+	defer func() {
+		os.RemoveAll("./testdata/unzipped")
+	}()
+
+	errorDirectoryCreate := func(path string, perm os.FileMode) error {
+		return errors.New("something went wrong")
+	}
+
+	errorCopyFile := func(dst io.Writer, src io.Reader) (written int64, err error) {
+		return 0, errors.New("something went wrong")
+	}
+
+	errorOpenFile := func(name string, flag int, perm os.FileMode) (*os.File, error) {
+		return nil, errors.New("something went wrong")
+	}
+
+	files := []string{
+		"testdata/unzipped/function.php",
+		"testdata/unzipped/script.js",
+		"testdata/unzipped/style.css",
+	}
+
+	type args struct {
+		source           string
+		destination      string
+		makeDirectoryAll func(path string, perm os.FileMode) error
+		ioCopy           func(dst io.Writer, src io.Reader) (written int64, err error)
+		openFile         func(name string, flag int, perm os.FileMode) (*os.File, error)
+	}
+	tests := []struct {
+		name          string
+		args          args
+		wantFilenames []string
+		wantChecksums []string
+		wantErr       bool
+	}{
+		{
+			"Untar - Uncompressed",
+			args{
+				source:      "./testdata/test.tar",
+				destination: "./testdata/unzipped",
+			},
+			files,
+			wantChecksums,
+			false,
+		},
+		{
+			"Untar - Gzip",
+			args{
+				source:      "./testdata/test.tar.gz",
+				destination: "./testdata/unzipped",
+			},
+			files,
+			wantChecksums,
+			false,
+		},
+		{
+			"Untar - Bzip2",
+			args{
+				source:      "./testdata/test.tar.bz2",
+				destination: "./testdata/unzipped",
+			},
+			files,
+			wantChecksums,
+			false,
+		},
+		{
+			"Untar - Missing File",
+			args{
+				source:      "./testdata/error.tar.gz",
+				destination: "./testdata/unzipped",
+			},
+			nil,
+			nil,
+			true,
+		},
+		{
+			"Untar - Failed Directory Create",
+			args{
+				source:           "./testdata/test.tar.gz",
+				destination:      "./testdata/unzipped",
+				makeDirectoryAll: errorDirectoryCreate,
+			},
+			nil,
+			nil,
+			true,
+		},
+		{
+			"Untar - Fail Open Target File",
+			args{
+				source:      "./testdata/test.tar.gz",
+				destination: "./testdata/unzipped",
+				openFile:    errorOpenFile,
+			},
+			nil,
+			nil,
+			true,
+		},
+		{
+			"Untar - Fail Copy to Target File",
+			args{
+				source:      "./testdata/test.tar.gz",
+				destination: "./testdata/unzipped",
+				ioCopy:      errorCopyFile,
+			},
+			nil,
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// Test create directory.
+			if tt.args.makeDirectoryAll != nil {
+				oldMakeDirectoryAll := makeDirectoryAll
+				makeDirectoryAll = tt.args.makeDirectoryAll
+				defer func() {
+					makeDirectoryAll = oldMakeDirectoryAll
+				}()
+			}
+
+			// Test io.Copy error.
+			if tt.args.ioCopy != nil {
+				oldCopy := ioCopy
+				ioCopy = tt.args.ioCopy
+				defer func() {
+					ioCopy = oldCopy
+				}()
+			}
+
+			// Test open file error.
+			if tt.args.openFile != nil {
+				oldOpenFile := openFile
+				openFile = tt.args.openFile
+				defer func() {
+					openFile = oldOpenFile
+				}()
+			}
+
+			gotFilenames, gotChecksums, err := untar(tt.args.source, tt.args.destination, source.DefaultLimits)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("untar() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			// Tar archives are not ordered, so compare sorted results.
+			sort.Strings(gotFilenames)
+			sort.Strings(gotChecksums)
+
+			if !reflect.DeepEqual(gotFilenames, tt.wantFilenames) {
+				t.Errorf("untar() gotFilenames = %v, want %v", gotFilenames, tt.wantFilenames)
+			}
+			if !reflect.DeepEqual(gotChecksums, tt.wantChecksums) {
+				t.Errorf("untar() gotChecksums = %v, want %v", gotChecksums, tt.wantChecksums)
+			}
+		})
+	}
+}
+
+func Test_untar_limits(t *testing.T) {
+
+	root, err := ioutil.TempDir("", "tide-tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Clean up after.
+	defer os.RemoveAll(root)
+
+	type entry struct {
+		header *tar.Header
+		body   string
+	}
+
+	tests := []struct {
+		name          string
+		entries       []entry
+		limits        source.Limits
+		wantViolation string
+	}{
+		{
+			"Path Traversal",
+			[]entry{{&tar.Header{Name: "../evil.php", Mode: 0644, Size: 6, Typeflag: tar.TypeReg}, "<?php\n"}},
+			source.DefaultLimits,
+			source.ViolationPathTraversal,
+		},
+		{
+			"Too Many Bytes",
+			[]entry{{&tar.Header{Name: "plugin/big.php", Mode: 0644, Size: 2048, Typeflag: tar.TypeReg}, strings.Repeat("a", 2048)}},
+			source.Limits{MaxBytes: 1024},
+			source.ViolationMaxBytes,
+		},
+		{
+			"Symlink Outside",
+			[]entry{{&tar.Header{Name: "plugin/passwd", Linkname: "/etc/passwd", Typeflag: tar.TypeSymlink}, ""}},
+			source.Limits{Symlinks: source.SymlinkExtract},
+			source.ViolationSymlink,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			w := tar.NewWriter(buf)
+			for _, e := range tt.entries {
+				w.WriteHeader(e.header)
+				w.Write([]byte(e.body))
+			}
+			w.Close()
+
+			archive := filepath.Join(root, "test.tar")
+			ioutil.WriteFile(archive, buf.Bytes(), 0644)
+
+			_, _, err := untar(archive, filepath.Join(root, "unzipped"), tt.limits)
+
+			archiveErr, ok := err.(*source.ArchiveError)
+			if !ok {
+				t.Errorf("untar() error = %v, want *source.ArchiveError", err)
+				return
+			}
+			if archiveErr.Violation != tt.wantViolation {
+				t.Errorf("untar() violation = %v, want %v", archiveErr.Violation, tt.wantViolation)
+			}
+			if _, err := os.Stat(filepath.Join(root, "evil.php")); !os.IsNotExist(err) {
+				t.Errorf("untar() wrote outside of the destination")
+			}
+		})
+	}
+}
+
+func Test_rootFolder_limits(t *testing.T) {
+
+	root, err := ioutil.TempDir("", "tide-tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Clean up after.
+	defer os.RemoveAll(root)
+
+	buf := &bytes.Buffer{}
+	w := tar.NewWriter(buf)
+	w.WriteHeader(&tar.Header{Name: "plugin/", Mode: 0755, Typeflag: tar.TypeDir})
+	for _, name := range []string{"plugin/a.php", "plugin/b.php", "plugin/c.php"} {
+		w.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 2048, Typeflag: tar.TypeReg})
+		w.Write([]byte(strings.Repeat("a", 2048)))
+	}
+	w.Close()
+
+	archive := filepath.Join(root, "test.tar")
+	ioutil.WriteFile(archive, buf.Bytes(), 0644)
+
+	tests := []struct {
+		name          string
+		limits        source.Limits
+		wantViolation string
+	}{
+		{"Within Limits", source.DefaultLimits, ""},
+		{"Too Many Files", source.Limits{MaxFiles: 2}, source.ViolationMaxFiles},
+		{"Too Many Bytes", source.Limits{MaxBytes: 4096}, source.ViolationMaxBytes},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rootFolder(archive, tt.limits)
+			if tt.wantViolation == "" {
+				if err != nil || got != "plugin/" {
+					t.Errorf("rootFolder() = %v, %v, want plugin/", got, err)
+				}
+				return
+			}
+			if archiveErr, ok := err.(*source.ArchiveError); !ok || archiveErr.Violation != tt.wantViolation {
+				t.Errorf("rootFolder() error = %v, want violation %v", err, tt.wantViolation)
+			}
+		})
+	}
+}
+
+func Test_untar_ratio(t *testing.T) {
+
+	root, err := ioutil.TempDir("", "tide-tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Clean up after.
+	defer os.RemoveAll(root)
+
+	// A tgz bomb: 8MB of zeros compress to a few KB.
+	const size = 8 << 20
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	w := tar.NewWriter(gz)
+	w.WriteHeader(&tar.Header{Name: "plugin/bomb.php", Mode: 0644, Size: size, Typeflag: tar.TypeReg})
+	w.Write(make([]byte, size))
+	w.Close()
+	gz.Close()
+
+	archive := filepath.Join(root, "test.tar.gz")
+	ioutil.WriteFile(archive, buf.Bytes(), 0644)
+
+	_, _, err = untar(archive, filepath.Join(root, "unzipped"), source.DefaultLimits)
+
+	if archiveErr, ok := err.(*source.ArchiveError); !ok || archiveErr.Violation != source.ViolationMaxRatio {
+		t.Errorf("untar() error = %v, want violation %v", err, source.ViolationMaxRatio)
+	}
+}
+
+func TestTar_PrepareFiles(t *testing.T) {
+
+	dest := "./testdata/download/"
+
+	// Clean up after.
+	defer func() {
+		os.RemoveAll(dest)
+	}()
+
+	type args struct {
+		dest string
+	}
+	tests := []struct {
+		name         string
+		url          string
+		args         args
+		wantChecksum string
+		wantErr      bool
+	}{
+		{
+			"Gzip Fetch",
+			fileServer.URL + "/test.tar.gz",
+			args{
+				dest: dest,
+			},
+			"a28f162ea0ea0050602d9da97a56cb9e154048047bbcc74aa2033807a47479f5",
+			false,
+		},
+		{
+			"Bzip2 Fetch",
+			fileServer.URL + "/test.tar.bz2",
+			args{
+				dest: dest,
+			},
+			"a28f162ea0ea0050602d9da97a56cb9e154048047bbcc74aa2033807a47479f5",
+			false,
+		},
+		{
+			"Error Destination",
+			fileServer.URL + "/test.tar.gz",
+			args{
+				dest: "./testdata/test.tar/",
+			},
+			"",
+			true,
+		},
+		{
+			"Error Source Archive",
+			fileServer.URL + "/error.tar.gz",
+			args{
+				dest: dest,
+			},
+			"",
+			true,
+		},
+		{
+			"Error Status",
+			fileServer.URL + "/missing.tar.gz",
+			args{
+				dest: dest,
+			},
+			"",
+			true,
+		},
+		{
+			"Error Url",
+			"https://error.err/error.tar.gz",
+			args{
+				dest: dest,
+			},
+			"",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			m := NewTar(tt.url)
+
+			// Don't retry failed downloads in tests.
+			m.SetDownloader(&source.Downloader{})
+
+			err := m.PrepareFiles(tt.args.dest)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Tar.PrepareFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				sourcetest.CheckManifest(t, m, filepath.Join(tt.args.dest, "unzipped"))
+			}
+			if got := m.GetChecksum(); got != tt.wantChecksum {
+				t.Errorf("Tar.GetChecksum() = %v, want %v", got, tt.wantChecksum)
+			}
+		})
+	}
+}
+
+func TestNewTar(t *testing.T) {
+	type args struct {
+		url string
+	}
+	tests := []struct {
+		name string
+		args args
+		want *Tar
+	}{
+		{
+			"Get new *Tar",
+			args{
+				fileServer.URL + "/test.tar.gz",
+			},
+			&Tar{
+				url: fileServer.URL + "/test.tar.gz",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewTar(tt.args.url); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewTar() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package wporg
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/wptide/pkg/source"
+	"github.com/wptide/pkg/source/zip"
+	"github.com/wptide/pkg/wporg"
+)
+
+// WPOrg describes a plugin or theme hosted on WordPress.org, given as
+// "wporg:plugin/<slug>[@version]" or "wporg:theme/<slug>[@version]".
+type WPOrg struct {
+	url        string
+	apiBaseURL string
+	client     requester
+	limits     *source.Limits
+	downloader *source.Downloader
+	project    *wporg.ProjectInfo
+	version    string
+	zip        *zip.Zip
+}
+
+// requester is the part of wporg.Client used to look up projects.
+type requester interface {
+	RequestProject(projectType, slug string) (*wporg.ProjectInfo, error)
+}
+
+var (
+	// APIBaseURL is the default WordPress.org API used to look up download links.
+	APIBaseURL = "https://api.wordpress.org"
+
+	// Project types by the name used in urls.
+	projectTypes = map[string]string{
+		"plugin": "plugins",
+		"theme":  "themes",
+	}
+)
+
+func init() {
+	source.Register(source.Kind{
+		Name:    "wporg",
+		Schemes: []string{"wporg"},
+		New: func(url string) source.Source {
+			return NewWPOrg(url)
+		},
+	})
+}
+
+// PrepareFiles downloads the plugin or theme to a given destination and extracts info about its files.
+func (m *WPOrg) PrepareFiles(dest string) error {
+	return m.PrepareFilesContext(context.Background(), dest)
+}
+
+// PrepareFilesContext is PrepareFiles with a context to cancel the download.
+func (m *WPOrg) PrepareFilesContext(ctx context.Context, dest string) error {
+
+	projectType, slug, version, err := Parse(m.url)
+	if err != nil {
+		return err
+	}
+
+	project, err := m.getClient().RequestProject(projectTypes[projectType], slug)
+	if err != nil {
+		return err
+	}
+
+	link, err := project.DownloadLinkFor(version)
+	if err != nil {
+		return err
+	}
+
+	if version == "" {
+		version = project.Version
+	}
+
+	m.zip = zip.NewZip(link)
+	if m.limits != nil {
+		m.zip.SetLimits(*m.limits)
+	}
+	if m.downloader != nil {
+		m.zip.SetDownloader(m.downloader)
+	}
+
+	if err := m.zip.PrepareFilesContext(ctx, dest); err != nil {
+		return err
+	}
+
+	m.project = project
+	m.version = version
+
+	return nil
+}
+
+// GetChecksum returns the combined checksum for the downloaded files.
+func (m WPOrg) GetChecksum() string {
+	if m.zip == nil {
+		return ""
+	}
+	return m.zip.GetChecksum()
+}
+
+// GetFiles returns the downloaded files.
+func (m WPOrg) GetFiles() []string {
+	if m.zip == nil {
+		return nil
+	}
+	return m.zip.GetFiles()
+}
+
+// GetManifest returns the details of the downloaded files.
+func (m WPOrg) GetManifest() source.Manifest {
+	if m.zip == nil {
+		return source.Manifest{}
+	}
+	return m.zip.GetManifest()
+}
+
+// GetProject returns the project as described by the API, once the files are prepared.
+func (m WPOrg) GetProject() *wporg.ProjectInfo {
+	return m.project
+}
+
+// GetVersion returns the version that was downloaded, once the files are prepared.
+func (m WPOrg) GetVersion() string {
+	return m.version
+}
+
+// SetAPIBaseURL replaces APIBaseURL for this project.
+func (m *WPOrg) SetAPIBaseURL(base string) {
+	m.apiBaseURL = base
+	m.client = nil
+}
+
+// SetLimits replaces source.DefaultLimits for this project.
+func (m *WPOrg) SetLimits(limits source.Limits) {
+	m.limits = &limits
+}
+
+// SetDownloader replaces source.DefaultDownloader for this project.
+func (m *WPOrg) SetDownloader(downloader *source.Downloader) {
+	m.downloader = downloader
+}
+
+// NewWPOrg returns a new WPOrg source.
+func NewWPOrg(url string) *WPOrg {
+	return &WPOrg{
+		url: url,
+	}
+}
+
+// Parse splits a "wporg:<type>/<slug>[@version]" url into its parts.
+// The type is "plugin" or "theme", the version is empty if not given.
+func Parse(location string) (projectType, slug, version string, err error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return "", "", "", err
+	}
+	if u.Scheme != "wporg" {
+		return "", "", "", errors.New("not a wporg url: " + location)
+	}
+
+	// "wporg:plugin/akismet" has an opaque part, "wporg://plugin/akismet" a host and path.
+	name := u.Opaque
+	if name == "" {
+		name = u.Host + u.Path
+	}
+
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) != 2 {
+		return "", "", "", errors.New("invalid wporg url, expected wporg:plugin/<slug> or wporg:theme/<slug>: " + location)
+	}
+	projectType, slug = parts[0], parts[1]
+
+	if i := strings.Index(slug, "@"); i != -1 {
+		slug, version = slug[:i], slug[i+1:]
+		if version == "" {
+			return "", "", "", errors.New("empty version in wporg url: " + location)
+		}
+	}
+
+	if _, ok := projectTypes[projectType]; !ok {
+		return "", "", "", errors.New("unknown project type " + projectType + " in wporg url: " + location)
+	}
+	if slug == "" || strings.Contains(slug, "/") {
+		return "", "", "", errors.New("invalid slug in wporg url: " + location)
+	}
+
+	return projectType, slug, version, nil
+}
+
+// getClient returns the client to request the project with.
+func (m *WPOrg) getClient() requester {
+	if m.client != nil {
+		return m.client
+	}
+
+	base := APIBaseURL
+	if m.apiBaseURL != "" {
+		base = m.apiBaseURL
+	}
+	base = strings.TrimSuffix(base, "/")
+
+	client := &wporg.Client{}
+	client.SetPluginAPISource(base + "/plugins/info/1.1/")
+	client.SetThemeAPISource(base + "/themes/info/1.1/")
+	m.client = client
+
+	return client
+}
@@ -0,0 +1,202 @@
+package wporg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/wptide/pkg/source"
+)
+
+var apiServer *httptest.Server
+
+func init() {
+	apiServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		switch r.URL.Path {
+		case "/plugins/info/1.1/", "/themes/info/1.1/":
+		case "/plugin/dummy.1.0.zip", "/plugin/dummy.1.0.2.zip", "/plugin/dummy.2.0.1.zip", "/theme/dummy.2.0.1.zip":
+			http.ServeFile(w, r, "../zip/testdata/test.zip")
+			return
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		kind := "plugin"
+		if r.PostForm.Get("action") == "theme_information" {
+			kind = "theme"
+		}
+
+		switch r.PostForm.Get("request[slug]") {
+		case "dummy":
+			fmt.Fprintf(w, `{
+				"name": "Dummy",
+				"slug": "dummy",
+				"version": "2.0.1",
+				"download_link": "%[1]s/%[2]s/dummy.2.0.1.zip",
+				"versions": {
+					"1.0": "%[1]s/%[2]s/dummy.1.0.zip",
+					"1.0.2": "%[1]s/%[2]s/dummy.1.0.2.zip",
+					"1.5": "%[1]s/%[2]s/dummy.1.5.zip"
+				}
+			}`, apiServer.URL, kind)
+		default:
+			fmt.Fprintln(w, `{"error": "Plugin not found."}`)
+		}
+	}))
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name            string
+		url             string
+		wantProjectType string
+		wantSlug        string
+		wantVersion     string
+		wantErr         bool
+	}{
+		{"Plugin", "wporg:plugin/akismet", "plugin", "akismet", "", false},
+		{"Plugin Version", "wporg:plugin/akismet@5.3", "plugin", "akismet", "5.3", false},
+		{"Plugin Patch Version", "wporg:plugin/akismet@5.3.1", "plugin", "akismet", "5.3.1", false},
+		{"Theme Version", "wporg:theme/twentyten@3.0", "theme", "twentyten", "3.0", false},
+		{"Slashes", "wporg://theme/twentyten", "theme", "twentyten", "", false},
+		{"Other Scheme", "https://wordpress.org/plugins/akismet", "", "", "", true},
+		{"Unknown Type", "wporg:block/akismet", "", "", "", true},
+		{"No Slug", "wporg:plugin/", "", "", "", true},
+		{"No Type", "wporg:akismet", "", "", "", true},
+		{"Nested Slug", "wporg:plugin/akismet/trunk", "", "", "", true},
+		{"Empty Version", "wporg:plugin/akismet@", "", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotProjectType, gotSlug, gotVersion, err := Parse(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotProjectType != tt.wantProjectType || gotSlug != tt.wantSlug || gotVersion != tt.wantVersion {
+				t.Errorf("Parse() = %v, %v, %v, want %v, %v, %v", gotProjectType, gotSlug, gotVersion,
+					tt.wantProjectType, tt.wantSlug, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestWPOrg_PrepareFiles(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		url          string
+		wantVersion  string
+		wantChecksum string
+		wantErr      bool
+	}{
+		{
+			"Latest Plugin",
+			"wporg:plugin/dummy",
+			"2.0.1",
+			"a28f162ea0ea0050602d9da97a56cb9e154048047bbcc74aa2033807a47479f5",
+			false,
+		},
+		{
+			"Latest Theme",
+			"wporg:theme/dummy",
+			"2.0.1",
+			"a28f162ea0ea0050602d9da97a56cb9e154048047bbcc74aa2033807a47479f5",
+			false,
+		},
+		{
+			"Older Plugin Version",
+			"wporg:plugin/dummy@1.0",
+			"1.0",
+			"a28f162ea0ea0050602d9da97a56cb9e154048047bbcc74aa2033807a47479f5",
+			false,
+		},
+		{
+			"Older Plugin Patch Version",
+			"wporg:plugin/dummy@1.0.2",
+			"1.0.2",
+			"a28f162ea0ea0050602d9da97a56cb9e154048047bbcc74aa2033807a47479f5",
+			false,
+		},
+		{
+			"Missing Download",
+			"wporg:plugin/dummy@1.5",
+			"",
+			"",
+			true,
+		},
+		{
+			"Unknown Version",
+			"wporg:plugin/dummy@9.9",
+			"",
+			"",
+			true,
+		},
+		{
+			"Unknown Slug",
+			"wporg:plugin/missing",
+			"",
+			"",
+			true,
+		},
+		{
+			"Invalid URL",
+			"wporg:plugin",
+			"",
+			"",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			dest, err := ioutil.TempDir("", "tide-wporg")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dest)
+
+			m := NewWPOrg(tt.url)
+			m.SetAPIBaseURL(apiServer.URL)
+			m.SetDownloader(&source.Downloader{})
+
+			err = m.PrepareFiles(dest)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("WPOrg.PrepareFiles() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+
+			if got := m.GetVersion(); got != tt.wantVersion {
+				t.Errorf("WPOrg.GetVersion() = %v, want %v", got, tt.wantVersion)
+			}
+			if got := m.GetChecksum(); got != tt.wantChecksum {
+				t.Errorf("WPOrg.GetChecksum() = %v, want %v", got, tt.wantChecksum)
+			}
+			if got := len(m.GetFiles()); got != 3 {
+				t.Errorf("WPOrg.GetFiles() returned %d files, want %d", got, 3)
+			}
+			if got := m.GetProject(); got == nil || got.Slug != "dummy" {
+				t.Errorf("WPOrg.GetProject() = %v, want project dummy", got)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	got, err := source.Resolve("wporg:plugin/akismet@5.3", "")
+	if err != nil {
+		t.Fatalf("source.Resolve() error = %v", err)
+	}
+	if _, ok := got.(*WPOrg); !ok {
+		t.Errorf("source.Resolve() = %T, want *WPOrg", got)
+	}
+}
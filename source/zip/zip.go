@@ -2,38 +2,58 @@ package zip
 
 import (
 	"archive/zip"
+	"context"
 	"crypto/sha256"
-	"encoding/json"
 	"fmt"
 	"io"
-	"net/http"
+	"io/ioutil"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
+
+	"github.com/wptide/pkg/source"
 )
 
 // Zip describes a zip file.
 type Zip struct {
-	url      string
-	dest     string
-	files    []string
-	checksum string
+	url        string
+	dest       string
+	files      []string
+	checksum   string
+	manifest   source.Manifest
+	limits     *source.Limits
+	downloader *source.Downloader
 }
 
 var (
 	// File system operation variables.
-	createFile       = os.Create
 	makeDirectoryAll = os.MkdirAll
 	ioCopy           = io.Copy
 	openFile         = os.OpenFile
+	symlink          = os.Symlink
 
 	// Default paths.
 	sourceFilename = "source.zip"
 )
 
+func init() {
+	source.Register(source.Kind{
+		Name:       "zip",
+		Extensions: []string{".zip"},
+		MimeTypes:  []string{"application/zip", "application/x-zip-compressed"},
+		New: func(url string) source.Source {
+			return NewZip(url)
+		},
+	})
+}
+
 // PrepareFiles downloads a zip file to a given destination and extracts info about the files in the zip.
 func (m *Zip) PrepareFiles(dest string) error {
+	return m.PrepareFilesContext(context.Background(), dest)
+}
+
+// PrepareFilesContext is PrepareFiles with a context to cancel the download.
+func (m *Zip) PrepareFilesContext(ctx context.Context, dest string) error {
 
 	// Prepare destination.
 	m.dest = dest
@@ -41,13 +61,23 @@ func (m *Zip) PrepareFiles(dest string) error {
 		os.Mkdir(m.dest, os.ModePerm)
 	}
 
-	err := downloadFile(m.url, m.dest+"/"+sourceFilename)
+	downloader := source.DefaultDownloader
+	if m.downloader != nil {
+		downloader = m.downloader
+	}
+
+	err := downloader.Download(ctx, m.url, m.dest+"/"+sourceFilename)
 	if err != nil {
 		return err
 	}
 
+	limits := source.DefaultLimits
+	if m.limits != nil {
+		limits = *m.limits
+	}
+
 	var checksums []string
-	m.files, checksums, err = unzip(m.dest+"/"+sourceFilename, m.dest+"/unzipped")
+	m.files, checksums, err = unzip(m.dest+"/"+sourceFilename, m.dest+"/unzipped", limits)
 	if err != nil {
 		return err
 	}
@@ -55,6 +85,12 @@ func (m *Zip) PrepareFiles(dest string) error {
 	// Calculate checksum - uses same technique as Tide Audit Server.
 	m.checksum = combinedChecksum(checksums)
 
+	// Keep the details of every file.
+	m.manifest, err = source.NewManifest(m.dest+"/unzipped", m.files, checksums)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -68,50 +104,41 @@ func (m Zip) GetFiles() []string {
 	return m.files
 }
 
-// NewZip returns a new Zip source.
-func NewZip(url string) *Zip {
-	return &Zip{
-		url: url,
-	}
+// GetManifest returns the details of the files in the zip file.
+func (m Zip) GetManifest() source.Manifest {
+	return m.manifest
 }
 
-// downloadFile uses an HTTP request to get a file and save it to a given destination folder.
-func downloadFile(source string, destination string) error {
-
-	// Create destination
-	out, err := createFile(destination)
-	if err != nil {
-		return err
-	}
-	defer out.Close()
-
-	// Get file
-	resp, err := http.Get(source)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
+// SetLimits replaces source.DefaultLimits for this zip file.
+func (m *Zip) SetLimits(limits source.Limits) {
+	m.limits = &limits
+}
 
-	// Write to file
-	_, err = ioCopy(out, resp.Body)
+// SetDownloader replaces source.DefaultDownloader for this zip file.
+func (m *Zip) SetDownloader(downloader *source.Downloader) {
+	m.downloader = downloader
+}
 
-	if err != nil {
-		return err
+// NewZip returns a new Zip source.
+func NewZip(url string) *Zip {
+	return &Zip{
+		url: url,
 	}
-
-	return nil
 }
 
 // unzip will un-compress a zip archive,
-// moving all files and folders to a destination directory
+// moving all files and folders to a destination directory.
+// Entries that break the limits or would be written outside
+// of the destination return a *source.ArchiveError.
 //
 // Props to https://golangcode.com/unzip-files-in-go/ and
 // http://blog.ralch.com/tutorial/golang-working-with-zip/
-func unzip(source, destination string) (filenames, checksums []string, err error) {
-	reader, err := zip.OpenReader(source)
+func unzip(archive, destination string, limits source.Limits) (filenames, checksums []string, err error) {
+	reader, err := zip.OpenReader(archive)
 	if err != nil {
 		return filenames, checksums, err
 	}
+	defer reader.Close()
 
 	if err := makeDirectoryAll(destination, 0755); err != nil {
 		return filenames, checksums, err
@@ -128,20 +155,43 @@ func unzip(source, destination string) (filenames, checksums []string, err error
 		}
 	}
 
+	extraction := source.NewExtraction(destination, limits)
+
 	for _, file := range reader.File {
-		path := filepath.Join(destination, strings.TrimPrefix(file.Name, rootPath))
+		path, err := extraction.Path(strings.TrimPrefix(file.Name, rootPath))
+		if err != nil {
+			return nil, nil, err
+		}
+
 		if file.FileInfo().IsDir() {
 			makeDirectoryAll(path, file.Mode())
 			continue
 		}
 
+		if file.Mode()&os.ModeSymlink != 0 {
+			if err := extractSymlink(extraction, file, path); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		if err := extraction.File(file.Name); err != nil {
+			return nil, nil, err
+		}
+
+		// Archives don't always contain entries for parent folders.
+		if err := makeDirectoryAll(filepath.Dir(path), 0755); err != nil {
+			return nil, nil, err
+		}
+
 		filenames = append(filenames, path)
 
 		// This reads the file from the ZIP. It does not yet exist on the system.
 		fileReader, _ := file.Open()
 
 		h := sha256.New()
-		if _, err := ioCopy(h, fileReader); err != nil {
+		limited := extraction.Reader(file.Name, fileReader, int64(file.CompressedSize64))
+		if _, err := ioCopy(h, limited); err != nil {
 			fileReader.Close()
 			return nil, nil, err
 		}
@@ -169,8 +219,28 @@ func unzip(source, destination string) (filenames, checksums []string, err error
 	return filenames, checksums, err
 }
 
+// extractSymlink creates a symlink entry if the limits allow it.
+func extractSymlink(extraction *source.Extraction, file *zip.File, path string) error {
+	fileReader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer fileReader.Close()
+
+	// The link target is stored as the content of the entry.
+	target, err := ioutil.ReadAll(io.LimitReader(fileReader, 4096))
+	if err != nil {
+		return err
+	}
+
+	create, err := extraction.Symlink(file.Name, path, string(target))
+	if err != nil || !create {
+		return err
+	}
+
+	return symlink(string(target), path)
+}
+
 func combinedChecksum(sums []string) string {
-	sort.Strings(sums)
-	jsonChecksums, _ := json.Marshal(sums)
-	return fmt.Sprintf("%x", sha256.Sum256(jsonChecksums))
+	return source.CombinedChecksum(sums)
 }
@@ -1,13 +1,23 @@
 package zip
 
 import (
+	"archive/zip"
+	"bytes"
 	"errors"
+	"hash"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+
+	"github.com/wptide/pkg/source"
+	"github.com/wptide/pkg/source/sourcetest"
 )
 
 var fileServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -16,6 +26,8 @@ var fileServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
 		w.Header().Set("Content-Type", "applicaiton/zip")
 		w.Header().Set("Content-Disposition", "attachment; filename='test.zip'")
 		http.ServeFile(w, r, "./testdata/test.zip")
+	case "/missing.zip":
+		http.NotFound(w, r)
 	}
 }))
 
@@ -106,7 +118,7 @@ func Test_unzip(t *testing.T) {
 	}
 
 	errorCopySHA := func(dst io.Writer, src io.Reader) (written int64, err error) {
-		if reflect.TypeOf(dst).String() != "*sha256.digest" {
+		if _, ok := dst.(hash.Hash); !ok {
 			return io.Copy(dst, src)
 		}
 		return 0, errors.New("something went wrong")
@@ -240,7 +252,7 @@ func Test_unzip(t *testing.T) {
 				}()
 			}
 
-			gotFilenames, gotChecksums, err := unzip(tt.args.source, tt.args.destination)
+			gotFilenames, gotChecksums, err := unzip(tt.args.source, tt.args.destination, source.DefaultLimits)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("unzip() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -255,6 +267,198 @@ func Test_unzip(t *testing.T) {
 	}
 }
 
+// zipEntry describes a file to add to a generated zip archive.
+type zipEntry struct {
+	name string
+	body string
+	mode os.FileMode
+}
+
+// writeZip creates a zip archive with the given entries.
+func writeZip(t *testing.T, path string, entries []zipEntry) {
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for _, e := range entries {
+		header := &zip.FileHeader{
+			Name:   e.name,
+			Method: zip.Deflate,
+		}
+		mode := e.mode
+		if mode == 0 {
+			mode = 0644
+		}
+		header.SetMode(mode)
+
+		f, err := w.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(e.body))
+	}
+	w.Close()
+
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_unzip_limits(t *testing.T) {
+
+	root, err := ioutil.TempDir("", "tide-zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Clean up after.
+	defer os.RemoveAll(root)
+
+	plugin := zipEntry{name: "plugin/plugin.php", body: "<?php\n"}
+	folder := zipEntry{name: "plugin/", mode: os.ModeDir | 0755}
+
+	tests := []struct {
+		name          string
+		entries       []zipEntry
+		limits        source.Limits
+		wantFiles     int
+		wantViolation string
+		wantLink      string
+	}{
+		{
+			"Within Limits",
+			[]zipEntry{folder, plugin},
+			source.DefaultLimits,
+			1,
+			"",
+			"",
+		},
+		{
+			"Path Traversal",
+			[]zipEntry{plugin, {name: "../../evil.php", body: "<?php\n"}},
+			source.DefaultLimits,
+			0,
+			source.ViolationPathTraversal,
+			"",
+		},
+		{
+			"Path Traversal - Root Folder",
+			[]zipEntry{folder, plugin, {name: "plugin/../../evil.php", body: "<?php\n"}},
+			source.DefaultLimits,
+			0,
+			source.ViolationPathTraversal,
+			"",
+		},
+		{
+			"Absolute Path",
+			[]zipEntry{{name: "/tmp/evil.php", body: "<?php\n"}},
+			source.DefaultLimits,
+			0,
+			source.ViolationPathTraversal,
+			"",
+		},
+		{
+			"Too Many Files",
+			[]zipEntry{plugin, {name: "plugin/other.php"}, {name: "plugin/third.php"}},
+			source.Limits{MaxFiles: 2},
+			0,
+			source.ViolationMaxFiles,
+			"",
+		},
+		{
+			"Too Many Bytes",
+			[]zipEntry{plugin, {name: "plugin/big.php", body: strings.Repeat("a", 2048)}},
+			source.Limits{MaxBytes: 1024},
+			0,
+			source.ViolationMaxBytes,
+			"",
+		},
+		{
+			"Compression Ratio",
+			[]zipEntry{{name: "plugin/bomb.php", body: strings.Repeat("\x00", 4<<20)}},
+			source.Limits{MaxRatio: 100},
+			0,
+			source.ViolationMaxRatio,
+			"",
+		},
+		{
+			"Too Deep",
+			[]zipEntry{{name: "plugin/a/b/c/d.php", body: "<?php\n"}},
+			source.Limits{MaxDepth: 2},
+			0,
+			source.ViolationMaxDepth,
+			"",
+		},
+		{
+			"Symlink - Skip",
+			[]zipEntry{folder, plugin, {name: "plugin/link.php", body: "plugin.php", mode: os.ModeSymlink | 0777}},
+			source.Limits{Symlinks: source.SymlinkSkip},
+			1,
+			"",
+			"",
+		},
+		{
+			"Symlink - Reject",
+			[]zipEntry{folder, plugin, {name: "plugin/link.php", body: "plugin.php", mode: os.ModeSymlink | 0777}},
+			source.Limits{Symlinks: source.SymlinkReject},
+			0,
+			source.ViolationSymlink,
+			"",
+		},
+		{
+			"Symlink - Extract",
+			[]zipEntry{folder, plugin, {name: "plugin/link.php", body: "plugin.php", mode: os.ModeSymlink | 0777}},
+			source.Limits{Symlinks: source.SymlinkExtract},
+			1,
+			"",
+			"link.php",
+		},
+		{
+			"Symlink - Extract Outside",
+			[]zipEntry{folder, plugin, {name: "plugin/link.php", body: "../../../etc/passwd", mode: os.ModeSymlink | 0777}},
+			source.Limits{Symlinks: source.SymlinkExtract},
+			0,
+			source.ViolationSymlink,
+			"",
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := filepath.Join(root, "test.zip")
+			destination := filepath.Join(root, "unzipped", strconv.Itoa(i))
+			writeZip(t, archive, tt.entries)
+
+			files, _, err := unzip(archive, destination, tt.limits)
+
+			if tt.wantViolation != "" {
+				archiveErr, ok := err.(*source.ArchiveError)
+				if !ok {
+					t.Errorf("unzip() error = %v, want *source.ArchiveError", err)
+					return
+				}
+				if archiveErr.Violation != tt.wantViolation {
+					t.Errorf("unzip() violation = %v, want %v", archiveErr.Violation, tt.wantViolation)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unzip() error = %v", err)
+				return
+			}
+			if len(files) != tt.wantFiles {
+				t.Errorf("unzip() files = %v, want %d files", files, tt.wantFiles)
+			}
+			if tt.wantLink != "" {
+				if _, err := os.Readlink(filepath.Join(destination, tt.wantLink)); err != nil {
+					t.Errorf("unzip() symlink not created: %v", err)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(root, "evil.php")); !os.IsNotExist(err) {
+				t.Errorf("unzip() wrote outside of the destination")
+			}
+		})
+	}
+}
+
 func TestZip_PrepareFiles(t *testing.T) {
 
 	dest := "./testdata/download/"
@@ -266,9 +470,8 @@ func TestZip_PrepareFiles(t *testing.T) {
 		os.RemoveAll(errDest)
 	}()
 
-	errorCreate := func(path string) (*os.File, error) {
-		return nil, errors.New("something went wrong")
-	}
+	// Don't retry failed downloads in tests.
+	downloader := &source.Downloader{}
 
 	type fields struct {
 		url      string
@@ -278,7 +481,6 @@ func TestZip_PrepareFiles(t *testing.T) {
 	}
 	type args struct {
 		dest           string
-		createFile     func(string) (*os.File, error)
 		sourceFilename string
 	}
 	tests := []struct {
@@ -305,8 +507,7 @@ func TestZip_PrepareFiles(t *testing.T) {
 				dest: dest,
 			},
 			args{
-				dest:       dest,
-				createFile: errorCreate,
+				dest: "./testdata/test.zip/",
 			},
 			true,
 		},
@@ -322,6 +523,17 @@ func TestZip_PrepareFiles(t *testing.T) {
 			},
 			true,
 		},
+		{
+			"Error Status",
+			fields{
+				url:  fileServer.URL + "/missing.zip",
+				dest: dest,
+			},
+			args{
+				dest: dest,
+			},
+			true,
+		},
 		{
 			"Error Url",
 			fields{
@@ -337,15 +549,6 @@ func TestZip_PrepareFiles(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 
-			// Test create file.
-			if tt.args.createFile != nil {
-				oldCreateFile := createFile
-				createFile = tt.args.createFile
-				defer func() {
-					createFile = oldCreateFile
-				}()
-			}
-
 			// Test bad source name.
 			if tt.args.sourceFilename != "" {
 				oldFilename := sourceFilename
@@ -356,73 +559,18 @@ func TestZip_PrepareFiles(t *testing.T) {
 			}
 
 			m := &Zip{
-				url:      tt.fields.url,
-				dest:     tt.fields.dest,
-				files:    tt.fields.files,
-				checksum: tt.fields.checksum,
+				url:        tt.fields.url,
+				dest:       tt.fields.dest,
+				files:      tt.fields.files,
+				checksum:   tt.fields.checksum,
+				downloader: downloader,
 			}
-			if err := m.PrepareFiles(tt.args.dest); (err != nil) != tt.wantErr {
+			err := m.PrepareFiles(tt.args.dest)
+			if (err != nil) != tt.wantErr {
 				t.Errorf("Zip.PrepareFiles() error = %v, wantErr %v", err, tt.wantErr)
 			}
-		})
-	}
-}
-
-func Test_downloadFile(t *testing.T) {
-
-	dest := "./testdata/source.zip"
-
-	// Clean up after.
-	defer func() {
-		os.Remove(dest)
-	}()
-
-	errorCopy := func(dst io.Writer, src io.Reader) (written int64, err error) {
-		return 0, errors.New("something went wrong")
-	}
-
-	type args struct {
-		source      string
-		destination string
-		ioCopy      func(dst io.Writer, src io.Reader) (written int64, err error)
-	}
-	tests := []struct {
-		name    string
-		args    args
-		wantErr bool
-	}{
-		{
-			"Download - Success",
-			args{
-				source:      fileServer.URL + "/test.zip",
-				destination: dest,
-			},
-			false,
-		},
-		{
-			"Download - Fail Copy to Target",
-			args{
-				source:      fileServer.URL + "/test.zip",
-				destination: dest,
-				ioCopy:      errorCopy,
-			},
-			true,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-
-			// Test io.Copy error.
-			if tt.args.ioCopy != nil {
-				oldCopy := ioCopy
-				ioCopy = tt.args.ioCopy
-				defer func() {
-					ioCopy = oldCopy
-				}()
-			}
-
-			if err := downloadFile(tt.args.source, tt.args.destination); (err != nil) != tt.wantErr {
-				t.Errorf("downloadFile() error = %v, wantErr %v", err, tt.wantErr)
+			if err == nil {
+				sourcetest.CheckManifest(t, m, filepath.Join(tt.args.dest, "unzipped"))
 			}
 		})
 	}
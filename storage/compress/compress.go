@@ -0,0 +1,198 @@
+// Package compress is a storage provider decorator that compresses objects on upload and decompresses them on download.
+package compress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/wptide/pkg/storage"
+)
+
+// Supported encodings.
+const (
+	Gzip = "gzip"
+	Zstd = "zstd"
+)
+
+var (
+	// File system operation variables.
+	fileCreate = os.Create
+	fileOpen   = os.Open
+
+	// Magic numbers at the start of compressed objects.
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Provider compresses the objects of another provider.
+// Objects that are not compressed, e.g. uploaded before compression was enabled, are read as is.
+type Provider struct {
+	provider storage.Provider
+	encoding string
+}
+
+// NewProvider returns a provider compressing objects with the encoding before storing them with provider.
+func NewProvider(provider storage.Provider, encoding string) (*Provider, error) {
+	switch encoding {
+	case Gzip, Zstd:
+	default:
+		return nil, fmt.Errorf("compress: unsupported encoding %q", encoding)
+	}
+
+	return &Provider{
+		provider: provider,
+		encoding: encoding,
+	}, nil
+}
+
+// Kind returns the kind of the wrapped provider.
+func (p *Provider) Kind() string {
+	return p.provider.Kind()
+}
+
+// CollectionRef returns the collection of the wrapped provider.
+func (p *Provider) CollectionRef() string {
+	return p.provider.CollectionRef()
+}
+
+// Encoding returns the content encoding of the stored objects.
+func (p *Provider) Encoding() string {
+	return p.encoding
+}
+
+// UploadFile compresses and uploads the file.
+func (p *Provider) UploadFile(filename, reference string) error {
+	file, err := fileOpen(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return p.Put(context.Background(), reference, file, nil)
+}
+
+// DownloadFile downloads and decompresses the object to the file.
+func (p *Provider) DownloadFile(reference, filename string) error {
+	r, err := p.Get(context.Background(), reference)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	file, err := fileCreate(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, r)
+	return err
+}
+
+// Put compresses the content of r and stores it with the Content-Encoding metadata set.
+func (p *Provider) Put(ctx context.Context, reference string, r io.Reader, metadata map[string]string) error {
+	compressed := p.compress(r)
+	// Stops the compression if the provider fails before reading everything.
+	defer compressed.Close()
+
+	md := map[string]string{storage.ContentEncoding: p.encoding}
+	for key, value := range metadata {
+		if key != storage.ContentEncoding {
+			md[key] = value
+		}
+	}
+
+	return storage.Put(ctx, p.provider, reference, compressed, md)
+}
+
+// Get returns a reader for the decompressed object. The reader must be closed.
+func (p *Provider) Get(ctx context.Context, reference string) (io.ReadCloser, error) {
+	r, err := storage.Get(ctx, p.provider, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	decompressed, err := decompress(r)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	return decompressed, nil
+}
+
+// compress returns a reader for the compressed content of r.
+// Closing the reader stops the compression.
+func (p *Provider) compress(r io.Reader) io.ReadCloser {
+	pr, pw := io.Pipe()
+
+	go func() {
+		w, err := p.newWriter(pw)
+		if err == nil {
+			_, err = io.Copy(w, r)
+			if cerr := w.Close(); err == nil {
+				err = cerr
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+
+	return pr
+}
+
+func (p *Provider) newWriter(w io.Writer) (io.WriteCloser, error) {
+	if p.encoding == Zstd {
+		return zstd.NewWriter(w)
+	}
+	return gzip.NewWriter(w), nil
+}
+
+// decompress detects the encoding of r from its magic number and returns a reader for its content.
+// Content that is not compressed is returned as is, which also covers providers
+// that already decompressed objects with a Content-Encoding when downloading them.
+func decompress(r io.ReadCloser) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(r)
+	head, err := buffered.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		return readCloser{gz, func() error {
+			gz.Close()
+			return r.Close()
+		}}, nil
+	case bytes.HasPrefix(head, zstdMagic):
+		dec, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		return readCloser{dec, func() error {
+			dec.Close()
+			return r.Close()
+		}}, nil
+	default:
+		return readCloser{buffered, r.Close}, nil
+	}
+}
+
+// readCloser reads from a decompressor and closes the underlying reader.
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (rc readCloser) Close() error {
+	return rc.close()
+}
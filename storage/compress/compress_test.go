@@ -0,0 +1,162 @@
+package compress
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/storage/memory"
+)
+
+// metadataStorage is a memory provider that remembers the metadata of the last Put.
+type metadataStorage struct {
+	*memory.Provider
+	metadata map[string]string
+}
+
+func (m *metadataStorage) Put(ctx context.Context, reference string, r io.Reader, metadata map[string]string) error {
+	m.metadata = metadata
+	return m.Provider.Put(ctx, reference, r, metadata)
+}
+
+// fileStorage hides the streaming methods of a memory provider.
+type fileStorage struct {
+	provider *memory.Provider
+}
+
+func (f fileStorage) Kind() string          { return f.provider.Kind() }
+func (f fileStorage) CollectionRef() string { return f.provider.CollectionRef() }
+func (f fileStorage) UploadFile(filename, reference string) error {
+	return f.provider.UploadFile(filename, reference)
+}
+func (f fileStorage) DownloadFile(reference, filename string) error {
+	return f.provider.DownloadFile(reference, filename)
+}
+
+// failingStorage fails to store anything.
+type failingStorage struct {
+	*memory.Provider
+}
+
+func (f failingStorage) Put(ctx context.Context, reference string, r io.Reader, metadata map[string]string) error {
+	return errors.New("something went wrong")
+}
+
+func TestNewProvider(t *testing.T) {
+	for _, encoding := range []string{Gzip, Zstd} {
+		p, err := NewProvider(memory.NewMemoryStorage("reports"), encoding)
+		if err != nil || p.Encoding() != encoding || storage.Encoding(p) != encoding {
+			t.Errorf("NewProvider(%q) = %v, %v", encoding, p, err)
+		}
+		if p.Kind() != "memory" || p.CollectionRef() != "reports" {
+			t.Errorf("NewProvider(%q) = %v %v, want the wrapped provider", encoding, p.Kind(), p.CollectionRef())
+		}
+	}
+
+	if _, err := NewProvider(memory.NewMemoryStorage("reports"), "brotli"); err == nil {
+		t.Errorf("NewProvider() error = nil, want error for an unsupported encoding")
+	}
+}
+
+func TestProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tide-compress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	content := strings.Repeat(`{"phpcs":"report"}`, 100)
+	filename := filepath.Join(dir, "report.json")
+	ioutil.WriteFile(filename, []byte(content), 0644)
+
+	tests := []struct {
+		name      string
+		encoding  string
+		streaming bool
+		magic     []byte
+	}{
+		{"Gzip Streaming", Gzip, true, gzipMagic},
+		{"Gzip Files", Gzip, false, gzipMagic},
+		{"Zstd Streaming", Zstd, true, zstdMagic},
+		{"Zstd Files", Zstd, false, zstdMagic},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mem := memory.NewMemoryStorage("reports")
+			recorder := &metadataStorage{Provider: mem}
+
+			var wrapped storage.Provider = fileStorage{mem}
+			if tt.streaming {
+				wrapped = recorder
+			}
+			p, _ := NewProvider(wrapped, tt.encoding)
+
+			if err := p.UploadFile(filename, "report.json"); err != nil {
+				t.Fatalf("Provider.UploadFile() error = %v", err)
+			}
+
+			stored, _ := mem.Get(context.Background(), "report.json")
+			raw, _ := ioutil.ReadAll(stored)
+			if !bytes.HasPrefix(raw, tt.magic) || len(raw) >= len(content) {
+				t.Errorf("Provider.UploadFile() stored %d bytes starting with %x, want %s", len(raw), raw[:4], tt.encoding)
+			}
+			if tt.streaming && recorder.metadata[storage.ContentEncoding] != tt.encoding {
+				t.Errorf("Provider.UploadFile() metadata = %v, want %s encoding", recorder.metadata, tt.encoding)
+			}
+
+			downloaded := filepath.Join(dir, "download.json")
+			if err := p.DownloadFile("report.json", downloaded); err != nil {
+				t.Fatalf("Provider.DownloadFile() error = %v", err)
+			}
+			if got, _ := ioutil.ReadFile(downloaded); string(got) != content {
+				t.Errorf("Provider.DownloadFile() = %d bytes, want the uploaded content", len(got))
+			}
+
+			if _, err := p.Get(context.Background(), "missing.json"); err == nil {
+				t.Errorf("Provider.Get() error = nil, want error for a missing object")
+			}
+		})
+	}
+}
+
+func TestProvider_uncompressed(t *testing.T) {
+	mem := memory.NewMemoryStorage("reports")
+	mem.Put(context.Background(), "old.json", strings.NewReader(`{"old":true}`), nil)
+	mem.Put(context.Background(), "empty.json", strings.NewReader(""), nil)
+
+	p, _ := NewProvider(mem, Gzip)
+
+	for reference, want := range map[string]string{"old.json": `{"old":true}`, "empty.json": ""} {
+		r, err := p.Get(context.Background(), reference)
+		if err != nil {
+			t.Fatalf("Provider.Get(%q) error = %v", reference, err)
+		}
+		got, _ := ioutil.ReadAll(r)
+		r.Close()
+
+		if string(got) != want {
+			t.Errorf("Provider.Get(%q) = %s, want %s", reference, got, want)
+		}
+	}
+}
+
+func TestProvider_errors(t *testing.T) {
+	p, _ := NewProvider(failingStorage{memory.NewMemoryStorage("reports")}, Gzip)
+
+	if err := p.Put(context.Background(), "report.json", strings.NewReader("{}"), nil); err == nil {
+		t.Errorf("Provider.Put() error = nil, want the provider error")
+	}
+	if err := p.UploadFile("./testdata/missing.json", "report.json"); err == nil {
+		t.Errorf("Provider.UploadFile() error = nil, want error for a missing file")
+	}
+	if err := p.DownloadFile("missing.json", "./missing.json"); err != storage.ErrNotExist {
+		t.Errorf("Provider.DownloadFile() error = %v, want %v", err, storage.ErrNotExist)
+	}
+}
@@ -0,0 +1,319 @@
+// Package encrypt is a storage provider decorator that encrypts objects with AES-GCM before storing them.
+//
+// Every object is encrypted with its own random data key, which is stored with the
+// object encrypted by the key of the provider (envelope encryption). The content is
+// encrypted in chunks so that large reports can be streamed.
+//
+// To combine it with compression, compress first:
+//
+//	encrypted, _ := encrypt.NewProvider(provider, key)
+//	compressed, _ := compress.NewProvider(encrypted, compress.Gzip)
+package encrypt
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/wptide/pkg/storage"
+)
+
+const (
+	chunkSize = 64 * 1024
+	keySize   = 32
+)
+
+var (
+	// File system operation variables.
+	fileCreate = os.Create
+	fileOpen   = os.Open
+
+	// Using a variable so that we can mock it in tests.
+	randReader = rand.Reader
+
+	// magic identifies the format of encrypted objects.
+	magic = []byte("TIDEENC1")
+
+	// ErrDecrypt is returned when an object can't be decrypted, e.g. because it was encrypted with another key or modified.
+	ErrDecrypt = errors.New("encrypt: could not decrypt object")
+)
+
+// Provider encrypts the objects of another provider.
+type Provider struct {
+	provider storage.Provider
+	key      cipher.AEAD
+}
+
+// NewProvider returns a provider encrypting objects before storing them with provider.
+// The key must be 16, 24 or 32 bytes long to select AES-128, AES-192 or AES-256.
+func NewProvider(provider storage.Provider, key []byte) (*Provider, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Provider{
+		provider: provider,
+		key:      aead,
+	}, nil
+}
+
+// Kind returns the kind of the wrapped provider.
+func (p *Provider) Kind() string {
+	return p.provider.Kind()
+}
+
+// CollectionRef returns the collection of the wrapped provider.
+func (p *Provider) CollectionRef() string {
+	return p.provider.CollectionRef()
+}
+
+// UploadFile encrypts and uploads the file.
+func (p *Provider) UploadFile(filename, reference string) error {
+	file, err := fileOpen(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return p.Put(context.Background(), reference, file, nil)
+}
+
+// DownloadFile downloads and decrypts the object to the file.
+func (p *Provider) DownloadFile(reference, filename string) error {
+	r, err := p.Get(context.Background(), reference)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	file, err := fileCreate(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, r)
+	return err
+}
+
+// Put encrypts the content of r and stores it.
+// The content type and encoding are not passed on, because the stored object is not readable without decrypting it.
+func (p *Provider) Put(ctx context.Context, reference string, r io.Reader, metadata map[string]string) error {
+	encrypted, err := p.encrypt(r)
+	if err != nil {
+		return err
+	}
+	// Stops the encryption if the provider fails before reading everything.
+	defer encrypted.Close()
+
+	md := map[string]string{storage.ContentType: "application/octet-stream"}
+	for key, value := range metadata {
+		if key != storage.ContentEncoding && key != storage.ContentType {
+			md[key] = value
+		}
+	}
+
+	return storage.Put(ctx, p.provider, reference, encrypted, md)
+}
+
+// Get returns a reader for the decrypted object. The reader must be closed.
+// Reading returns ErrDecrypt if the object was not encrypted with the key or was modified.
+func (p *Provider) Get(ctx context.Context, reference string) (io.ReadCloser, error) {
+	r, err := storage.Get(ctx, p.provider, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted, err := p.decrypt(r)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	return decrypted, nil
+}
+
+// encrypt returns a reader for the encrypted content of r: the header with the
+// encrypted data key followed by the encrypted chunks. Closing the reader stops the encryption.
+func (p *Provider) encrypt(r io.Reader) (io.ReadCloser, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := io.ReadFull(randReader, dataKey); err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, p.key.NonceSize())
+	if _, err := io.ReadFull(randReader, nonce); err != nil {
+		return nil, err
+	}
+
+	header := append(append([]byte{}, magic...), nonce...)
+	header = p.key.Seal(header, nonce, dataKey, magic)
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+
+	go func() {
+		if _, err := pw.Write(header); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(sealChunks(pw, r, aead))
+	}()
+
+	return pr, nil
+}
+
+// decrypt reads the header of r and returns a reader for the decrypted content.
+func (p *Provider) decrypt(r io.ReadCloser) (io.ReadCloser, error) {
+	buffered := bufio.NewReaderSize(r, chunkSize+2*aes.BlockSize)
+
+	header := make([]byte, len(magic)+p.key.NonceSize()+keySize+p.key.Overhead())
+	if _, err := io.ReadFull(buffered, header); err != nil {
+		return nil, ErrDecrypt
+	}
+	if string(header[:len(magic)]) != string(magic) {
+		return nil, ErrDecrypt
+	}
+
+	nonce := header[len(magic) : len(magic)+p.key.NonceSize()]
+	dataKey, err := p.key.Open(nil, nonce, header[len(magic)+p.key.NonceSize():], magic)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chunkReader{
+		r:      buffered,
+		closer: r,
+		aead:   aead,
+	}, nil
+}
+
+// sealChunks writes the content of r to w in encrypted chunks.
+// The nonce of each chunk is its counter and a flag for the last chunk, so that
+// chunks can't be reordered, dropped or truncated without failing to decrypt.
+func sealChunks(w io.Writer, r io.Reader, aead cipher.AEAD) error {
+	buffered := bufio.NewReaderSize(r, chunkSize)
+	buf := make([]byte, chunkSize)
+	var sealed []byte
+
+	for counter := uint64(0); ; counter++ {
+		n, err := io.ReadFull(buffered, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+
+		// A short chunk, or a full one that nothing follows, is the last one.
+		last := err != nil
+		if !last {
+			if _, err := buffered.Peek(1); err == io.EOF {
+				last = true
+			} else if err != nil {
+				return err
+			}
+		}
+
+		sealed = aead.Seal(sealed[:0], chunkNonce(aead, counter, last), buf[:n], nil)
+		if _, err := w.Write(sealed); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// chunkNonce returns the nonce for a chunk.
+func chunkNonce(aead cipher.AEAD, counter uint64, last bool) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-9:], counter)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// chunkReader decrypts the chunks written by sealChunks.
+type chunkReader struct {
+	r       *bufio.Reader
+	closer  io.Closer
+	aead    cipher.AEAD
+	counter uint64
+	plain   []byte // Decrypted content not read yet.
+	done    bool   // The last chunk was decrypted.
+	err     error
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for len(c.plain) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		if c.done {
+			return 0, io.EOF
+		}
+		c.err = c.next()
+	}
+
+	n := copy(p, c.plain)
+	c.plain = c.plain[n:]
+	return n, nil
+}
+
+// next decrypts the next chunk.
+func (c *chunkReader) next() error {
+	sealed := make([]byte, chunkSize+c.aead.Overhead())
+	n, err := io.ReadFull(c.r, sealed)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		// A missing last chunk means that the object was truncated.
+		if err == io.EOF {
+			return ErrDecrypt
+		}
+		return err
+	}
+
+	// A short chunk, or a full one that nothing follows, is the last one.
+	last := err == io.ErrUnexpectedEOF
+	if !last {
+		if _, perr := c.r.Peek(1); perr == io.EOF {
+			last = true
+		}
+	}
+
+	plain, err := c.aead.Open(nil, chunkNonce(c.aead, c.counter, last), sealed[:n], nil)
+	if err != nil {
+		return ErrDecrypt
+	}
+
+	c.counter++
+	c.plain = plain
+	c.done = last
+	return nil
+}
+
+func (c *chunkReader) Close() error {
+	return c.closer.Close()
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,219 @@
+package encrypt
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/storage/compress"
+	"github.com/wptide/pkg/storage/memory"
+	"github.com/wptide/pkg/storage/storagetest"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+// fileStorage hides the streaming methods of a memory provider.
+type fileStorage struct {
+	provider *memory.Provider
+}
+
+func (f fileStorage) Kind() string          { return f.provider.Kind() }
+func (f fileStorage) CollectionRef() string { return f.provider.CollectionRef() }
+func (f fileStorage) UploadFile(filename, reference string) error {
+	return f.provider.UploadFile(filename, reference)
+}
+func (f fileStorage) DownloadFile(reference, filename string) error {
+	return f.provider.DownloadFile(reference, filename)
+}
+
+func stored(p *memory.Provider, reference string) []byte {
+	r, err := p.Get(context.Background(), reference)
+	if err != nil {
+		return nil
+	}
+	defer r.Close()
+	data, _ := ioutil.ReadAll(r)
+	return data
+}
+
+func TestNewProvider(t *testing.T) {
+	for _, size := range []int{16, 24, 32} {
+		if _, err := NewProvider(memory.NewMemoryStorage("reports"), testKey[:size]); err != nil {
+			t.Errorf("NewProvider() with a %d byte key error = %v", size, err)
+		}
+	}
+	if _, err := NewProvider(memory.NewMemoryStorage("reports"), testKey[:10]); err == nil {
+		t.Errorf("NewProvider() error = nil, want error for a 10 byte key")
+	}
+
+	p, _ := NewProvider(memory.NewMemoryStorage("reports"), testKey)
+	if p.Kind() != "memory" || p.CollectionRef() != "reports" {
+		t.Errorf("NewProvider() = %v %v, want the wrapped provider", p.Kind(), p.CollectionRef())
+	}
+}
+
+func TestProvider_Streamer(t *testing.T) {
+	p, _ := NewProvider(memory.NewMemoryStorage("reports"), testKey)
+	storagetest.TestStreamer(t, p)
+}
+
+func TestProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tide-encrypt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	large := make([]byte, 3*chunkSize+100)
+	rand.Read(large)
+
+	tests := []struct {
+		name      string
+		content   []byte
+		streaming bool
+	}{
+		{"Empty", []byte{}, true},
+		{"Small", []byte(`{"phpcs":"private"}`), true},
+		{"Exact Chunk", bytes.Repeat([]byte("a"), chunkSize), true},
+		{"Two Exact Chunks", bytes.Repeat([]byte("a"), 2*chunkSize), true},
+		{"Large", large, true},
+		{"Large Files", large, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mem := memory.NewMemoryStorage("reports")
+
+			var wrapped storage.Provider = mem
+			if !tt.streaming {
+				wrapped = fileStorage{mem}
+			}
+			p, _ := NewProvider(wrapped, testKey)
+
+			filename := filepath.Join(dir, "report.json")
+			ioutil.WriteFile(filename, tt.content, 0644)
+
+			if err := p.UploadFile(filename, "report.json"); err != nil {
+				t.Fatalf("Provider.UploadFile() error = %v", err)
+			}
+
+			raw := stored(mem, "report.json")
+			if len(tt.content) > 0 && bytes.Contains(raw, tt.content) {
+				t.Errorf("Provider.UploadFile() stored the content in cleartext")
+			}
+
+			downloaded := filepath.Join(dir, "download.json")
+			if err := p.DownloadFile("report.json", downloaded); err != nil {
+				t.Fatalf("Provider.DownloadFile() error = %v", err)
+			}
+			if got, _ := ioutil.ReadFile(downloaded); !bytes.Equal(got, tt.content) {
+				t.Errorf("Provider.DownloadFile() = %d bytes, want the %d uploaded bytes", len(got), len(tt.content))
+			}
+		})
+	}
+}
+
+func TestProvider_tampering(t *testing.T) {
+	content := bytes.Repeat([]byte("private report "), chunkSize/5)
+
+	tests := []struct {
+		name   string
+		key    []byte
+		modify func([]byte) []byte
+	}{
+		{"Other Key", []byte("fedcba9876543210fedcba9876543210"), nil},
+		{"Modified Content", testKey, func(b []byte) []byte { b[len(b)-40] ^= 1; return b }},
+		{"Truncated Chunk", testKey, func(b []byte) []byte { return b[:len(b)-10] }},
+		{"Dropped Last Chunk", testKey, func(b []byte) []byte { return b[:len(b)-(len(content)-2*chunkSize)-16] }},
+		{"Not Encrypted", testKey, func(b []byte) []byte { return content }},
+		{"Short Header", testKey, func(b []byte) []byte { return b[:20] }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mem := memory.NewMemoryStorage("reports")
+			writer, _ := NewProvider(mem, testKey)
+			writer.Put(context.Background(), "report.json", bytes.NewReader(content), nil)
+
+			if tt.modify != nil {
+				data := tt.modify(stored(mem, "report.json"))
+				mem.Put(context.Background(), "report.json", bytes.NewReader(data), nil)
+			}
+
+			reader, _ := NewProvider(mem, tt.key)
+			r, err := reader.Get(context.Background(), "report.json")
+			if err == nil {
+				_, err = ioutil.ReadAll(r)
+				r.Close()
+			}
+			if err != ErrDecrypt {
+				t.Errorf("Provider.Get() error = %v, want %v", err, ErrDecrypt)
+			}
+		})
+	}
+}
+
+func TestProvider_compress(t *testing.T) {
+	mem := memory.NewMemoryStorage("reports")
+	encrypted, _ := NewProvider(mem, testKey)
+	p, _ := compress.NewProvider(encrypted, compress.Gzip)
+
+	content := bytes.Repeat([]byte(`{"phpcs":"private"}`), 1000)
+	metadata := map[string]string{storage.ContentType: "application/json", storage.MetaSlug: "twentyseventeen"}
+	if err := p.Put(context.Background(), "report.json", bytes.NewReader(content), metadata); err != nil {
+		t.Fatalf("Provider.Put() error = %v", err)
+	}
+
+	info, _ := mem.Stat("report.json")
+	if info.ContentType != "application/octet-stream" || info.Metadata[storage.MetaSlug] != "twentyseventeen" {
+		t.Errorf("Provider.Put() stored %+v, want an octet-stream with the custom metadata", info)
+	}
+
+	if raw := stored(mem, "report.json"); len(raw) >= len(content) || !bytes.HasPrefix(raw, magic) {
+		t.Errorf("Provider.Put() stored %d bytes, want compressed and encrypted content", len(raw))
+	}
+
+	r, err := p.Get(context.Background(), "report.json")
+	if err != nil {
+		t.Fatalf("Provider.Get() error = %v", err)
+	}
+	defer r.Close()
+	if got, _ := ioutil.ReadAll(r); !bytes.Equal(got, content) {
+		t.Errorf("Provider.Get() = %d bytes, want the %d uploaded bytes", len(got), len(content))
+	}
+}
+
+func TestProvider_errors(t *testing.T) {
+	p, _ := NewProvider(memory.NewMemoryStorage("reports"), testKey)
+
+	oldRand := randReader
+	randReader = bytes.NewReader(nil)
+	if err := p.Put(context.Background(), "report.json", bytes.NewReader([]byte("{}")), nil); err == nil {
+		t.Errorf("Provider.Put() error = nil, want error without random keys")
+	}
+	randReader = oldRand
+
+	if err := p.DownloadFile("missing.json", "./missing.json"); err != storage.ErrNotExist {
+		t.Errorf("Provider.DownloadFile() error = %v, want %v", err, storage.ErrNotExist)
+	}
+	if err := p.UploadFile("./testdata/missing.json", "report.json"); err == nil {
+		t.Errorf("Provider.UploadFile() error = nil, want error for a missing file")
+	}
+
+	failing := errors.New("something went wrong")
+	if err := p.Put(context.Background(), "report.json", &errReader{failing}, nil); err == nil {
+		t.Errorf("Provider.Put() error = nil, want the read error")
+	}
+}
+
+type errReader struct {
+	err error
+}
+
+func (e *errReader) Read(p []byte) (int, error) {
+	return 0, e.err
+}
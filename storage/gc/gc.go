@@ -0,0 +1,203 @@
+// Package gc deletes old reports from storage and old audit folders from the temp folder.
+//
+// Reports are grouped into versions by the metadata written when they were uploaded:
+// the objects of a project slug with the same checksum are one version of that project.
+// Objects without a slug are never deleted, as there is no way to tell which project they belong to.
+package gc
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wptide/pkg/storage"
+)
+
+var (
+	// Using a variable so that we can mock it in tests.
+	now = time.Now
+
+	// File system operation variables.
+	readDir   = ioutil.ReadDir
+	removeAll = os.RemoveAll
+)
+
+// auditFolderPrefix is the prefix of the folders the ingest process extracts code to.
+const auditFolderPrefix = "audit-"
+
+// Policy describes which versions of a project to keep.
+type Policy struct {
+	KeepVersions int           // Number of newest versions kept per slug. Zero keeps all of them.
+	MaxAge       time.Duration // Versions older than this are deleted. Zero keeps them regardless of age.
+	KeepLatest   bool          // Never delete the newest version of a slug, however old it is.
+	DryRun       bool          // Report what would be deleted without deleting it.
+}
+
+// Result describes the objects deleted by a collection.
+type Result struct {
+	Deleted []storage.ObjectInfo // Deleted objects, or the objects that would be deleted in a dry run.
+	Kept    int                  // Number of objects kept.
+	Skipped int                  // Number of objects kept because they have no slug metadata.
+}
+
+// Collector applies a retention policy to the objects of a provider.
+type Collector struct {
+	manager storage.Manager
+	policy  Policy
+}
+
+// NewCollector returns a Collector deleting objects of the manager according to the policy.
+func NewCollector(manager storage.Manager, policy Policy) *Collector {
+	return &Collector{
+		manager: manager,
+		policy:  policy,
+	}
+}
+
+// version is the set of objects stored for a checksum of a project.
+type version struct {
+	objects  []storage.ObjectInfo
+	modified time.Time // Newest modification of the objects.
+}
+
+// Collect deletes the objects with a reference starting with prefix that the policy does not keep.
+// On an error the result lists the objects deleted so far.
+func (c *Collector) Collect(prefix string) (*Result, error) {
+	if c.policy.KeepVersions < 0 || c.policy.MaxAge < 0 {
+		return nil, errors.New("gc: invalid retention policy")
+	}
+
+	objects, err := c.manager.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	slugs := make(map[string]map[string]*version)
+
+	for _, info := range objects {
+		// Providers like S3 only return the metadata of a single object.
+		if info.Metadata == nil {
+			if info, err = c.manager.Stat(info.Reference); err == storage.ErrNotExist {
+				continue
+			} else if err != nil {
+				return result, err
+			}
+		}
+
+		slug := info.Metadata[storage.MetaSlug]
+		if slug == "" {
+			result.Skipped++
+			continue
+		}
+
+		// Objects without a checksum are versions of their own.
+		checksum := info.Metadata[storage.MetaChecksum]
+		if checksum == "" {
+			checksum = info.Reference
+		}
+
+		if slugs[slug] == nil {
+			slugs[slug] = make(map[string]*version)
+		}
+		v := slugs[slug][checksum]
+		if v == nil {
+			v = &version{}
+			slugs[slug][checksum] = v
+		}
+		v.objects = append(v.objects, info)
+		if info.Modified.After(v.modified) {
+			v.modified = info.Modified
+		}
+	}
+
+	var expired []storage.ObjectInfo
+	for _, versions := range slugs {
+		for _, v := range c.expired(versions) {
+			expired = append(expired, v.objects...)
+		}
+		for _, v := range versions {
+			result.Kept += len(v.objects)
+		}
+	}
+	result.Kept -= len(expired)
+
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].Reference < expired[j].Reference
+	})
+
+	if c.policy.DryRun {
+		result.Deleted = expired
+		return result, nil
+	}
+
+	for _, info := range expired {
+		if err := c.manager.Delete(info.Reference); err != nil {
+			return result, err
+		}
+		result.Deleted = append(result.Deleted, info)
+	}
+
+	return result, nil
+}
+
+// expired returns the versions of a project that the policy does not keep.
+func (c *Collector) expired(versions map[string]*version) []*version {
+	var sorted []*version
+	for _, v := range versions {
+		sorted = append(sorted, v)
+	}
+
+	// Newest first.
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].modified.After(sorted[j].modified)
+	})
+
+	cutoff := now().Add(-c.policy.MaxAge)
+
+	var expired []*version
+	for i, v := range sorted {
+		if i == 0 && c.policy.KeepLatest {
+			continue
+		}
+		if (c.policy.KeepVersions > 0 && i >= c.policy.KeepVersions) ||
+			(c.policy.MaxAge > 0 && v.modified.Before(cutoff)) {
+			expired = append(expired, v)
+		}
+	}
+
+	return expired
+}
+
+// CleanTempFolder removes the audit folders in dir that were last modified more than maxAge ago
+// and returns their paths. In a dry run the folders are only returned.
+// The maxAge should be longer than an audit takes, so that folders in use are kept.
+func CleanTempFolder(dir string, maxAge time.Duration, dryRun bool) ([]string, error) {
+	infos, err := readDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := now().Add(-maxAge)
+
+	var removed []string
+	for _, info := range infos {
+		if !info.IsDir() || !strings.HasPrefix(info.Name(), auditFolderPrefix) || !info.ModTime().Before(cutoff) {
+			continue
+		}
+
+		path := filepath.Join(dir, info.Name())
+		if !dryRun {
+			if err := removeAll(path); err != nil {
+				return removed, err
+			}
+		}
+		removed = append(removed, path)
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,282 @@
+package gc
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/storage"
+)
+
+var testNow = time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// mockManager keeps object details without content.
+type mockManager struct {
+	objects  map[string]storage.ObjectInfo
+	listOnly bool // List does not return metadata, like S3.
+	fail     string
+}
+
+func (m *mockManager) Kind() string                                  { return "mock" }
+func (m *mockManager) CollectionRef() string                         { return "mock-collection" }
+func (m *mockManager) UploadFile(filename, reference string) error   { return nil }
+func (m *mockManager) DownloadFile(reference, filename string) error { return nil }
+
+func (m *mockManager) Exists(reference string) (bool, error) {
+	_, ok := m.objects[reference]
+	return ok, nil
+}
+
+func (m *mockManager) List(prefix string) ([]storage.ObjectInfo, error) {
+	if m.fail == "list" {
+		return nil, errors.New("something went wrong")
+	}
+
+	var objects []storage.ObjectInfo
+	for reference, info := range m.objects {
+		if strings.HasPrefix(reference, prefix) {
+			if m.listOnly {
+				info.Metadata = nil
+			}
+			objects = append(objects, info)
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Reference < objects[j].Reference
+	})
+	return objects, nil
+}
+
+func (m *mockManager) Delete(reference string) error {
+	if m.fail == reference {
+		return errors.New("something went wrong")
+	}
+	delete(m.objects, reference)
+	return nil
+}
+
+func (m *mockManager) Stat(reference string) (storage.ObjectInfo, error) {
+	if m.fail == "stat" {
+		return storage.ObjectInfo{}, errors.New("something went wrong")
+	}
+	info, ok := m.objects[reference]
+	if !ok {
+		return storage.ObjectInfo{}, storage.ErrNotExist
+	}
+	return info, nil
+}
+
+// newManager returns a manager with the phpcs and lighthouse reports of three versions of
+// twentyseventeen, one version of akismet and an object without metadata.
+func newManager() *mockManager {
+	m := &mockManager{objects: make(map[string]storage.ObjectInfo)}
+	add := func(reference, slug, checksum string, age time.Duration) {
+		info := storage.ObjectInfo{Reference: reference, Modified: testNow.Add(-age)}
+		if slug != "" {
+			info.Metadata = map[string]string{storage.MetaSlug: slug, storage.MetaChecksum: checksum}
+		}
+		m.objects[reference] = info
+	}
+
+	day := 24 * time.Hour
+	add("v1-phpcs.json", "twentyseventeen", "v1", 30*day)
+	add("v1-lighthouse.json", "twentyseventeen", "v1", 29*day)
+	add("v2-phpcs.json", "twentyseventeen", "v2", 10*day)
+	add("v2-lighthouse.json", "twentyseventeen", "v2", 10*day)
+	add("v3-phpcs.json", "twentyseventeen", "v3", day)
+	add("a1-phpcs.json", "akismet", "a1", 60*day)
+	add("legacy.json", "", "", 90*day)
+
+	return m
+}
+
+func references(objects []storage.ObjectInfo) []string {
+	var refs []string
+	for _, info := range objects {
+		refs = append(refs, info.Reference)
+	}
+	return refs
+}
+
+func TestCollector_Collect(t *testing.T) {
+	oldNow := now
+	now = func() time.Time { return testNow }
+	defer func() { now = oldNow }()
+
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name        string
+		policy      Policy
+		listOnly    bool
+		wantDeleted []string
+		wantKept    int
+	}{
+		{
+			"Keep All",
+			Policy{},
+			false,
+			nil,
+			6,
+		},
+		{
+			"Keep Last Version",
+			Policy{KeepVersions: 1},
+			false,
+			[]string{"v1-lighthouse.json", "v1-phpcs.json", "v2-lighthouse.json", "v2-phpcs.json"},
+			2,
+		},
+		{
+			"Keep Last Two Versions",
+			Policy{KeepVersions: 2},
+			false,
+			[]string{"v1-lighthouse.json", "v1-phpcs.json"},
+			4,
+		},
+		{
+			"Max Age",
+			Policy{MaxAge: 20 * day},
+			false,
+			[]string{"a1-phpcs.json", "v1-lighthouse.json", "v1-phpcs.json"},
+			3,
+		},
+		{
+			"Max Age Keep Latest",
+			Policy{MaxAge: 20 * day, KeepLatest: true},
+			false,
+			[]string{"v1-lighthouse.json", "v1-phpcs.json"},
+			4,
+		},
+		{
+			"Versions And Age",
+			Policy{KeepVersions: 2, MaxAge: 5 * day, KeepLatest: true},
+			false,
+			[]string{"v1-lighthouse.json", "v1-phpcs.json", "v2-lighthouse.json", "v2-phpcs.json"},
+			2,
+		},
+		{
+			"Metadata From Stat",
+			Policy{KeepVersions: 1},
+			true,
+			[]string{"v1-lighthouse.json", "v1-phpcs.json", "v2-lighthouse.json", "v2-phpcs.json"},
+			2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, dryRun := range []bool{true, false} {
+				m := newManager()
+				m.listOnly = tt.listOnly
+
+				policy := tt.policy
+				policy.DryRun = dryRun
+
+				result, err := NewCollector(m, policy).Collect("")
+				if err != nil {
+					t.Fatalf("Collector.Collect() error = %v", err)
+				}
+				if got := references(result.Deleted); !reflect.DeepEqual(got, tt.wantDeleted) {
+					t.Errorf("Collector.Collect() dry run %v Deleted = %v, want %v", dryRun, got, tt.wantDeleted)
+				}
+				if result.Kept != tt.wantKept || result.Skipped != 1 {
+					t.Errorf("Collector.Collect() Kept, Skipped = %d, %d, want %d, 1", result.Kept, result.Skipped, tt.wantKept)
+				}
+
+				remaining := len(m.objects)
+				if dryRun && remaining != 7 {
+					t.Errorf("Collector.Collect() deleted %d objects in a dry run", 7-remaining)
+				}
+				if !dryRun && remaining != 7-len(tt.wantDeleted) {
+					t.Errorf("Collector.Collect() left %d objects, want %d", remaining, 7-len(tt.wantDeleted))
+				}
+			}
+		})
+	}
+}
+
+func TestCollector_Collect_errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      Policy
+		fail        string
+		wantDeleted int
+	}{
+		{"Invalid Policy", Policy{KeepVersions: -1}, "", 0},
+		{"List Error", Policy{KeepVersions: 1}, "list", 0},
+		{"Stat Error", Policy{KeepVersions: 1}, "stat", 0},
+		{"Delete Error", Policy{KeepVersions: 1}, "v2-lighthouse.json", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newManager()
+			m.fail = tt.fail
+			m.listOnly = tt.fail == "stat"
+
+			result, err := NewCollector(m, tt.policy).Collect("")
+			if err == nil {
+				t.Errorf("Collector.Collect() error = nil, want error")
+			}
+			if result != nil && len(result.Deleted) != tt.wantDeleted {
+				t.Errorf("Collector.Collect() Deleted = %v, want %d objects", references(result.Deleted), tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestCleanTempFolder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tide-gc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	old := testNow.Add(-48 * time.Hour)
+	for name, modified := range map[string]time.Time{
+		"audit-old":     old,
+		"audit-current": testNow,
+		"other-old":     old,
+	} {
+		path := filepath.Join(dir, name)
+		os.Mkdir(path, os.ModePerm)
+		os.Chtimes(path, modified, modified)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "audit-file"), []byte("{}"), 0644)
+	os.Chtimes(filepath.Join(dir, "audit-file"), old, old)
+
+	oldNow := now
+	now = func() time.Time { return testNow }
+	defer func() { now = oldNow }()
+
+	want := []string{filepath.Join(dir, "audit-old")}
+
+	removed, err := CleanTempFolder(dir, 24*time.Hour, true)
+	if err != nil || !reflect.DeepEqual(removed, want) {
+		t.Errorf("CleanTempFolder() dry run = %v, %v, want %v", removed, err, want)
+	}
+	if _, err := os.Stat(want[0]); err != nil {
+		t.Errorf("CleanTempFolder() removed a folder in a dry run")
+	}
+
+	removed, err = CleanTempFolder(dir, 24*time.Hour, false)
+	if err != nil || !reflect.DeepEqual(removed, want) {
+		t.Errorf("CleanTempFolder() = %v, %v, want %v", removed, err, want)
+	}
+	if _, err := os.Stat(want[0]); !os.IsNotExist(err) {
+		t.Errorf("CleanTempFolder() did not remove %s", want[0])
+	}
+	for _, name := range []string{"audit-current", "other-old", "audit-file"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("CleanTempFolder() removed %s", name)
+		}
+	}
+
+	if _, err := CleanTempFolder(filepath.Join(dir, "missing"), time.Hour, false); err == nil {
+		t.Errorf("CleanTempFolder() error = nil, want error for a missing folder")
+	}
+}
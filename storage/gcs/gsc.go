@@ -2,22 +2,42 @@ package gcs
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"net/url"
 	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wptide/pkg/storage"
 )
 
 var (
 	fileCreate    = os.Create
 	fileOpen      = os.Open
 	storageObject = GSCClient(context.Background())
+	getenv        = os.Getenv
+	readFile      = ioutil.ReadFile
+	now           = time.Now
 )
 
+func init() {
+	storage.Register("gs", Open)
+}
+
 // Provider describes the GCS provider.
 type Provider struct {
 	ctx        context.Context
 	client     *client
 	projectID  *string
 	bucketName *string
+	prefix     string // Prepended to every reference, e.g. "reports/".
+	accessID   string // Service account email used to sign download links.
+	privateKey []byte // PEM private key of the service account.
 }
 
 // Kind returns the kind of provider.
@@ -26,8 +46,11 @@ func (p Provider) Kind() string {
 }
 
 
-// CollectionRef returns an reference to a storage collection/bucket.
+// CollectionRef returns an reference to a storage collection/bucket, including the name prefix if there is one.
 func (p Provider) CollectionRef() string {
+	if p.prefix != "" {
+		return *p.bucketName + "/" + strings.TrimSuffix(p.prefix, "/")
+	}
 	return *p.bucketName
 }
 
@@ -43,7 +66,7 @@ func (p Provider) UploadFile(filename, reference string) error {
 	}
 	defer file.Close()
 
-	w, _ := storageObject.GetWriteCloser(*p.bucketName, reference)
+	w, _ := storageObject.GetWriteCloser(*p.bucketName, p.key(reference))
 	defer w.Close()
 
 	// Copy from file to object.
@@ -66,7 +89,7 @@ func (p Provider) DownloadFile(reference, filename string) error {
 	defer file.Close()
 
 	// Object to read from.
-	r, err := storageObject.GetReadCloser(*p.bucketName, reference)
+	r, err := storageObject.GetReadCloser(*p.bucketName, p.key(reference))
 	defer r.Close()
 
 	// Copy from object to file.
@@ -77,6 +100,129 @@ func (p Provider) DownloadFile(reference, filename string) error {
 	return nil
 }
 
+// Put writes the content of r to an object with metadata as custom object metadata.
+// The storage.ContentEncoding and storage.ContentType keys set the headers of the object instead.
+func (p Provider) Put(ctx context.Context, reference string, r io.Reader, metadata map[string]string) error {
+	// Cancelling the context aborts the upload if the content can't be read.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var w io.WriteCloser
+	var err error
+	if client, ok := storageObject.(StreamClient); ok {
+		w, err = client.NewWriter(ctx, *p.bucketName, p.key(reference), metadata)
+	} else {
+		w, err = storageObject.GetWriteCloser(*p.bucketName, p.key(reference))
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		cancel()
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+// Get returns a reader for the object. The reader must be closed.
+func (p Provider) Get(ctx context.Context, reference string) (io.ReadCloser, error) {
+	var r io.ReadCloser
+	var err error
+	if client, ok := storageObject.(StreamClient); ok {
+		r, err = client.NewReader(ctx, *p.bucketName, p.key(reference))
+	} else {
+		r, err = storageObject.GetReadCloser(*p.bucketName, p.key(reference))
+	}
+
+	if err == ErrObjectNotExist {
+		return nil, storage.ErrNotExist
+	}
+	return r, err
+}
+
+// SetSigningKey sets the service account used to sign download links.
+func (p *Provider) SetSigningKey(accessID string, privateKey []byte) {
+	p.accessID = accessID
+	p.privateKey = privateKey
+}
+
+// SignedURL returns a link to download the object without credentials until the ttl expires.
+// It requires a service account key, see SetSigningKey.
+func (p Provider) SignedURL(reference string, ttl time.Duration) (string, error) {
+	if p.accessID == "" || len(p.privateKey) == 0 {
+		return "", errors.New("gcs: no service account key to sign urls with")
+	}
+	return signedURLInterface(*p.bucketName, p.key(reference), p.accessID, p.privateKey, now().Add(ttl))
+}
+
+// Exists reports whether there is an object for the reference.
+func (p Provider) Exists(reference string) (bool, error) {
+	_, err := p.Stat(reference)
+	if err == storage.ErrNotExist {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// List returns the objects with a name starting with prefix.
+func (p Provider) List(prefix string) ([]storage.ObjectInfo, error) {
+	client, err := objectClient()
+	if err != nil {
+		return nil, err
+	}
+
+	attrs, err := client.List(*p.bucketName, p.key(prefix))
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []storage.ObjectInfo
+	for _, a := range attrs {
+		objects = append(objects, p.objectInfo(a))
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Reference < objects[j].Reference
+	})
+
+	return objects, nil
+}
+
+// Delete removes the object for the reference.
+func (p Provider) Delete(reference string) error {
+	client, err := objectClient()
+	if err != nil {
+		return err
+	}
+
+	err = client.Delete(*p.bucketName, p.key(reference))
+	if err == ErrObjectNotExist {
+		return nil
+	}
+	return err
+}
+
+// Stat returns the details of the object for the reference.
+func (p Provider) Stat(reference string) (storage.ObjectInfo, error) {
+	client, err := objectClient()
+	if err != nil {
+		return storage.ObjectInfo{}, err
+	}
+
+	attrs, err := client.Stat(*p.bucketName, p.key(reference))
+	if err == ErrObjectNotExist {
+		return storage.ObjectInfo{}, storage.ErrNotExist
+	}
+	if err != nil {
+		return storage.ObjectInfo{}, err
+	}
+
+	return p.objectInfo(attrs), nil
+}
+
 // NewCloudStorageProvider creates a new GCS provider.
 func NewCloudStorageProvider(ctx context.Context, projectID string, bucketName string) *Provider {
 	return &Provider{
@@ -85,3 +231,70 @@ func NewCloudStorageProvider(ctx context.Context, projectID string, bucketName s
 		bucketName: &bucketName,
 	}
 }
+
+// objectClient returns the storage client if it supports object operations.
+func objectClient() (ObjectClient, error) {
+	client, ok := storageObject.(ObjectClient)
+	if !ok {
+		return nil, errors.New("storage client does not support object operations")
+	}
+	return client, nil
+}
+
+// Open returns a provider for a url like "gs://bucket/prefix?project=my-project".
+// The project falls back to GOOGLE_CLOUD_PROJECT and the client uses the
+// application default credentials, e.g. from GOOGLE_APPLICATION_CREDENTIALS.
+// A service account key in GOOGLE_APPLICATION_CREDENTIALS is also used to sign urls.
+func Open(ctx context.Context, u *url.URL) (storage.Provider, error) {
+	if u.Host == "" {
+		return nil, errors.New("gcs: no bucket in url")
+	}
+
+	projectID := u.Query().Get("project")
+	if projectID == "" {
+		projectID = getenv("GOOGLE_CLOUD_PROJECT")
+	}
+
+	p := NewCloudStorageProvider(ctx, projectID, u.Host)
+	p.prefix = storage.Prefix(u)
+
+	// Service account credentials can also sign download links.
+	if filename := getenv("GOOGLE_APPLICATION_CREDENTIALS"); filename != "" {
+		data, err := readFile(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		var key serviceAccountKey
+		if err := json.Unmarshal(data, &key); err != nil {
+			return nil, fmt.Errorf("gcs: invalid credentials in %s: %s", filename, err)
+		}
+		if key.Type == "service_account" {
+			p.SetSigningKey(key.ClientEmail, []byte(key.PrivateKey))
+		}
+	}
+
+	return p, nil
+}
+
+// serviceAccountKey is the part of a service account key file used to sign urls.
+type serviceAccountKey struct {
+	Type        string `json:"type"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+}
+
+// key returns the object name for a reference.
+func (p Provider) key(reference string) string {
+	return p.prefix + reference
+}
+
+func (p Provider) objectInfo(attrs ObjectAttrs) storage.ObjectInfo {
+	return storage.ObjectInfo{
+		Reference:   strings.TrimPrefix(attrs.Name, p.prefix),
+		Size:        attrs.Size,
+		Modified:    attrs.Updated,
+		ContentType: attrs.ContentType,
+		Metadata:    attrs.Metadata,
+	}
+}
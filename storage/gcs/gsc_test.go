@@ -1,14 +1,21 @@
 package gcs
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"cloud.google.com/go/storage"
+	tidestorage "github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/storage/storagetest"
 )
 
 type mockStorageClient struct{}
@@ -295,3 +302,244 @@ func TestProvider_DownloadFile(t *testing.T) {
 		})
 	}
 }
+
+// bucketClient is a mock storage client keeping objects in memory.
+type bucketClient struct {
+	objects map[string][]byte
+}
+
+type bucketWriter struct {
+	bytes.Buffer
+	close func([]byte)
+}
+
+func (w *bucketWriter) Close() error {
+	w.close(w.Bytes())
+	return nil
+}
+
+func (b *bucketClient) GetWriteCloser(bucket, ref string) (io.WriteCloser, error) {
+	return &bucketWriter{close: func(data []byte) { b.objects[ref] = data }}, nil
+}
+
+func (b *bucketClient) GetReadCloser(bucket, ref string) (io.ReadCloser, error) {
+	data, ok := b.objects[ref]
+	if !ok {
+		return nil, ErrObjectNotExist
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (b *bucketClient) List(bucket, prefix string) ([]ObjectAttrs, error) {
+	var objects []ObjectAttrs
+	for name := range b.objects {
+		if strings.HasPrefix(name, prefix) {
+			attrs, _ := b.Stat(bucket, name)
+			objects = append(objects, attrs)
+		}
+	}
+	return objects, nil
+}
+
+func (b *bucketClient) Stat(bucket, ref string) (ObjectAttrs, error) {
+	data, ok := b.objects[ref]
+	if !ok {
+		return ObjectAttrs{}, ErrObjectNotExist
+	}
+	return ObjectAttrs{Name: ref, Size: int64(len(data)), Updated: time.Now()}, nil
+}
+
+func (b *bucketClient) Delete(bucket, ref string) error {
+	if _, ok := b.objects[ref]; !ok {
+		return ErrObjectNotExist
+	}
+	delete(b.objects, ref)
+	return nil
+}
+
+func TestProvider_Manager(t *testing.T) {
+	storageObject = &bucketClient{objects: make(map[string][]byte)}
+	defer func() { storageObject = GSCClient(context.Background()) }()
+
+	storagetest.TestManager(t, NewCloudStorageProvider(context.Background(), "project", "bucket"))
+
+	t.Run("Unsupported Client", func(t *testing.T) {
+		storageObject = &mockStorageClient{}
+		if _, err := NewCloudStorageProvider(context.Background(), "project", "bucket").List(""); err == nil {
+			t.Errorf("Provider.List() error = nil, want error")
+		}
+	})
+}
+
+// streamClient is a mock StreamClient that discards uploads when the context is cancelled.
+type streamClient struct {
+	*bucketClient
+	metadata map[string]map[string]string
+}
+
+func (c *streamClient) NewWriter(ctx context.Context, bucket, ref string, metadata map[string]string) (io.WriteCloser, error) {
+	c.metadata[ref] = metadata
+	return &bucketWriter{close: func(data []byte) {
+		if ctx.Err() == nil {
+			c.objects[ref] = data
+		}
+	}}, nil
+}
+
+func (c *streamClient) NewReader(ctx context.Context, bucket, ref string) (io.ReadCloser, error) {
+	return c.GetReadCloser(bucket, ref)
+}
+
+func TestProvider_Streamer(t *testing.T) {
+	defer func() { storageObject = GSCClient(context.Background()) }()
+
+	t.Run("Storage Client", func(t *testing.T) {
+		storageObject = &bucketClient{objects: make(map[string][]byte)}
+		storagetest.TestStreamer(t, NewCloudStorageProvider(context.Background(), "project", "bucket"))
+	})
+
+	t.Run("Stream Client", func(t *testing.T) {
+		client := &streamClient{
+			bucketClient: &bucketClient{objects: make(map[string][]byte)},
+			metadata:     make(map[string]map[string]string),
+		}
+		storageObject = client
+		storagetest.TestStreamer(t, NewCloudStorageProvider(context.Background(), "project", "bucket"))
+
+		if got := client.metadata["abc-stream.json"]; got["tool"] != "phpcs" {
+			t.Errorf("Provider.Put() metadata = %v, want tool phpcs", got)
+		}
+		if _, ok := client.objects["abc-failed.json"]; ok {
+			t.Errorf("Provider.Put() committed a partial object after a read error")
+		}
+	})
+}
+
+func TestProvider_prefix(t *testing.T) {
+	client := &bucketClient{objects: map[string][]byte{"other.json": []byte("{}")}}
+	storageObject = client
+	defer func() { storageObject = GSCClient(context.Background()) }()
+
+	p := NewCloudStorageProvider(context.Background(), "project", "bucket")
+	p.prefix = "reports/"
+
+	storagetest.TestManager(t, p)
+
+	if _, ok := client.objects["reports/abc-phpcs.json"]; !ok {
+		t.Errorf("Provider.UploadFile() did not prefix the name, objects: %v", client.objects)
+	}
+	if got := p.CollectionRef(); got != "bucket/reports" {
+		t.Errorf("Provider.CollectionRef() = %v, want bucket/reports", got)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	oldGetenv := getenv
+	getenv = func(key string) string {
+		if key == "GOOGLE_CLOUD_PROJECT" {
+			return "env-project"
+		}
+		return ""
+	}
+	defer func() { getenv = oldGetenv }()
+
+	tests := []struct {
+		name        string
+		rawurl      string
+		wantBucket  string
+		wantPrefix  string
+		wantProject string
+		wantErr     bool
+	}{
+		{"Project In URL", "gs://bucket/reports/?project=tide", "bucket", "reports/", "tide", false},
+		{"Project From Env", "gs://bucket", "bucket", "", "env-project", false},
+		{"No Bucket", "gs:///reports", "", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tidestorage.Open(context.Background(), tt.rawurl)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("storage.Open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			p := got.(*Provider)
+			if *p.bucketName != tt.wantBucket || p.prefix != tt.wantPrefix || *p.projectID != tt.wantProject {
+				t.Errorf("storage.Open() = %v %v %v, want %v %v %v", *p.bucketName, p.prefix, *p.projectID,
+					tt.wantBucket, tt.wantPrefix, tt.wantProject)
+			}
+		})
+	}
+}
+
+func TestProvider_SignedURL(t *testing.T) {
+	oldSigned, oldNow := signedURLInterface, now
+	defer func() { signedURLInterface, now = oldSigned, oldNow }()
+
+	now = func() time.Time { return time.Unix(1500000000, 0) }
+	signedURLInterface = func(bucket, name, accessID string, privateKey []byte, expires time.Time) (string, error) {
+		return fmt.Sprintf("https://storage.googleapis.com/%s/%s?GoogleAccessId=%s&Expires=%d", bucket, name, accessID, expires.Unix()), nil
+	}
+
+	p := NewCloudStorageProvider(context.Background(), "project", "bucket")
+	p.prefix = "reports/"
+
+	if _, err := p.SignedURL("abc-phpcs.json", time.Hour); err == nil {
+		t.Errorf("Provider.SignedURL() error = nil, want error without a signing key")
+	}
+
+	p.SetSigningKey("tide@project.iam.gserviceaccount.com", []byte("key"))
+	got, err := p.SignedURL("abc-phpcs.json", time.Hour)
+	want := "https://storage.googleapis.com/bucket/reports/abc-phpcs.json?GoogleAccessId=tide@project.iam.gserviceaccount.com&Expires=1500003600"
+	if err != nil || got != want {
+		t.Errorf("Provider.SignedURL() = %v, %v, want %v", got, err, want)
+	}
+}
+
+func TestOpen_credentials(t *testing.T) {
+	oldGetenv, oldReadFile := getenv, readFile
+	defer func() { getenv, readFile = oldGetenv, oldReadFile }()
+
+	getenv = func(key string) string {
+		if key == "GOOGLE_APPLICATION_CREDENTIALS" {
+			return "credentials.json"
+		}
+		return ""
+	}
+
+	tests := []struct {
+		name         string
+		credentials  string
+		readErr      error
+		wantAccessID string
+		wantErr      bool
+	}{
+		{
+			"Service Account",
+			`{"type":"service_account","client_email":"tide@project.iam.gserviceaccount.com","private_key":"key"}`,
+			nil,
+			"tide@project.iam.gserviceaccount.com",
+			false,
+		},
+		{"User Credentials", `{"type":"authorized_user"}`, nil, "", false},
+		{"Invalid Credentials", `not json`, nil, "", true},
+		{"Missing Credentials", "", errors.New("no such file"), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readFile = func(filename string) ([]byte, error) {
+				return []byte(tt.credentials), tt.readErr
+			}
+
+			got, err := tidestorage.Open(context.Background(), "gs://bucket")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("storage.Open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.(*Provider).accessID != tt.wantAccessID {
+				t.Errorf("storage.Open() accessID = %v, want %v", got.(*Provider).accessID, tt.wantAccessID)
+			}
+		})
+	}
+}
@@ -1,9 +1,39 @@
 package gcs
 
-import "io"
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
 
 // StorageClient interface describes a new storage client.
 type StorageClient interface {
 	GetWriteCloser(bucket, ref string) (io.WriteCloser, error)
 	GetReadCloser(bucket, ref string) (io.ReadCloser, error)
 }
+
+// ObjectClient is implemented by storage clients that can look up and remove objects.
+type ObjectClient interface {
+	List(bucket, prefix string) ([]ObjectAttrs, error)
+	Stat(bucket, ref string) (ObjectAttrs, error) // Returns ErrObjectNotExist for a missing object.
+	Delete(bucket, ref string) error              // Returns ErrObjectNotExist for a missing object.
+}
+
+// StreamClient is implemented by storage clients that can read and write objects with a context and metadata.
+type StreamClient interface {
+	NewWriter(ctx context.Context, bucket, ref string, metadata map[string]string) (io.WriteCloser, error)
+	NewReader(ctx context.Context, bucket, ref string) (io.ReadCloser, error) // Returns ErrObjectNotExist for a missing object.
+}
+
+// ObjectAttrs describes a stored object.
+type ObjectAttrs struct {
+	Name        string
+	Size        int64
+	Updated     time.Time
+	ContentType string
+	Metadata    map[string]string
+}
+
+// ErrObjectNotExist is returned by an ObjectClient when an object does not exist.
+var ErrObjectNotExist = errors.New("gcs: object does not exist")
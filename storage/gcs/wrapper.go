@@ -3,13 +3,17 @@ package gcs
 import (
 	"context"
 	"io"
+	"time"
 
 	"cloud.google.com/go/storage"
+	tidestorage "github.com/wptide/pkg/storage"
+	"google.golang.org/api/iterator"
 )
 
 // Provides a way to return an alternate objectHandle. Used for testing.
 var objectWriterInterface = objectWriter
 var objectReaderInterface = objectReader
+var signedURLInterface = signedURL
 
 // Interface which storage.Client implicitly implements.
 type client interface {
@@ -55,6 +59,16 @@ func objectReader(ctx context.Context, obj objectHandle) (io.ReadCloser, error)
 	return obj.NewReader(ctx)
 }
 
+// signedURL returns a link to GET an object signed with a service account key.
+func signedURL(bucket, name, accessID string, privateKey []byte, expires time.Time) (string, error) {
+	return storage.SignedURL(bucket, name, &storage.SignedURLOptions{
+		GoogleAccessID: accessID,
+		PrivateKey:     privateKey,
+		Method:         "GET",
+		Expires:        expires,
+	})
+}
+
 // GetWriteCloser gets a new io.WriteCloser for the storage client.
 func (s *Storage) GetWriteCloser(bucket, ref string) (io.WriteCloser, error) {
 	obj := s.getObject(s.getBucket(bucket), ref)
@@ -67,11 +81,100 @@ func (s *Storage) GetReadCloser(bucket, ref string) (io.ReadCloser, error) {
 	return objectReaderInterface(s.ctx, obj)
 }
 
+// NewWriter returns a writer for an object with extra metadata.
+// The ContentEncoding and ContentType metadata keys set the headers of the object instead.
+// Cancel the context to abort the upload.
+func (s *Storage) NewWriter(ctx context.Context, bucket, ref string, metadata map[string]string) (io.WriteCloser, error) {
+	w, err := objectWriterInterface(ctx, s.getObject(s.getBucket(bucket), ref))
+	if err != nil {
+		return nil, err
+	}
+
+	if sw, ok := w.(*storage.Writer); ok {
+		sw.ContentType = tidestorage.TypeByExtension(ref)
+		for key, value := range metadata {
+			switch key {
+			case tidestorage.ContentEncoding:
+				sw.ContentEncoding = value
+			case tidestorage.ContentType:
+				sw.ContentType = value
+			default:
+				sw.Metadata[key] = value
+			}
+		}
+	}
+
+	return w, nil
+}
+
+// NewReader returns a reader for an object.
+func (s *Storage) NewReader(ctx context.Context, bucket, ref string) (io.ReadCloser, error) {
+	r, err := objectReaderInterface(ctx, s.getObject(s.getBucket(bucket), ref))
+	if err == storage.ErrObjectNotExist {
+		return nil, ErrObjectNotExist
+	}
+	return r, err
+}
+
+// List returns the attributes of the objects with a name starting with prefix.
+func (s *Storage) List(bucket, prefix string) ([]ObjectAttrs, error) {
+	it := s.client.Bucket(bucket).Objects(s.ctx, &storage.Query{Prefix: prefix})
+
+	var objects []ObjectAttrs
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, ObjectAttrs{
+			Name:        attrs.Name,
+			Size:        attrs.Size,
+			Updated:     attrs.Updated,
+			ContentType: attrs.ContentType,
+			Metadata:    attrs.Metadata,
+		})
+	}
+
+	return objects, nil
+}
+
+// Stat returns the attributes of an object.
+func (s *Storage) Stat(bucket, ref string) (ObjectAttrs, error) {
+	attrs, err := s.client.Bucket(bucket).Object(ref).Attrs(s.ctx)
+	if err == storage.ErrObjectNotExist {
+		return ObjectAttrs{}, ErrObjectNotExist
+	}
+	if err != nil {
+		return ObjectAttrs{}, err
+	}
+
+	return ObjectAttrs{
+		Name:        attrs.Name,
+		Size:        attrs.Size,
+		Updated:     attrs.Updated,
+		ContentType: attrs.ContentType,
+		Metadata:    attrs.Metadata,
+	}, nil
+}
+
+// Delete removes an object.
+func (s *Storage) Delete(bucket, ref string) error {
+	err := s.client.Bucket(bucket).Object(ref).Delete(s.ctx)
+	if err == storage.ErrObjectNotExist {
+		return ErrObjectNotExist
+	}
+	return err
+}
+
 // GSCClient returns a new StorageClient.
 func GSCClient(ctx context.Context) StorageClient {
 	client, _ := storage.NewClient(ctx)
 
 	return &Storage{
 		client: client,
+		ctx:    ctx,
 	}
 }
@@ -1,15 +1,35 @@
 package local
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"io"
+	"io/ioutil"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wptide/pkg/storage"
 )
 
 var (
 	fileCreate = os.Create
 	fileOpen   = os.Open
+	mkdirAll   = os.MkdirAll
+	readFile   = ioutil.ReadFile
+	writeFile  = ioutil.WriteFile
 )
 
+// metadataSuffix names the sidecar file keeping the metadata of an object next to it.
+// Files with the suffix are not listed as objects.
+const metadataSuffix = ".meta.json"
+
+func init() {
+	storage.Register("file", Open)
+}
+
 // Provider is a local storage provider.
 type Provider struct {
 	serverPath string
@@ -30,7 +50,12 @@ func (p Provider) CollectionRef() string {
 func (p Provider) UploadFile(filename, reference string) error {
 	// Copy to "uploads" folder.
 	dest := p.serverPath + "/" + reference
-	return copyFile(filename, dest)
+	if err := copyFile(filename, dest); err != nil {
+		return err
+	}
+
+	// The metadata of a previous upload does not describe the new file.
+	return p.writeMetadata(reference, nil)
 }
 
 // DownloadFile copies the file from the storage provider.
@@ -40,6 +65,145 @@ func (p Provider) DownloadFile(reference, filename string) error {
 	return copyFile(src, filename)
 }
 
+// Put writes the content of r to the file for the reference and the metadata to its sidecar file.
+func (p Provider) Put(ctx context.Context, reference string, r io.Reader, metadata map[string]string) error {
+	destFile, err := fileCreate(p.serverPath + "/" + reference)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(destFile, contextReader{ctx, r}); err != nil {
+		destFile.Close()
+		os.Remove(destFile.Name())
+		return err
+	}
+
+	if err := destFile.Close(); err != nil {
+		return err
+	}
+
+	return p.writeMetadata(reference, metadata)
+}
+
+// Get opens the file for the reference.
+func (p Provider) Get(ctx context.Context, reference string) (io.ReadCloser, error) {
+	file, err := fileOpen(p.serverPath + "/" + reference)
+	if os.IsNotExist(err) {
+		return nil, storage.ErrNotExist
+	}
+	return file, err
+}
+
+// Exists reports whether there is a file for the reference.
+func (p Provider) Exists(reference string) (bool, error) {
+	_, err := p.Stat(reference)
+	if err == storage.ErrNotExist {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// List returns the files with a reference starting with prefix.
+func (p Provider) List(prefix string) ([]storage.ObjectInfo, error) {
+	var objects []storage.ObjectInfo
+
+	err := filepath.Walk(p.serverPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// An empty storage folder has no files.
+			if path == p.serverPath && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(p.serverPath, path)
+		if err != nil {
+			return err
+		}
+
+		reference := filepath.ToSlash(rel)
+		if !strings.HasPrefix(reference, prefix) || strings.HasSuffix(reference, metadataSuffix) {
+			return nil
+		}
+
+		metadata, err := p.readMetadata(reference)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, objectInfo(reference, info, metadata))
+
+		return nil
+	})
+
+	// Walk visits files in lexical order, so the objects are already sorted.
+	return objects, err
+}
+
+// Delete removes the file for the reference and its metadata.
+func (p Provider) Delete(reference string) error {
+	err := os.Remove(p.serverPath + "/" + reference)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return p.writeMetadata(reference, nil)
+}
+
+// Stat returns the details of the file for the reference.
+func (p Provider) Stat(reference string) (storage.ObjectInfo, error) {
+	info, err := os.Stat(p.serverPath + "/" + reference)
+	if os.IsNotExist(err) || (err == nil && info.IsDir()) {
+		return storage.ObjectInfo{}, storage.ErrNotExist
+	}
+	if err != nil {
+		return storage.ObjectInfo{}, err
+	}
+
+	metadata, err := p.readMetadata(reference)
+	if err != nil {
+		return storage.ObjectInfo{}, err
+	}
+	return objectInfo(reference, info, metadata), nil
+}
+
+// readMetadata returns the metadata in the sidecar file of an object, or nil if it has none.
+func (p Provider) readMetadata(reference string) (map[string]string, error) {
+	data, err := readFile(p.serverPath + "/" + reference + metadataSuffix)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata map[string]string
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, errors.New("local: invalid metadata for " + reference + ": " + err.Error())
+	}
+	return metadata, nil
+}
+
+// writeMetadata writes the metadata to the sidecar file of an object, or removes the file if there is no metadata.
+func (p Provider) writeMetadata(reference string, metadata map[string]string) error {
+	filename := p.serverPath + "/" + reference + metadataSuffix
+
+	if len(metadata) == 0 {
+		err := os.Remove(filename)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	return writeFile(filename, data, 0644)
+}
+
 // NewLocalStorage returns a local storage provider.
 func NewLocalStorage(storagePath string, localPath string) *Provider {
 	return &Provider{
@@ -48,6 +212,31 @@ func NewLocalStorage(storagePath string, localPath string) *Provider {
 	}
 }
 
+// Open returns a provider for a url like "file:///var/tide/reports?collection=reports",
+// creating the folder if needed. Relative folders are written as "file://./reports".
+// The collection defaults to the name of the folder.
+func Open(ctx context.Context, u *url.URL) (storage.Provider, error) {
+	path := u.Opaque
+	if path == "" {
+		path = u.Host + u.Path
+	}
+	if path == "" {
+		return nil, errors.New("local: no folder in url")
+	}
+	path = filepath.Clean(path)
+
+	collection := u.Query().Get("collection")
+	if collection == "" {
+		collection = filepath.Base(path)
+	}
+
+	if err := mkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+
+	return NewLocalStorage(path, collection), nil
+}
+
 func copyFile(src, dst string) error {
 
 	// Open source file to copy from.
@@ -72,3 +261,41 @@ func copyFile(src, dst string) error {
 
 	return nil
 }
+
+func objectInfo(reference string, info os.FileInfo, metadata map[string]string) storage.ObjectInfo {
+	obj := storage.ObjectInfo{
+		Reference:   reference,
+		Size:        info.Size(),
+		Modified:    info.ModTime(),
+		ContentType: storage.TypeByExtension(reference),
+	}
+
+	for key, value := range metadata {
+		switch key {
+		case storage.ContentType:
+			obj.ContentType = value
+		case storage.ContentEncoding:
+			// Served by SigningProvider, but not part of the object details.
+		default:
+			if obj.Metadata == nil {
+				obj.Metadata = make(map[string]string)
+			}
+			obj.Metadata[key] = value
+		}
+	}
+
+	return obj
+}
+
+// contextReader stops reading once the context is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -1,8 +1,16 @@
 package local
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/storage/storagetest"
 )
 
 func TestProvider_Kind(t *testing.T) {
@@ -158,3 +166,174 @@ func TestNewLocalStorage(t *testing.T) {
 		})
 	}
 }
+
+func TestProvider_Manager(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tide-local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	storagetest.TestManager(t, NewLocalStorage(dir, "subdir"))
+
+	t.Run("Missing Storage Folder", func(t *testing.T) {
+		list, err := NewLocalStorage(dir+"/missing", "subdir").List("")
+		if len(list) != 0 || err != nil {
+			t.Errorf("Provider.List() = %v, %v, want no objects", list, err)
+		}
+	})
+}
+
+func TestProvider_Streamer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tide-local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := NewLocalStorage(dir, "subdir")
+	storagetest.TestStreamer(t, p)
+
+	if ok, _ := p.Exists("abc-failed.json"); ok {
+		t.Errorf("Provider.Put() kept a partial file after a read error")
+	}
+}
+
+func TestProvider_metadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tide-local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := NewLocalStorage(dir, "subdir")
+	ctx := context.Background()
+
+	metadata := map[string]string{
+		storage.ContentType:     "application/vnd.tide+json",
+		storage.ContentEncoding: "gzip",
+		storage.MetaSlug:        "twentyseventeen",
+	}
+	if err := p.Put(ctx, "abc-phpcs.json", strings.NewReader("{}"), metadata); err != nil {
+		t.Fatalf("Provider.Put() error = %v", err)
+	}
+	p.Put(ctx, "abc-plain.json", strings.NewReader("{}"), nil)
+
+	if _, err := os.Stat(filepath.Join(dir, "abc-phpcs.json"+metadataSuffix)); err != nil {
+		t.Errorf("Provider.Put() did not write the sidecar file: %v", err)
+	}
+
+	want := map[string]storage.ObjectInfo{
+		"abc-phpcs.json": {
+			Reference:   "abc-phpcs.json",
+			ContentType: "application/vnd.tide+json",
+			Metadata:    map[string]string{storage.MetaSlug: "twentyseventeen"},
+		},
+		"abc-plain.json": {
+			Reference:   "abc-plain.json",
+			ContentType: "application/json",
+		},
+	}
+
+	list, err := p.List("")
+	if err != nil || len(list) != len(want) {
+		t.Fatalf("Provider.List() = %+v, %v, want the objects without sidecar files", list, err)
+	}
+	for _, info := range list {
+		stat, _ := p.Stat(info.Reference)
+		for _, got := range []storage.ObjectInfo{info, stat} {
+			w := want[info.Reference]
+			if got.ContentType != w.ContentType || !reflect.DeepEqual(got.Metadata, w.Metadata) {
+				t.Errorf("Provider.List() and Stat() = %+v, want %+v", got, w)
+			}
+		}
+	}
+
+	// A new upload replaces the metadata.
+	filename := filepath.Join(dir, "upload.json")
+	ioutil.WriteFile(filename, []byte("{}"), 0644)
+	if err := p.UploadFile(filename, "abc-phpcs.json"); err != nil {
+		t.Fatalf("Provider.UploadFile() error = %v", err)
+	}
+	if info, _ := p.Stat("abc-phpcs.json"); info.Metadata != nil || info.ContentType != "application/json" {
+		t.Errorf("Provider.Stat() = %+v, want no metadata after UploadFile", info)
+	}
+
+	p.Put(ctx, "abc-phpcs.json", strings.NewReader("{}"), metadata)
+	if err := p.Delete("abc-phpcs.json"); err != nil {
+		t.Fatalf("Provider.Delete() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "abc-phpcs.json"+metadataSuffix)); !os.IsNotExist(err) {
+		t.Errorf("Provider.Delete() did not remove the sidecar file")
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "abc-plain.json"+metadataSuffix), []byte("not json"), 0644)
+	if _, err := p.Stat("abc-plain.json"); err == nil {
+		t.Errorf("Provider.Stat() error = nil, want error for invalid metadata")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tide-local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name    string
+		rawurl  string
+		want    *Provider
+		wantErr bool
+	}{
+		{
+			"Absolute Folder",
+			"file://" + dir + "/reports",
+			&Provider{filepath.Join(dir, "reports"), "reports"},
+			false,
+		},
+		{
+			"Collection",
+			"file://" + dir + "/reports/?collection=audits",
+			&Provider{filepath.Join(dir, "reports"), "audits"},
+			false,
+		},
+		{
+			"Relative Folder",
+			"file://./testdata",
+			&Provider{"testdata", "testdata"},
+			false,
+		},
+		{
+			"No Folder",
+			"file://",
+			nil,
+			true,
+		},
+		{
+			"Folder Error",
+			"file://" + dir + "/file.txt/reports",
+			nil,
+			true,
+		},
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "file.txt"), nil, 0644)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := storage.Open(context.Background(), tt.rawurl)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("storage.Open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("storage.Open() = %v, want %v", got, tt.want)
+			}
+			if !tt.wantErr {
+				if info, err := os.Stat(tt.want.serverPath); err != nil || !info.IsDir() {
+					t.Errorf("storage.Open() did not create the folder %s", tt.want.serverPath)
+				}
+			}
+		})
+	}
+}
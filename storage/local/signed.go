@@ -0,0 +1,121 @@
+package local
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wptide/pkg/storage"
+)
+
+// Using a variable so that we can mock it in tests.
+var now = time.Now
+
+// SigningProvider is a local provider that signs download links and serves the files for them.
+type SigningProvider struct {
+	*Provider
+	baseURL string
+	key     []byte
+}
+
+// NewSigningProvider returns a provider signing links to baseURL with the key.
+// Serve the links by mounting the provider as an http.Handler at the path of baseURL, e.g.
+//
+//	http.Handle("/reports/", http.StripPrefix("/reports", p))
+func NewSigningProvider(p *Provider, baseURL string, key []byte) *SigningProvider {
+	return &SigningProvider{
+		Provider: p,
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		key:      key,
+	}
+}
+
+// SignedURL returns a link to download the file until the ttl expires.
+func (s *SigningProvider) SignedURL(reference string, ttl time.Duration) (string, error) {
+	if !validReference(reference) {
+		return "", errors.New("local: invalid reference " + reference)
+	}
+
+	expires := strconv.FormatInt(now().Add(ttl).Unix(), 10)
+
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", s.sign(reference, expires))
+
+	return s.baseURL + "/" + (&url.URL{Path: reference}).EscapedPath() + "?" + query.Encode(), nil
+}
+
+// ServeHTTP serves the file of a signed link that has not expired.
+func (s *SigningProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	reference := strings.TrimPrefix(r.URL.Path, "/")
+	expires := r.URL.Query().Get("expires")
+	signature := r.URL.Query().Get("signature")
+
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || !validReference(reference) ||
+		!hmac.Equal([]byte(signature), []byte(s.sign(reference, expires))) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	if now().Unix() > unix {
+		http.Error(w, "link expired", http.StatusForbidden)
+		return
+	}
+
+	file, err := fileOpen(s.serverPath + "/" + reference)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	metadata, err := s.readMetadata(reference)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", objectInfo(reference, info, metadata).ContentType)
+	if encoding := metadata[storage.ContentEncoding]; encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
+	}
+
+	http.ServeContent(w, r, path.Base(reference), info.ModTime(), file)
+}
+
+// sign returns the signature of a reference and its expiry time.
+func (s *SigningProvider) sign(reference, expires string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(reference + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// validReference reports whether a reference stays inside the storage folder.
+func validReference(reference string) bool {
+	return reference != "" && !strings.HasPrefix(reference, "/") && path.Clean(reference) == reference &&
+		reference != ".." && !strings.HasPrefix(reference, "../")
+}
@@ -0,0 +1,108 @@
+package local
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/storage"
+)
+
+func TestSigningProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tide-local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.MkdirAll(filepath.Join(dir, "reports"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "abc-phpcs.json"), []byte(`{"phpcs":true}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "reports", "abc phpcs.json"), []byte(`{"nested":true}`), 0644)
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	p := NewSigningProvider(NewLocalStorage(dir, "reports"), server.URL+"/links/", []byte("secret"))
+	mux.Handle("/links/", http.StripPrefix("/links", p))
+
+	oldNow := now
+	defer func() { now = oldNow }()
+	start := time.Now()
+	now = func() time.Time { return start }
+
+	link, _ := p.SignedURL("abc-phpcs.json", time.Minute)
+	nested, _ := p.SignedURL("reports/abc phpcs.json", time.Minute)
+	missing, _ := p.SignedURL("missing.json", time.Minute)
+	other, _ := NewSigningProvider(NewLocalStorage(dir, "reports"), server.URL+"/links", []byte("other")).SignedURL("abc-phpcs.json", time.Minute)
+
+	tests := []struct {
+		name     string
+		method   string
+		url      string
+		after    time.Duration
+		wantCode int
+		wantBody string
+	}{
+		{"Valid Link", http.MethodGet, link, 0, http.StatusOK, `{"phpcs":true}`},
+		{"Nested Reference", http.MethodGet, nested, 0, http.StatusOK, `{"nested":true}`},
+		{"Expired Link", http.MethodGet, link, 2 * time.Minute, http.StatusForbidden, ""},
+		{"Other Key", http.MethodGet, other, 0, http.StatusForbidden, ""},
+		{"Other Reference", http.MethodGet, strings.Replace(link, "abc-phpcs", "def-phpcs", 1), 0, http.StatusForbidden, ""},
+		{"No Signature", http.MethodGet, server.URL + "/links/abc-phpcs.json", 0, http.StatusForbidden, ""},
+		{"Missing File", http.MethodGet, missing, 0, http.StatusNotFound, ""},
+		{"Wrong Method", http.MethodPost, link, 0, http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = func() time.Time { return start.Add(tt.after) }
+
+			req, _ := http.NewRequest(tt.method, tt.url, nil)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			body, _ := ioutil.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantCode || (tt.wantBody != "" && string(body) != tt.wantBody) {
+				t.Errorf("GET %s = %d %s, want %d %s", tt.url, resp.StatusCode, body, tt.wantCode, tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Headers", func(t *testing.T) {
+		now = func() time.Time { return start }
+
+		p.Put(context.Background(), "abc-gzip.json", strings.NewReader("compressed"), map[string]string{storage.ContentEncoding: "gzip"})
+		gzipped, _ := p.SignedURL("abc-gzip.json", time.Minute)
+
+		for url, want := range map[string][2]string{
+			link:    {"application/json", ""},
+			gzipped: {"application/json", "gzip"},
+		} {
+			req, _ := http.NewRequest(http.MethodHead, url, nil)
+			resp, err := http.DefaultTransport.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if got := [2]string{resp.Header.Get("Content-Type"), resp.Header.Get("Content-Encoding")}; got != want {
+				t.Errorf("HEAD %s Content-Type, Content-Encoding = %v, want %v", url, got, want)
+			}
+		}
+	})
+
+	for _, reference := range []string{"", "../secret.json", "/etc/passwd", "reports/../../secret.json"} {
+		if _, err := p.SignedURL(reference, time.Minute); err == nil {
+			t.Errorf("SigningProvider.SignedURL(%q) error = nil, want error", reference)
+		}
+	}
+}
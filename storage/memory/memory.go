@@ -0,0 +1,211 @@
+// Package memory is a storage provider keeping objects in memory, for tests and single binary deployments.
+package memory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wptide/pkg/storage"
+)
+
+var (
+	// File system operation variables.
+	readFile  = ioutil.ReadFile
+	writeFile = ioutil.WriteFile
+
+	// Using a variable so that we can mock it in tests.
+	now = time.Now
+)
+
+func init() {
+	storage.Register("mem", Open)
+}
+
+// Provider is an in-memory storage provider. It is safe for concurrent use.
+type Provider struct {
+	name    string
+	mu      sync.RWMutex
+	objects map[string]object
+}
+
+type object struct {
+	data        []byte
+	modified    time.Time
+	contentType string
+	metadata    map[string]string
+}
+
+// Kind returns the kind of provider.
+func (p *Provider) Kind() string {
+	return "memory"
+}
+
+// CollectionRef returns the name of the provider.
+func (p *Provider) CollectionRef() string {
+	return p.name
+}
+
+// UploadFile keeps a copy of the file.
+func (p *Provider) UploadFile(filename, reference string) error {
+	data, err := readFile(filename)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.objects[reference] = object{
+		data:        data,
+		modified:    now(),
+		contentType: storage.TypeByExtension(reference),
+	}
+
+	return nil
+}
+
+// DownloadFile writes the object to a file.
+func (p *Provider) DownloadFile(reference, filename string) error {
+	p.mu.RLock()
+	obj, ok := p.objects[reference]
+	p.mu.RUnlock()
+
+	if !ok {
+		return storage.ErrNotExist
+	}
+
+	return writeFile(filename, obj.data, os.ModePerm)
+}
+
+// Put keeps the content of r and its metadata.
+func (p *Provider) Put(ctx context.Context, reference string, r io.Reader, metadata map[string]string) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	obj := object{
+		data:        data,
+		modified:    now(),
+		contentType: storage.TypeByExtension(reference),
+		metadata:    make(map[string]string),
+	}
+	for key, value := range metadata {
+		switch key {
+		case storage.ContentType:
+			obj.contentType = value
+		case storage.ContentEncoding:
+			// Objects are returned as stored, so the encoding is not kept.
+		default:
+			obj.metadata[key] = value
+		}
+	}
+
+	p.objects[reference] = obj
+
+	return nil
+}
+
+// Get returns a reader for the object.
+func (p *Provider) Get(ctx context.Context, reference string) (io.ReadCloser, error) {
+	p.mu.RLock()
+	obj, ok := p.objects[reference]
+	p.mu.RUnlock()
+
+	if !ok {
+		return nil, storage.ErrNotExist
+	}
+
+	// Objects are replaced, never changed, so the data can be shared.
+	return ioutil.NopCloser(bytes.NewReader(obj.data)), nil
+}
+
+// Exists reports whether there is an object for the reference.
+func (p *Provider) Exists(reference string) (bool, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	_, ok := p.objects[reference]
+	return ok, nil
+}
+
+// List returns the objects with a reference starting with prefix.
+func (p *Provider) List(prefix string) ([]storage.ObjectInfo, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var objects []storage.ObjectInfo
+	for reference, obj := range p.objects {
+		if strings.HasPrefix(reference, prefix) {
+			objects = append(objects, obj.info(reference))
+		}
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Reference < objects[j].Reference
+	})
+
+	return objects, nil
+}
+
+// Delete removes the object for the reference.
+func (p *Provider) Delete(reference string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.objects, reference)
+	return nil
+}
+
+// Stat returns the details of the object for the reference.
+func (p *Provider) Stat(reference string) (storage.ObjectInfo, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	obj, ok := p.objects[reference]
+	if !ok {
+		return storage.ObjectInfo{}, storage.ErrNotExist
+	}
+	return obj.info(reference), nil
+}
+
+// NewMemoryStorage returns an empty in-memory storage provider.
+func NewMemoryStorage(name string) *Provider {
+	return &Provider{
+		name:    name,
+		objects: make(map[string]object),
+	}
+}
+
+// Open returns an empty provider for a url like "mem://reports", named after the url host.
+func Open(ctx context.Context, u *url.URL) (storage.Provider, error) {
+	if u.Host == "" {
+		return nil, errors.New("memory: no name in url")
+	}
+	return NewMemoryStorage(u.Host), nil
+}
+
+func (o object) info(reference string) storage.ObjectInfo {
+	return storage.ObjectInfo{
+		Reference:   reference,
+		Size:        int64(len(o.data)),
+		Modified:    o.modified,
+		ContentType: o.contentType,
+		Metadata:    o.metadata,
+	}
+}
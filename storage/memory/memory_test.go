@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/storage/storagetest"
+)
+
+func TestProvider_Kind(t *testing.T) {
+	p := NewMemoryStorage("reports")
+	if got := p.Kind(); got != "memory" {
+		t.Errorf("Provider.Kind() = %v, want memory", got)
+	}
+	if got := p.CollectionRef(); got != "reports" {
+		t.Errorf("Provider.CollectionRef() = %v, want reports", got)
+	}
+}
+
+func TestProvider_Manager(t *testing.T) {
+	storagetest.TestManager(t, NewMemoryStorage("reports"))
+}
+
+func TestProvider_Streamer(t *testing.T) {
+	p := NewMemoryStorage("reports")
+	storagetest.TestStreamer(t, p)
+
+	if info, err := p.Stat("abc-stream.json"); err != nil || info.Metadata["tool"] != "phpcs" {
+		t.Errorf("Provider.Stat() = %+v, %v, want the metadata of Put", info, err)
+	}
+}
+
+func TestProvider_errors(t *testing.T) {
+	p := NewMemoryStorage("reports")
+
+	if err := p.UploadFile("./testdata/missing.json", "missing.json"); err == nil {
+		t.Errorf("Provider.UploadFile() error = nil, want error for a missing file")
+	}
+	if err := p.DownloadFile("missing.json", "./missing.json"); err != storage.ErrNotExist {
+		t.Errorf("Provider.DownloadFile() error = %v, want %v", err, storage.ErrNotExist)
+	}
+}
+
+func TestProvider_concurrency(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tide-memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "report.json")
+	ioutil.WriteFile(filename, []byte("{}"), 0644)
+
+	p := NewMemoryStorage("reports")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reference := fmt.Sprintf("report-%d.json", i)
+			p.UploadFile(filename, reference)
+			p.Stat(reference)
+			p.List("report-")
+			p.Delete(reference)
+		}(i)
+	}
+	wg.Wait()
+
+	if list, _ := p.List(""); len(list) != 0 {
+		t.Errorf("Provider.List() = %v, want no objects", list)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	p, err := storage.Open(context.Background(), "mem://reports")
+	if err != nil {
+		t.Fatalf("storage.Open() error = %v", err)
+	}
+	if p.Kind() != "memory" || p.CollectionRef() != "reports" {
+		t.Errorf("storage.Open() = %v %v, want memory reports", p.Kind(), p.CollectionRef())
+	}
+
+	if _, err := storage.Open(context.Background(), "mem:"); err == nil {
+		t.Errorf("storage.Open() error = nil, want error for a missing name")
+	}
+}
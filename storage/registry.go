@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Opener returns a new Provider for a storage url.
+type Opener func(ctx context.Context, u *url.URL) (Provider, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Opener)
+)
+
+// Register makes a Provider implementation available to Open for the url scheme.
+// It panics if the opener is nil or if the scheme is already registered.
+func Register(scheme string, opener Opener) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	scheme = strings.ToLower(scheme)
+	if scheme == "" || opener == nil {
+		panic("storage: Register requires a scheme and an opener")
+	}
+	if _, ok := registry[scheme]; ok {
+		panic("storage: Register called twice for scheme " + scheme)
+	}
+
+	registry[scheme] = opener
+}
+
+// Schemes returns the sorted url schemes of the registered providers.
+func Schemes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	var schemes []string
+	for scheme := range registry {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+
+	return schemes
+}
+
+// Open returns a Provider for a storage url, e.g. "s3://bucket/prefix?region=eu-north-1".
+// Providers register their schemes when their package is imported, so import them for side effects:
+//
+//	import _ "github.com/wptide/pkg/storage/s3"
+func Open(ctx context.Context, rawurl string) (Provider, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	registryMu.RLock()
+	opener, ok := registry[strings.ToLower(u.Scheme)]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("storage: no provider for %q, supported schemes: %s",
+			rawurl, strings.Join(Schemes(), ", "))
+	}
+
+	return opener(ctx, u)
+}
+
+// Prefix returns the url path as a key prefix, without a leading slash and with a trailing slash.
+func Prefix(u *url.URL) string {
+	prefix := strings.Trim(u.Path, "/")
+	if prefix == "" {
+		return ""
+	}
+	return prefix + "/"
+}
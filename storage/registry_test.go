@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// urlProvider is a Provider that remembers the url it was opened with.
+type urlProvider struct {
+	Provider
+	url *url.URL
+}
+
+// withOpeners replaces the registry for the duration of a test.
+func withOpeners(openers map[string]Opener) func() {
+	registryMu.Lock()
+	old := registry
+	registry = openers
+	registryMu.Unlock()
+
+	return func() {
+		registryMu.Lock()
+		registry = old
+		registryMu.Unlock()
+	}
+}
+
+func TestOpen(t *testing.T) {
+	defer withOpeners(map[string]Opener{
+		"test": func(ctx context.Context, u *url.URL) (Provider, error) {
+			return urlProvider{url: u}, nil
+		},
+	})()
+
+	tests := []struct {
+		name     string
+		rawurl   string
+		wantHost string
+		wantErr  bool
+	}{
+		{"Registered Scheme", "test://bucket/prefix", "bucket", false},
+		{"Upper Case Scheme", "TEST://bucket", "bucket", false},
+		{"Unknown Scheme", "ftp://bucket", "", true},
+		{"No Scheme", "bucket/prefix", "", true},
+		{"Invalid URL", "test://bucket/%zz", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Open(context.Background(), tt.rawurl)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.(urlProvider).url.Host != tt.wantHost {
+				t.Errorf("Open() url = %v, want host %v", got.(urlProvider).url, tt.wantHost)
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer withOpeners(make(map[string]Opener))()
+
+	opener := func(ctx context.Context, u *url.URL) (Provider, error) { return nil, nil }
+	Register("b", opener)
+	Register("A", opener)
+
+	if got := Schemes(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("Schemes() = %v, want [a b]", got)
+	}
+
+	panics := func(scheme string, opener Opener) (panicked bool) {
+		defer func() { panicked = recover() != nil }()
+		Register(scheme, opener)
+		return false
+	}
+
+	if !panics("a", opener) {
+		t.Errorf("Register() of a duplicate scheme did not panic")
+	}
+	if !panics("c", nil) {
+		t.Errorf("Register() without an opener did not panic")
+	}
+	if !panics("", opener) {
+		t.Errorf("Register() without a scheme did not panic")
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	tests := []struct {
+		rawurl string
+		want   string
+	}{
+		{"s3://bucket", ""},
+		{"s3://bucket/", ""},
+		{"s3://bucket/reports", "reports/"},
+		{"s3://bucket/reports/tide/", "reports/tide/"},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.rawurl)
+		if got := Prefix(u); got != tt.want {
+			t.Errorf("Prefix(%q) = %q, want %q", tt.rawurl, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,371 @@
+// Package replicate is a storage provider writing objects to several providers.
+package replicate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/storage"
+)
+
+// Policy decides when a write to the providers succeeds.
+type Policy int
+
+const (
+	// All requires every provider to store the object.
+	All Policy = iota
+	// FirstSuccess succeeds once a provider stored the object, trying them in order.
+	// The other providers get a copy in the background.
+	FirstSuccess
+)
+
+var (
+	// File system operation variables.
+	fileOpen = os.Open
+	tempFile = ioutil.TempFile
+)
+
+// Provider writes objects to several providers and reads them from the first provider that has them.
+// The first provider is the primary: Kind and CollectionRef report it.
+type Provider struct {
+	providers []storage.Provider
+	policy    Policy
+
+	backfills sync.WaitGroup
+	mu        sync.Mutex
+	errs      []error // Backfill errors since the last Wait.
+}
+
+// NewProvider returns a provider writing to primary and others with the policy.
+func NewProvider(policy Policy, primary storage.Provider, others ...storage.Provider) *Provider {
+	return &Provider{
+		providers: append([]storage.Provider{primary}, others...),
+		policy:    policy,
+	}
+}
+
+// Kind returns the kind of the primary provider.
+func (p *Provider) Kind() string {
+	return p.providers[0].Kind()
+}
+
+// CollectionRef returns the collection of the primary provider.
+func (p *Provider) CollectionRef() string {
+	return p.providers[0].CollectionRef()
+}
+
+// Encoding returns the content encoding of the primary provider.
+func (p *Provider) Encoding() string {
+	return storage.Encoding(p.providers[0])
+}
+
+// UploadFile uploads the file to the providers according to the policy.
+func (p *Provider) UploadFile(filename, reference string) error {
+	return p.store(context.Background(), filename, false, reference, nil)
+}
+
+// DownloadFile downloads the object from the first provider that has it.
+func (p *Provider) DownloadFile(reference, filename string) error {
+	var errs []error
+	for _, provider := range p.providers {
+		err := provider.DownloadFile(reference, filename)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return combine(p.providers, errs)
+}
+
+// Put stores the content of r with the providers according to the policy.
+// The content is kept in a temp file so that every provider can read it.
+func (p *Provider) Put(ctx context.Context, reference string, r io.Reader, metadata map[string]string) error {
+	tmp, err := spool(r)
+	if err != nil {
+		return err
+	}
+	return p.store(ctx, tmp, true, reference, metadata)
+}
+
+// Get returns a reader for the object from the first provider that has it. The reader must be closed.
+func (p *Provider) Get(ctx context.Context, reference string) (io.ReadCloser, error) {
+	var errs []error
+	for _, provider := range p.providers {
+		r, err := storage.Get(ctx, provider, reference)
+		if err == nil {
+			return r, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, combine(p.providers, errs)
+}
+
+// Exists reports whether any provider has the object.
+func (p *Provider) Exists(reference string) (bool, error) {
+	managers, err := p.managers()
+	if err != nil {
+		return false, err
+	}
+
+	for _, m := range managers {
+		ok, err := m.Exists(reference)
+		if ok || err != nil {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// Stat returns the details of the object from the first provider that has it.
+func (p *Provider) Stat(reference string) (storage.ObjectInfo, error) {
+	managers, err := p.managers()
+	if err != nil {
+		return storage.ObjectInfo{}, err
+	}
+
+	for _, m := range managers {
+		info, err := m.Stat(reference)
+		if err != storage.ErrNotExist {
+			return info, err
+		}
+	}
+	return storage.ObjectInfo{}, storage.ErrNotExist
+}
+
+// List returns the objects of all providers with a reference starting with prefix.
+// Objects stored by several providers are listed once, with the details from the first of them.
+func (p *Provider) List(prefix string) ([]storage.ObjectInfo, error) {
+	managers, err := p.managers()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var objects []storage.ObjectInfo
+	for _, m := range managers {
+		list, err := m.List(prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range list {
+			if !seen[info.Reference] {
+				seen[info.Reference] = true
+				objects = append(objects, info)
+			}
+		}
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Reference < objects[j].Reference
+	})
+
+	return objects, nil
+}
+
+// Delete removes the object from every provider.
+func (p *Provider) Delete(reference string) error {
+	managers, err := p.managers()
+	if err != nil {
+		return err
+	}
+
+	errs := make([]error, len(managers))
+	for i, m := range managers {
+		errs[i] = m.Delete(reference)
+	}
+	return combine(p.providers, errs)
+}
+
+// Wait waits for the background copies to finish and returns their errors since the last call.
+func (p *Provider) Wait() error {
+	p.backfills.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	errs := p.errs
+	p.errs = nil
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	var msgs []string
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+// store writes the file to the providers. Owned files are temp files removed when they are not needed anymore.
+func (p *Provider) store(ctx context.Context, filename string, owned bool, reference string, metadata map[string]string) error {
+	if p.policy == FirstSuccess {
+		return p.storeFirst(ctx, filename, owned, reference, metadata)
+	}
+
+	if owned {
+		defer os.Remove(filename)
+	}
+
+	errs := make([]error, len(p.providers))
+
+	var wg sync.WaitGroup
+	for i, provider := range p.providers {
+		wg.Add(1)
+		go func(i int, provider storage.Provider) {
+			defer wg.Done()
+			errs[i] = write(ctx, provider, filename, reference, metadata)
+		}(i, provider)
+	}
+	wg.Wait()
+
+	return combine(p.providers, errs)
+}
+
+// storeFirst writes the file to the first provider that accepts it and copies it to the others in the background.
+func (p *Provider) storeFirst(ctx context.Context, filename string, owned bool, reference string, metadata map[string]string) error {
+	errs := make([]error, len(p.providers))
+	stored := -1
+	for i, provider := range p.providers {
+		if errs[i] = write(ctx, provider, filename, reference, metadata); errs[i] == nil {
+			stored = i
+			break
+		}
+	}
+
+	if stored < 0 {
+		if owned {
+			os.Remove(filename)
+		}
+		return combine(p.providers, errs)
+	}
+
+	var others []storage.Provider
+	for i, provider := range p.providers {
+		if i != stored {
+			others = append(others, provider)
+		}
+	}
+	if len(others) == 0 {
+		if owned {
+			os.Remove(filename)
+		}
+		return nil
+	}
+
+	// The caller may remove its file once we return.
+	if !owned {
+		file, err := fileOpen(filename)
+		if err != nil {
+			return err
+		}
+		filename, err = spool(file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	p.backfills.Add(1)
+	go p.backfill(others, filename, reference, metadata)
+
+	return nil
+}
+
+// backfill copies a stored object to the other providers and removes the temp file.
+func (p *Provider) backfill(providers []storage.Provider, filename, reference string, metadata map[string]string) {
+	defer p.backfills.Done()
+	defer os.Remove(filename)
+
+	for _, provider := range providers {
+		// The request may be over, so don't use its context.
+		if err := write(context.Background(), provider, filename, reference, metadata); err != nil {
+			err = fmt.Errorf("replicate: could not copy %s to %s: %s", reference, provider.Kind(), err)
+			log.Log("Storage", err.Error())
+
+			p.mu.Lock()
+			p.errs = append(p.errs, err)
+			p.mu.Unlock()
+		}
+	}
+}
+
+// managers returns the providers as storage.Managers.
+func (p *Provider) managers() ([]storage.Manager, error) {
+	var managers []storage.Manager
+	for _, provider := range p.providers {
+		m, ok := provider.(storage.Manager)
+		if !ok {
+			return nil, fmt.Errorf("replicate: %s provider does not support managing objects", provider.Kind())
+		}
+		managers = append(managers, m)
+	}
+	return managers, nil
+}
+
+// write stores a file with a provider, streaming it if the provider supports it.
+func write(ctx context.Context, provider storage.Provider, filename, reference string, metadata map[string]string) error {
+	streamer, ok := provider.(storage.Streamer)
+	if !ok {
+		return provider.UploadFile(filename, reference)
+	}
+
+	file, err := fileOpen(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return streamer.Put(ctx, reference, file, metadata)
+}
+
+// spool copies r to a new temp file and returns its name.
+func spool(r io.Reader) (string, error) {
+	tmp, err := tempFile("", "tide-replicate")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return tmp.Name(), nil
+}
+
+// combine returns an error listing the errors of the providers, or nil if there are none.
+// If every provider is missing the object the result is storage.ErrNotExist.
+func combine(providers []storage.Provider, errs []error) error {
+	var msgs []string
+	notExist := 0
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if err == storage.ErrNotExist {
+			notExist++
+		}
+		msgs = append(msgs, providers[i].Kind()+": "+err.Error())
+	}
+
+	if len(msgs) == 0 {
+		return nil
+	}
+	if notExist == len(providers) {
+		return storage.ErrNotExist
+	}
+	return errors.New("replicate: " + strings.Join(msgs, "; "))
+}
@@ -0,0 +1,217 @@
+package replicate
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/storage/memory"
+	"github.com/wptide/pkg/storage/storagetest"
+)
+
+// failingStorage fails to store anything.
+type failingStorage struct {
+	*memory.Provider
+}
+
+func (f failingStorage) Kind() string { return "failing" }
+
+func (f failingStorage) UploadFile(filename, reference string) error {
+	return errors.New("something went wrong")
+}
+
+func (f failingStorage) Put(ctx context.Context, reference string, r io.Reader, metadata map[string]string) error {
+	return errors.New("something went wrong")
+}
+
+// fileStorage hides the streaming methods of a memory provider.
+type fileStorage struct {
+	provider *memory.Provider
+}
+
+func (f fileStorage) Kind() string          { return "file" }
+func (f fileStorage) CollectionRef() string { return f.provider.CollectionRef() }
+func (f fileStorage) UploadFile(filename, reference string) error {
+	return f.provider.UploadFile(filename, reference)
+}
+func (f fileStorage) DownloadFile(reference, filename string) error {
+	return f.provider.DownloadFile(reference, filename)
+}
+
+func init() {
+	log.SetOutput(ioutil.Discard)
+}
+
+func has(p *memory.Provider, reference string) bool {
+	ok, _ := p.Exists(reference)
+	return ok
+}
+
+func TestProvider_primary(t *testing.T) {
+	p := NewProvider(All, memory.NewMemoryStorage("primary"), memory.NewMemoryStorage("copy"))
+	if p.Kind() != "memory" || p.CollectionRef() != "primary" {
+		t.Errorf("Provider = %v %v, want the primary provider", p.Kind(), p.CollectionRef())
+	}
+}
+
+func TestProvider_conformance(t *testing.T) {
+	storagetest.TestManager(t, NewProvider(All, memory.NewMemoryStorage("primary"), memory.NewMemoryStorage("copy")))
+	storagetest.TestStreamer(t, NewProvider(All, memory.NewMemoryStorage("primary"), memory.NewMemoryStorage("copy")))
+}
+
+func TestProvider_All(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tide-replicate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "report.json")
+	ioutil.WriteFile(filename, []byte("{}"), 0644)
+
+	primary, other := memory.NewMemoryStorage("primary"), memory.NewMemoryStorage("copy")
+
+	t.Run("Every Provider", func(t *testing.T) {
+		p := NewProvider(All, primary, fileStorage{other})
+		if err := p.UploadFile(filename, "upload.json"); err != nil {
+			t.Fatalf("Provider.UploadFile() error = %v", err)
+		}
+		if err := p.Put(context.Background(), "put.json", strings.NewReader("{}"), nil); err != nil {
+			t.Fatalf("Provider.Put() error = %v", err)
+		}
+
+		for _, reference := range []string{"upload.json", "put.json"} {
+			if !has(primary, reference) || !has(other, reference) {
+				t.Errorf("Provider did not store %s with every provider", reference)
+			}
+		}
+	})
+
+	t.Run("Failing Provider", func(t *testing.T) {
+		p := NewProvider(All, primary, failingStorage{other})
+		err := p.UploadFile(filename, "failed.json")
+		if err == nil || !strings.Contains(err.Error(), "failing: something went wrong") {
+			t.Errorf("Provider.UploadFile() error = %v, want the failing provider error", err)
+		}
+	})
+}
+
+func TestProvider_FirstSuccess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tide-replicate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "report.json")
+
+	t.Run("Backfill", func(t *testing.T) {
+		ioutil.WriteFile(filename, []byte("{}"), 0644)
+		primary, other := memory.NewMemoryStorage("primary"), memory.NewMemoryStorage("copy")
+
+		p := NewProvider(FirstSuccess, primary, fileStorage{other})
+		if err := p.UploadFile(filename, "upload.json"); err != nil {
+			t.Fatalf("Provider.UploadFile() error = %v", err)
+		}
+		// The backfill must not depend on the caller's file.
+		os.Remove(filename)
+
+		if err := p.Put(context.Background(), "put.json", strings.NewReader("{}"), nil); err != nil {
+			t.Fatalf("Provider.Put() error = %v", err)
+		}
+
+		if err := p.Wait(); err != nil {
+			t.Fatalf("Provider.Wait() error = %v", err)
+		}
+		for _, reference := range []string{"upload.json", "put.json"} {
+			if !has(primary, reference) || !has(other, reference) {
+				t.Errorf("Provider did not copy %s to every provider", reference)
+			}
+		}
+	})
+
+	t.Run("Failing Primary", func(t *testing.T) {
+		other := memory.NewMemoryStorage("copy")
+
+		p := NewProvider(FirstSuccess, failingStorage{memory.NewMemoryStorage("primary")}, other)
+		if err := p.Put(context.Background(), "put.json", strings.NewReader("{}"), nil); err != nil {
+			t.Fatalf("Provider.Put() error = %v", err)
+		}
+		if !has(other, "put.json") {
+			t.Errorf("Provider.Put() did not store the object with the second provider")
+		}
+		if err := p.Wait(); err == nil || !strings.Contains(err.Error(), "could not copy put.json to failing") {
+			t.Errorf("Provider.Wait() error = %v, want the backfill error", err)
+		}
+		if err := p.Wait(); err != nil {
+			t.Errorf("Provider.Wait() error = %v, want nil once reported", err)
+		}
+	})
+
+	t.Run("Every Provider Failing", func(t *testing.T) {
+		p := NewProvider(FirstSuccess, failingStorage{memory.NewMemoryStorage("primary")})
+		if err := p.Put(context.Background(), "put.json", strings.NewReader("{}"), nil); err == nil {
+			t.Errorf("Provider.Put() error = nil, want error")
+		}
+	})
+}
+
+func TestProvider_read(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tide-replicate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	primary, other := memory.NewMemoryStorage("primary"), memory.NewMemoryStorage("copy")
+	other.Put(context.Background(), "copy.json", strings.NewReader(`{"copy":true}`), nil)
+
+	for _, p := range []*Provider{
+		NewProvider(All, primary, other),
+		NewProvider(All, primary, fileStorage{other}),
+	} {
+		r, err := p.Get(context.Background(), "copy.json")
+		if err != nil {
+			t.Fatalf("Provider.Get() error = %v", err)
+		}
+		got, _ := ioutil.ReadAll(r)
+		r.Close()
+		if string(got) != `{"copy":true}` {
+			t.Errorf("Provider.Get() = %s, want the copy", got)
+		}
+
+		filename := filepath.Join(dir, "copy.json")
+		if err := p.DownloadFile("copy.json", filename); err != nil {
+			t.Errorf("Provider.DownloadFile() error = %v", err)
+		}
+
+		if _, err := p.Get(context.Background(), "missing.json"); err != storage.ErrNotExist {
+			t.Errorf("Provider.Get() error = %v, want %v", err, storage.ErrNotExist)
+		}
+	}
+
+	p := NewProvider(All, primary, other)
+	if ok, _ := p.Exists("copy.json"); !ok {
+		t.Errorf("Provider.Exists() = false, want true for an object of the second provider")
+	}
+	if info, err := p.Stat("copy.json"); err != nil || info.Reference != "copy.json" {
+		t.Errorf("Provider.Stat() = %v, %v, want the object of the second provider", info, err)
+	}
+
+	primary.Put(context.Background(), "copy.json", strings.NewReader("{}"), nil)
+	primary.Put(context.Background(), "primary.json", strings.NewReader("{}"), nil)
+	if list, err := p.List(""); err != nil || len(list) != 2 || list[0].Size != 2 {
+		t.Errorf("Provider.List() = %v, %v, want each object once from the primary", list, err)
+	}
+
+	if _, err := NewProvider(All, primary, fileStorage{other}).List(""); err == nil {
+		t.Errorf("Provider.List() error = nil, want error for a provider that can't list")
+	}
+}
@@ -1,27 +1,51 @@
 package s3
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/storage"
 )
 
+// Region used with custom endpoints when none is configured.
+const defaultRegion = "us-east-1"
+
 var (
 	fileCreate = os.Create
 	fileOpen   = os.Open
 )
 
+func init() {
+	storage.Register("s3", Open)
+}
+
 // Provider describes a new S3 storage provider.
 type Provider struct {
 	session    *session.Session
 	uploader   s3manageriface.UploaderAPI
 	downloader s3manageriface.DownloaderAPI
+	client     s3iface.S3API
 	bucket     string
+	prefix     string // Prepended to every reference, e.g. "reports/".
 }
 
 // Kind returns the provider kind.
@@ -29,8 +53,11 @@ func (s3p Provider) Kind() string {
 	return "s3"
 }
 
-// CollectionRef gets the bucket reference.
+// CollectionRef gets the bucket reference, including the key prefix if there is one.
 func (s3p Provider) CollectionRef() string {
+	if s3p.prefix != "" {
+		return s3p.bucket + "/" + strings.TrimSuffix(s3p.prefix, "/")
+	}
 	return s3p.bucket
 }
 
@@ -48,9 +75,10 @@ func (s3p Provider) UploadFile(filename, reference string) error {
 
 	// Use the upload manager to write to S3.
 	_, err = s3p.uploader.Upload(&s3manager.UploadInput{
-		Bucket: aws.String(s3p.bucket),
-		Key:    aws.String(reference),
-		Body:   file,
+		Bucket:      aws.String(s3p.bucket),
+		Key:         aws.String(s3p.key(reference)),
+		Body:        file,
+		ContentType: aws.String(storage.TypeByExtension(reference)),
 	})
 
 	// Error if file cannot be uploaded.
@@ -78,7 +106,7 @@ func (s3p Provider) DownloadFile(reference, filename string) error {
 	_, err = s3p.downloader.Download(file,
 		&s3.GetObjectInput{
 			Bucket: aws.String(s3p.bucket),
-			Key:    aws.String(reference),
+			Key:    aws.String(s3p.key(reference)),
 		})
 
 	// Error on failed download.
@@ -89,10 +117,184 @@ func (s3p Provider) DownloadFile(reference, filename string) error {
 	return nil
 }
 
+// Put uploads the content of r with metadata as user-defined object metadata.
+// The storage.ContentEncoding and storage.ContentType keys set the headers of the object instead.
+func (s3p Provider) Put(ctx context.Context, reference string, r io.Reader, metadata map[string]string) error {
+	input := &s3manager.UploadInput{
+		Bucket:      aws.String(s3p.bucket),
+		Key:         aws.String(s3p.key(reference)),
+		Body:        r,
+		ContentType: aws.String(storage.TypeByExtension(reference)),
+	}
+	for key, value := range metadata {
+		switch key {
+		case storage.ContentEncoding:
+			input.ContentEncoding = aws.String(value)
+		case storage.ContentType:
+			input.ContentType = aws.String(value)
+		default:
+			if input.Metadata == nil {
+				input.Metadata = make(map[string]*string)
+			}
+			input.Metadata[key] = aws.String(value)
+		}
+	}
+
+	_, err := s3p.uploader.UploadWithContext(ctx, input)
+	return err
+}
+
+// Get returns a reader for the object. The reader must be closed.
+func (s3p Provider) Get(ctx context.Context, reference string) (io.ReadCloser, error) {
+	out, err := s3p.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s3p.bucket),
+		Key:    aws.String(s3p.key(reference)),
+	})
+
+	if err != nil {
+		if isNotFound(err) {
+			return nil, storage.ErrNotExist
+		}
+		return nil, err
+	}
+
+	return out.Body, nil
+}
+
+// SignedURL returns a link to download the object without credentials until the ttl expires.
+func (s3p Provider) SignedURL(reference string, ttl time.Duration) (string, error) {
+	req, _ := s3p.client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(s3p.bucket),
+		Key:    aws.String(s3p.key(reference)),
+	})
+	return req.Presign(ttl)
+}
+
+// Exists reports whether there is an object for the reference.
+func (s3p Provider) Exists(reference string) (bool, error) {
+	_, err := s3p.Stat(reference)
+	if err == storage.ErrNotExist {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// List returns the objects with a key starting with prefix.
+// S3 does not list metadata, Stat returns it.
+func (s3p Provider) List(prefix string) ([]storage.ObjectInfo, error) {
+	var objects []storage.ObjectInfo
+
+	err := s3p.client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s3p.bucket),
+		Prefix: aws.String(s3p.key(prefix)),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			objects = append(objects, storage.ObjectInfo{
+				Reference: strings.TrimPrefix(aws.StringValue(obj.Key), s3p.prefix),
+				Size:      aws.Int64Value(obj.Size),
+				Modified:  aws.TimeValue(obj.LastModified),
+			})
+		}
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// S3 lists keys in UTF-8 binary order already, but don't rely on compatible services doing the same.
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Reference < objects[j].Reference
+	})
+
+	return objects, nil
+}
+
+// Delete removes the object for the reference.
+func (s3p Provider) Delete(reference string) error {
+	_, err := s3p.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s3p.bucket),
+		Key:    aws.String(s3p.key(reference)),
+	})
+	return err
+}
+
+// Stat returns the details of the object for the reference.
+func (s3p Provider) Stat(reference string) (storage.ObjectInfo, error) {
+	head, err := s3p.client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s3p.bucket),
+		Key:    aws.String(s3p.key(reference)),
+	})
+
+	if err != nil {
+		if isNotFound(err) {
+			return storage.ObjectInfo{}, storage.ErrNotExist
+		}
+		return storage.ObjectInfo{}, err
+	}
+
+	info := storage.ObjectInfo{
+		Reference:   reference,
+		Size:        aws.Int64Value(head.ContentLength),
+		Modified:    aws.TimeValue(head.LastModified),
+		ContentType: aws.StringValue(head.ContentType),
+	}
+
+	// S3 returns the keys of user-defined metadata in header case, e.g. "Slug".
+	for key, value := range head.Metadata {
+		if info.Metadata == nil {
+			info.Metadata = make(map[string]string)
+		}
+		info.Metadata[strings.ToLower(key)] = aws.StringValue(value)
+	}
+
+	return info, nil
+}
+
+// Config describes how to connect to S3 or to an S3-compatible service such as MinIO or Ceph.
+type Config struct {
+	Region             string
+	Key                string // Static credentials. Without them the default credential chain is used.
+	Secret             string
+	Endpoint           string // Custom endpoint, e.g. "http://localhost:9000".
+	PathStyle          bool   // Address buckets as endpoint/bucket instead of bucket.endpoint.
+	InsecureSkipVerify bool   // Skip TLS certificate verification. Only for development.
+}
+
 // NewS3Provider is a convenience method to return a new *Provider instance.
+// Empty key and secret use the default credential chain.
+// If the session can't be created the error is logged, and the requests of the provider fail.
 func NewS3Provider(region, key, secret, bucket string) *Provider {
+	cfg := Config{
+		Region: region,
+		Key:    key,
+		Secret: secret,
+	}
 
-	sess, _ := getSession(region, key, secret)
+	p, err := NewS3ProviderConfig(cfg, bucket)
+	if err != nil {
+		log.Log("s3", "could not create session: "+err.Error())
+
+		// session.New never fails, the requests made with its session return the error instead.
+		p = newProvider(session.New(awsConfig(cfg)), bucket)
+	}
+
+	return p
+}
+
+// NewS3ProviderConfig returns a new *Provider for the bucket using the config.
+func NewS3ProviderConfig(cfg Config, bucket string) (*Provider, error) {
+
+	sess, err := newSession(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return newProvider(sess, bucket), nil
+}
+
+// newProvider returns a new *Provider for the bucket using the session.
+func newProvider(sess *session.Session, bucket string) *Provider {
 	uploader := s3manager.NewUploader(sess)
 	downloader := s3manager.NewDownloader(sess)
 
@@ -100,16 +302,110 @@ func NewS3Provider(region, key, secret, bucket string) *Provider {
 		session:    sess,
 		uploader:   uploader,
 		downloader: downloader,
+		client:     s3.New(sess),
 		bucket:     bucket,
 	}
 }
 
-// getSession establishes a new SQS session.
-func getSession(region, key, secret string) (*session.Session, error) {
-	sess, err := session.NewSession(&aws.Config{
-		Region:      aws.String(region),
-		Credentials: credentials.NewStaticCredentials(key, secret, ""),
+// Open returns a provider for a url like "s3://bucket/prefix?region=eu-north-1".
+// These query values are supported:
+//   - region: falls back to AWS_REGION or the shared config,
+//   - endpoint: an S3-compatible service, e.g. "http://localhost:9000",
+//   - path_style: "true" to address buckets as endpoint/bucket,
+//   - insecure: "true" to skip TLS certificate verification.
+//
+// Credentials come from the default chain: the environment, the shared config or an IAM role.
+func Open(ctx context.Context, u *url.URL) (storage.Provider, error) {
+	if u.Host == "" {
+		return nil, errors.New("s3: no bucket in url")
+	}
+
+	query := u.Query()
+	cfg := Config{
+		Region:   query.Get("region"),
+		Endpoint: query.Get("endpoint"),
+	}
+
+	var err error
+	if cfg.PathStyle, err = parseBool(query, "path_style"); err != nil {
+		return nil, err
+	}
+	if cfg.InsecureSkipVerify, err = parseBool(query, "insecure"); err != nil {
+		return nil, err
+	}
+
+	p, err := NewS3ProviderConfig(cfg, u.Host)
+	if err != nil {
+		return nil, err
+	}
+	if aws.StringValue(p.session.Config.Region) == "" {
+		return nil, errors.New("s3: no region in url, AWS_REGION or shared config")
+	}
+	p.prefix = storage.Prefix(u)
+
+	return p, nil
+}
+
+func parseBool(query url.Values, key string) (bool, error) {
+	value := query.Get(key)
+	if value == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("s3: invalid %s %q", key, value)
+	}
+	return b, nil
+}
+
+// key returns the object key for a reference.
+func (s3p Provider) key(reference string) string {
+	return s3p.prefix + reference
+}
+
+// isNotFound reports whether an S3 error means that the object does not exist.
+// HeadObject has no body to carry an error code, so it only reports "NotFound".
+func isNotFound(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && (awsErr.Code() == "NotFound" || awsErr.Code() == s3.ErrCodeNoSuchKey)
+}
+
+// newSession establishes a new S3 session.
+func newSession(cfg Config) (*session.Session, error) {
+	return session.NewSessionWithOptions(session.Options{
+		Config:            *awsConfig(cfg),
+		SharedConfigState: session.SharedConfigEnable,
 	})
+}
+
+// awsConfig returns the AWS config of the session for the config.
+func awsConfig(cfg Config) *aws.Config {
+	config := aws.NewConfig().WithS3ForcePathStyle(cfg.PathStyle)
+
+	if cfg.Region != "" {
+		config.WithRegion(cfg.Region)
+	} else if cfg.Endpoint != "" {
+		// S3-compatible services usually ignore the region, but requests still need one to be signed.
+		config.WithRegion(defaultRegion)
+	}
+
+	if cfg.Key != "" || cfg.Secret != "" {
+		config.WithCredentials(credentials.NewStaticCredentials(cfg.Key, cfg.Secret, ""))
+	}
+
+	if cfg.Endpoint != "" {
+		config.WithEndpoint(cfg.Endpoint)
+	}
+
+	if cfg.InsecureSkipVerify {
+		config.WithHTTPClient(&http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			},
+		})
+	}
 
-	return sess, err
+	return config
 }
@@ -1,16 +1,30 @@
 package s3
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/storage/storagetest"
 )
 
 type mockS3 struct {
@@ -226,6 +240,28 @@ func TestNewS3Provider(t *testing.T) {
 	}
 }
 
+func TestNewS3Provider_sessionError(t *testing.T) {
+	// A missing CA bundle fails the session.
+	defer setenv(map[string]string{
+		"AWS_CA_BUNDLE": "./testdata/missing",
+	})()
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stdout)
+
+	p := NewS3Provider("us-west-2", "random-key", "so-secret", "the-bucket")
+	if p == nil {
+		t.Fatal("NewS3Provider() = nil, want a provider")
+	}
+	if !strings.Contains(buf.String(), "could not create session") {
+		t.Errorf("NewS3Provider() log = %q, want the session error", buf.String())
+	}
+	if err := p.DownloadFile("missing.json", "/dev/null"); err == nil {
+		t.Error("Provider.DownloadFile() error = nil, want the session error")
+	}
+}
+
 func TestS3Provider_CollectionRef(t *testing.T) {
 	type fields struct {
 		bucket string
@@ -263,3 +299,342 @@ func TestS3Provider_Kind(t *testing.T) {
 		}
 	})
 }
+
+// bucketS3 is a mock S3 bucket keeping objects in memory.
+type bucketS3 struct {
+	s3iface.S3API
+	s3manageriface.UploaderAPI
+	s3manageriface.DownloaderAPI
+	objects  map[string][]byte
+	metadata map[string]map[string]*string
+	types    map[string]*string
+	last     *s3manager.UploadInput
+}
+
+func (b *bucketS3) Upload(input *s3manager.UploadInput, _ ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
+	b.last = input
+	data, err := ioutil.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	b.objects[*input.Key] = data
+
+	// S3 returns metadata keys in header case.
+	if b.metadata == nil {
+		b.metadata = make(map[string]map[string]*string)
+		b.types = make(map[string]*string)
+	}
+	b.types[*input.Key] = input.ContentType
+	b.metadata[*input.Key] = make(map[string]*string)
+	for key, value := range input.Metadata {
+		b.metadata[*input.Key][http.CanonicalHeaderKey(key)] = value
+	}
+
+	return &s3manager.UploadOutput{}, nil
+}
+
+func (b *bucketS3) UploadWithContext(_ aws.Context, input *s3manager.UploadInput, options ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
+	return b.Upload(input, options...)
+}
+
+func (b *bucketS3) GetObjectWithContext(_ aws.Context, input *s3.GetObjectInput, _ ...request.Option) (*s3.GetObjectOutput, error) {
+	data, ok := b.objects[*input.Key]
+	if !ok {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, "not found", nil)
+	}
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func (b *bucketS3) Download(w io.WriterAt, input *s3.GetObjectInput, _ ...func(*s3manager.Downloader)) (int64, error) {
+	data, ok := b.objects[*input.Key]
+	if !ok {
+		return 0, awserr.New(s3.ErrCodeNoSuchKey, "not found", nil)
+	}
+	n, err := w.WriteAt(data, 0)
+	return int64(n), err
+}
+
+func (b *bucketS3) HeadObject(input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	data, ok := b.objects[*input.Key]
+	if !ok {
+		return nil, awserr.New("NotFound", "Not Found", nil)
+	}
+	return &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(int64(len(data))),
+		LastModified:  aws.Time(time.Now()),
+		Metadata:      b.metadata[*input.Key],
+		ContentType:   b.types[*input.Key],
+	}, nil
+}
+
+func (b *bucketS3) ListObjectsV2Pages(input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool) error {
+	if *input.Bucket == "error_bucket" {
+		return errors.New("something went wrong")
+	}
+
+	// One object per page to exercise paging.
+	for key, data := range b.objects {
+		if !strings.HasPrefix(key, *input.Prefix) {
+			continue
+		}
+		page := &s3.ListObjectsV2Output{
+			Contents: []*s3.Object{{
+				Key:          aws.String(key),
+				Size:         aws.Int64(int64(len(data))),
+				LastModified: aws.Time(time.Now()),
+			}},
+		}
+		if !fn(page, false) {
+			break
+		}
+	}
+	return nil
+}
+
+func (b *bucketS3) DeleteObject(input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	delete(b.objects, *input.Key)
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func TestS3Provider_Manager(t *testing.T) {
+	bucket := &bucketS3{objects: make(map[string][]byte)}
+
+	storagetest.TestManager(t, Provider{
+		uploader:   bucket,
+		downloader: bucket,
+		client:     bucket,
+		bucket:     "the-bucket",
+	})
+
+	t.Run("Stat Metadata", func(t *testing.T) {
+		p := Provider{uploader: bucket, client: bucket, bucket: "the-bucket"}
+		metadata := map[string]string{storage.MetaSlug: "twentyseventeen", storage.MetaChecksum: "abc"}
+
+		if err := p.Put(context.Background(), "abc-meta.json", strings.NewReader("{}"), metadata); err != nil {
+			t.Fatalf("Provider.Put() error = %v", err)
+		}
+		info, err := p.Stat("abc-meta.json")
+		if err != nil || !reflect.DeepEqual(info.Metadata, metadata) {
+			t.Errorf("Provider.Stat() Metadata = %v, %v, want %v", info.Metadata, err, metadata)
+		}
+		if info.ContentType != "application/json" {
+			t.Errorf("Provider.Stat() ContentType = %q, want application/json", info.ContentType)
+		}
+
+		p.Put(context.Background(), "abc-meta.json", strings.NewReader("{}"), map[string]string{storage.ContentType: "text/plain"})
+		if info, _ := p.Stat("abc-meta.json"); info.ContentType != "text/plain" || info.Metadata != nil {
+			t.Errorf("Provider.Stat() = %+v, want the Content-Type header without metadata", info)
+		}
+	})
+
+	t.Run("List Error", func(t *testing.T) {
+		p := Provider{client: bucket, bucket: "error_bucket"}
+		if _, err := p.List(""); err == nil {
+			t.Errorf("Provider.List() error = nil, want error")
+		}
+	})
+}
+
+func TestS3Provider_Streamer(t *testing.T) {
+	bucket := &bucketS3{objects: make(map[string][]byte)}
+	p := Provider{
+		uploader:   bucket,
+		downloader: bucket,
+		client:     bucket,
+		bucket:     "the-bucket",
+	}
+
+	storagetest.TestStreamer(t, p)
+
+	t.Run("Metadata", func(t *testing.T) {
+		p.Put(context.Background(), "report.json", strings.NewReader("{}"), map[string]string{
+			"tool":                  "phpcs",
+			storage.ContentEncoding: "gzip",
+		})
+
+		if got := aws.StringValue(bucket.last.ContentEncoding); got != "gzip" {
+			t.Errorf("Provider.Put() ContentEncoding = %v, want gzip", got)
+		}
+		if got := aws.StringValueMap(bucket.last.Metadata); !reflect.DeepEqual(got, map[string]string{"tool": "phpcs"}) {
+			t.Errorf("Provider.Put() Metadata = %v, want only the tool", got)
+		}
+	})
+}
+
+func TestS3Provider_prefix(t *testing.T) {
+	bucket := &bucketS3{objects: make(map[string][]byte)}
+
+	p := Provider{
+		uploader:   bucket,
+		downloader: bucket,
+		client:     bucket,
+		bucket:     "the-bucket",
+		prefix:     "reports/",
+	}
+	bucket.objects["other.json"] = []byte("{}")
+
+	storagetest.TestManager(t, p)
+
+	if _, ok := bucket.objects["reports/abc-phpcs.json"]; !ok {
+		t.Errorf("Provider.UploadFile() did not prefix the key, objects: %v", bucket.objects)
+	}
+	if got := p.CollectionRef(); got != "the-bucket/reports" {
+		t.Errorf("Provider.CollectionRef() = %v, want the-bucket/reports", got)
+	}
+}
+
+// setenv sets environment variables for the duration of a test.
+func setenv(env map[string]string) func() {
+	old := make(map[string]string)
+	for key, value := range env {
+		old[key] = os.Getenv(key)
+		os.Setenv(key, value)
+	}
+
+	return func() {
+		for key, value := range old {
+			os.Setenv(key, value)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	// Keep the shared config of the machine running the tests out of the way.
+	defer setenv(map[string]string{
+		"AWS_ACCESS_KEY_ID":           "key",
+		"AWS_SECRET_ACCESS_KEY":       "secret",
+		"AWS_CONFIG_FILE":             "./testdata/missing",
+		"AWS_SHARED_CREDENTIALS_FILE": "./testdata/missing",
+		"AWS_DEFAULT_REGION":          "",
+		"AWS_PROFILE":                 "",
+	})()
+
+	tests := []struct {
+		name         string
+		rawurl       string
+		region       string
+		wantBucket   string
+		wantPrefix   string
+		wantRegion   string
+		wantEndpoint string
+		wantPath     bool
+		wantInsecure bool
+		wantErr      bool
+	}{
+		{"Region In URL", "s3://bucket/reports?region=eu-north-1", "", "bucket", "reports/", "eu-north-1", "", false, false, false},
+		{"Region From Env", "s3://bucket", "us-west-2", "bucket", "", "us-west-2", "", false, false, false},
+		{
+			"Custom Endpoint",
+			"s3://bucket/reports?endpoint=http://localhost:9000&path_style=true&insecure=1",
+			"",
+			"bucket",
+			"reports/",
+			"us-east-1",
+			"http://localhost:9000",
+			true,
+			true,
+			false,
+		},
+		{"No Region", "s3://bucket", "", "", "", "", "", false, false, true},
+		{"No Bucket", "s3:///reports?region=eu-north-1", "", "", "", "", "", false, false, true},
+		{"Invalid Path Style", "s3://bucket?region=eu-north-1&path_style=maybe", "", "", "", "", "", false, false, true},
+		{"Invalid Insecure", "s3://bucket?region=eu-north-1&insecure=maybe", "", "", "", "", "", false, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer setenv(map[string]string{"AWS_REGION": tt.region})()
+
+			got, err := storage.Open(context.Background(), tt.rawurl)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("storage.Open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			p := got.(*Provider)
+			config := p.session.Config
+			if p.bucket != tt.wantBucket || p.prefix != tt.wantPrefix || aws.StringValue(config.Region) != tt.wantRegion {
+				t.Errorf("storage.Open() = %v %v %v, want %v %v %v", p.bucket, p.prefix,
+					aws.StringValue(config.Region), tt.wantBucket, tt.wantPrefix, tt.wantRegion)
+			}
+			if aws.StringValue(config.Endpoint) != tt.wantEndpoint || aws.BoolValue(config.S3ForcePathStyle) != tt.wantPath {
+				t.Errorf("storage.Open() endpoint = %v %v, want %v %v", aws.StringValue(config.Endpoint),
+					aws.BoolValue(config.S3ForcePathStyle), tt.wantEndpoint, tt.wantPath)
+			}
+
+			insecure := false
+			if transport, ok := config.HTTPClient.Transport.(*http.Transport); ok && transport.TLSClientConfig != nil {
+				insecure = transport.TLSClientConfig.InsecureSkipVerify
+			}
+			if insecure != tt.wantInsecure {
+				t.Errorf("storage.Open() insecure = %v, want %v", insecure, tt.wantInsecure)
+			}
+
+			// Credentials come from the environment.
+			if creds, err := config.Credentials.Get(); err != nil || creds.AccessKeyID != "key" {
+				t.Errorf("storage.Open() credentials = %v, %v, want the environment key", creds.AccessKeyID, err)
+			}
+		})
+	}
+}
+
+func TestS3Provider_endpoint(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		switch r.Method {
+		case http.MethodGet:
+			w.Write([]byte("{}"))
+		}
+	}))
+	defer server.Close()
+
+	p, err := NewS3ProviderConfig(Config{
+		Key:       "key",
+		Secret:    "secret",
+		Endpoint:  server.URL,
+		PathStyle: true,
+	}, "bucket")
+	if err != nil {
+		t.Fatalf("NewS3ProviderConfig() error = %v", err)
+	}
+
+	if err := p.Put(context.Background(), "report.json", strings.NewReader("{}"), nil); err != nil {
+		t.Fatalf("Provider.Put() error = %v", err)
+	}
+
+	r, err := p.Get(context.Background(), "report.json")
+	if err != nil {
+		t.Fatalf("Provider.Get() error = %v", err)
+	}
+	r.Close()
+
+	want := []string{"PUT /bucket/report.json", "GET /bucket/report.json"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("requests = %v, want %v", paths, want)
+	}
+}
+
+func TestS3Provider_SignedURL(t *testing.T) {
+	p, err := NewS3ProviderConfig(Config{
+		Region: "eu-north-1",
+		Key:    "key",
+		Secret: "secret",
+	}, "bucket")
+	if err != nil {
+		t.Fatalf("NewS3ProviderConfig() error = %v", err)
+	}
+	p.prefix = "reports/"
+
+	got, err := p.SignedURL("abc-phpcs.json", time.Hour)
+	if err != nil {
+		t.Fatalf("Provider.SignedURL() error = %v", err)
+	}
+
+	u, _ := url.Parse(got)
+	query := u.Query()
+	if !strings.HasSuffix(u.Path, "/reports/abc-phpcs.json") || query.Get("X-Amz-Expires") != "3600" || query.Get("X-Amz-Signature") == "" {
+		t.Errorf("Provider.SignedURL() = %v, want a presigned link to the object", got)
+	}
+}
@@ -1,5 +1,15 @@
 package storage
 
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
 // Provider interface describes the methods required to upload or download files from a storage provider.
 type Provider interface {
 	Kind() string
@@ -7,3 +17,77 @@ type Provider interface {
 	UploadFile(filename, reference string) error
 	DownloadFile(reference, filename string) error
 }
+
+// Manager is an optional interface for providers that can look up and remove stored objects.
+type Manager interface {
+	Provider
+	Exists(reference string) (bool, error)
+	List(prefix string) ([]ObjectInfo, error) // Objects with a reference starting with prefix, sorted by reference.
+	Delete(reference string) error            // Deleting an object that does not exist is not an error.
+	Stat(reference string) (ObjectInfo, error)
+}
+
+// Streamer is an optional interface for providers that can store and read objects without temporary files.
+type Streamer interface {
+	Provider
+	Put(ctx context.Context, reference string, r io.Reader, metadata map[string]string) error
+	Get(ctx context.Context, reference string) (io.ReadCloser, error) // Returns ErrNotExist if there is no object.
+}
+
+// Signer is an optional interface for providers that can create expiring download links for objects.
+type Signer interface {
+	Provider
+	SignedURL(reference string, ttl time.Duration) (string, error)
+}
+
+// Encoder is an optional interface for providers that encode objects before storing them, e.g. with compression.
+type Encoder interface {
+	Encoding() string // Content encoding of the stored objects, e.g. "gzip".
+}
+
+// Metadata keys that providers store as properties of the object instead of as custom metadata.
+const (
+	ContentEncoding = "Content-Encoding" // Content encoding of the object, e.g. "gzip".
+	ContentType     = "Content-Type"     // Media type of the object. Defaults to the type of the reference extension.
+)
+
+// TypeByExtension returns the media type for the extension of a reference, or "application/octet-stream" if it is unknown.
+func TypeByExtension(reference string) string {
+	ext := strings.ToLower(path.Ext(reference))
+	// Not every version of the mime package knows JSON, the format of all reports.
+	if ext == ".json" {
+		return "application/json"
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// Encoding returns the content encoding of the objects stored by the provider, or "" if they are stored as is.
+func Encoding(p Provider) string {
+	if e, ok := p.(Encoder); ok {
+		return e.Encoding()
+	}
+	return ""
+}
+
+// Metadata keys describing the audit an object belongs to.
+const (
+	MetaSlug     = "slug"     // Slug of the audited project.
+	MetaChecksum = "checksum" // Checksum of the audited code, shared by all objects of an audit.
+	MetaAudit    = "audit"    // Kind of audit, e.g. "phpcs_wordpress" or "lighthouse".
+	MetaStandard = "standard" // Coding standard of a phpcs audit.
+)
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Reference   string
+	Size        int64
+	Modified    time.Time
+	ContentType string            // Media type of the object, if the provider keeps it.
+	Metadata    map[string]string // Custom metadata stored with the object, if the provider keeps it.
+}
+
+// ErrNotExist is returned by Stat and Get when there is no object for the reference.
+var ErrNotExist = errors.New("storage: object does not exist")
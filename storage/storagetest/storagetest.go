@@ -0,0 +1,145 @@
+// Package storagetest checks that storage providers behave the same way.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wptide/pkg/storage"
+)
+
+// TestManager uploads, lists, inspects and deletes objects with an empty storage.Manager.
+func TestManager(t *testing.T, m storage.Manager) {
+
+	tmp, err := ioutil.TempDir("", "tide-storagetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	objects := map[string]string{
+		"abc-phpcs.json":      `{"phpcs":true}`,
+		"abc-lighthouse.json": `{"lighthouse":true}`,
+		"def-phpcs.json":      `{}`,
+	}
+	for reference, content := range objects {
+		filename := filepath.Join(tmp, reference)
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.UploadFile(filename, reference); err != nil {
+			t.Fatalf("%s.UploadFile() error = %v", m.Kind(), err)
+		}
+	}
+
+	t.Run("Exists", func(t *testing.T) {
+		if ok, err := m.Exists("abc-phpcs.json"); !ok || err != nil {
+			t.Errorf("%s.Exists() = %v, %v, want true", m.Kind(), ok, err)
+		}
+		if ok, err := m.Exists("missing.json"); ok || err != nil {
+			t.Errorf("%s.Exists() = %v, %v, want false", m.Kind(), ok, err)
+		}
+	})
+
+	t.Run("Stat", func(t *testing.T) {
+		info, err := m.Stat("abc-phpcs.json")
+		if err != nil {
+			t.Fatalf("%s.Stat() error = %v", m.Kind(), err)
+		}
+		if info.Reference != "abc-phpcs.json" || info.Size != int64(len(objects["abc-phpcs.json"])) || info.Modified.IsZero() {
+			t.Errorf("%s.Stat() = %+v, want the uploaded object", m.Kind(), info)
+		}
+		if _, err := m.Stat("missing.json"); err != storage.ErrNotExist {
+			t.Errorf("%s.Stat() error = %v, want %v", m.Kind(), err, storage.ErrNotExist)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		list, err := m.List("abc-")
+		if err != nil {
+			t.Fatalf("%s.List() error = %v", m.Kind(), err)
+		}
+		if len(list) != 2 || list[0].Reference != "abc-lighthouse.json" || list[1].Reference != "abc-phpcs.json" {
+			t.Errorf("%s.List() = %+v, want the two abc- objects in order", m.Kind(), list)
+		}
+
+		all, err := m.List("")
+		if err != nil || len(all) != len(objects) {
+			t.Errorf("%s.List() = %+v, %v, want %d objects", m.Kind(), all, err, len(objects))
+		}
+	})
+
+	t.Run("Download", func(t *testing.T) {
+		filename := filepath.Join(tmp, "download.json")
+		if err := m.DownloadFile("abc-lighthouse.json", filename); err != nil {
+			t.Fatalf("%s.DownloadFile() error = %v", m.Kind(), err)
+		}
+		if got, _ := ioutil.ReadFile(filename); string(got) != objects["abc-lighthouse.json"] {
+			t.Errorf("%s.DownloadFile() = %s, want %s", m.Kind(), got, objects["abc-lighthouse.json"])
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := m.Delete("def-phpcs.json"); err != nil {
+			t.Fatalf("%s.Delete() error = %v", m.Kind(), err)
+		}
+		if ok, _ := m.Exists("def-phpcs.json"); ok {
+			t.Errorf("%s.Delete() did not delete the object", m.Kind())
+		}
+		if err := m.Delete("def-phpcs.json"); err != nil {
+			t.Errorf("%s.Delete() of a missing object error = %v, want nil", m.Kind(), err)
+		}
+	})
+}
+
+// failingReader returns an error after the first read.
+type failingReader struct {
+	read bool
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.read {
+		return 0, errors.New("read failed")
+	}
+	r.read = true
+	return copy(p, "partial"), nil
+}
+
+// TestStreamer writes and reads objects with an empty storage.Streamer.
+func TestStreamer(t *testing.T, s storage.Streamer) {
+	ctx := context.Background()
+	content := `{"streamed":true}`
+
+	if err := s.Put(ctx, "abc-stream.json", strings.NewReader(content), map[string]string{"tool": "phpcs"}); err != nil {
+		t.Fatalf("%s.Put() error = %v", s.Kind(), err)
+	}
+
+	t.Run("Get", func(t *testing.T) {
+		r, err := s.Get(ctx, "abc-stream.json")
+		if err != nil {
+			t.Fatalf("%s.Get() error = %v", s.Kind(), err)
+		}
+		defer r.Close()
+
+		if got, _ := ioutil.ReadAll(r); string(got) != content {
+			t.Errorf("%s.Get() = %s, want %s", s.Kind(), got, content)
+		}
+	})
+
+	t.Run("Get Missing", func(t *testing.T) {
+		if _, err := s.Get(ctx, "missing.json"); err != storage.ErrNotExist {
+			t.Errorf("%s.Get() error = %v, want %v", s.Kind(), err, storage.ErrNotExist)
+		}
+	})
+
+	t.Run("Put Read Error", func(t *testing.T) {
+		if err := s.Put(ctx, "abc-failed.json", &failingReader{}, nil); err == nil {
+			t.Errorf("%s.Put() error = nil, want the read error", s.Kind())
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// Put stores the content of r with the provider, through a temp file if the provider is not a Streamer.
+// The metadata is only kept by Streamers.
+func Put(ctx context.Context, p Provider, reference string, r io.Reader, metadata map[string]string) error {
+	if streamer, ok := p.(Streamer); ok {
+		return streamer.Put(ctx, reference, r, metadata)
+	}
+
+	tmp, err := ioutil.TempFile("", "tide-storage")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	return p.UploadFile(tmp.Name(), reference)
+}
+
+// UploadFile uploads the file with the metadata if the provider is a Streamer, and without it otherwise.
+func UploadFile(ctx context.Context, p Provider, filename, reference string, metadata map[string]string) error {
+	streamer, ok := p.(Streamer)
+	if !ok {
+		return p.UploadFile(filename, reference)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return streamer.Put(ctx, reference, file, metadata)
+}
+
+// Get returns a reader for the object, downloading it to a temp file if the provider is not a Streamer.
+// The reader must be closed.
+func Get(ctx context.Context, p Provider, reference string) (io.ReadCloser, error) {
+	if streamer, ok := p.(Streamer); ok {
+		return streamer.Get(ctx, reference)
+	}
+
+	tmp, err := ioutil.TempFile("", "tide-storage")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+
+	if err := p.DownloadFile(reference, tmp.Name()); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	file, err := os.Open(tmp.Name())
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return tempFile{file}, nil
+}
+
+// tempFile removes itself when closed.
+type tempFile struct {
+	*os.File
+}
+
+func (t tempFile) Close() error {
+	err := t.File.Close()
+	if rerr := os.Remove(t.Name()); err == nil {
+		err = rerr
+	}
+	return err
+}
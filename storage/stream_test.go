@@ -0,0 +1,103 @@
+package storage_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/storage/memory"
+)
+
+// fileStorage hides the streaming methods of a memory provider.
+type fileStorage struct {
+	provider *memory.Provider
+}
+
+func (f fileStorage) Kind() string          { return f.provider.Kind() }
+func (f fileStorage) CollectionRef() string { return f.provider.CollectionRef() }
+func (f fileStorage) UploadFile(filename, reference string) error {
+	return f.provider.UploadFile(filename, reference)
+}
+func (f fileStorage) DownloadFile(reference, filename string) error {
+	return f.provider.DownloadFile(reference, filename)
+}
+
+func TestUploadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tide-storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "report.json")
+	ioutil.WriteFile(filename, []byte(`{"phpcs":true}`), 0644)
+	metadata := map[string]string{storage.MetaSlug: "twentyseventeen"}
+
+	tests := []struct {
+		name     string
+		provider *memory.Provider
+		wrap     bool
+		wantSlug string
+	}{
+		{"Streamer", memory.NewMemoryStorage("reports"), false, "twentyseventeen"},
+		{"Provider", memory.NewMemoryStorage("reports"), true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p storage.Provider = tt.provider
+			if tt.wrap {
+				p = fileStorage{tt.provider}
+			}
+
+			if err := storage.UploadFile(context.Background(), p, filename, "abc-phpcs.json", metadata); err != nil {
+				t.Fatalf("UploadFile() error = %v", err)
+			}
+
+			info, err := tt.provider.Stat("abc-phpcs.json")
+			if err != nil || info.Size != 14 || info.Metadata[storage.MetaSlug] != tt.wantSlug {
+				t.Errorf("UploadFile() stored %+v, %v, want slug %q", info, err, tt.wantSlug)
+			}
+
+			// Put and Get work through temp files for providers that can't stream.
+			if err := storage.Put(context.Background(), p, "abc-put.json", strings.NewReader("{}"), nil); err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+			r, err := storage.Get(context.Background(), p, "abc-put.json")
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			data, _ := ioutil.ReadAll(r)
+			r.Close()
+			if string(data) != "{}" {
+				t.Errorf("Get() = %s, want {}", data)
+			}
+
+			if _, err := storage.Get(context.Background(), p, "missing.json"); err != storage.ErrNotExist {
+				t.Errorf("Get() error = %v, want %v", err, storage.ErrNotExist)
+			}
+		})
+	}
+
+	if err := storage.UploadFile(context.Background(), memory.NewMemoryStorage("reports"), filepath.Join(dir, "missing.json"), "missing.json", nil); err == nil {
+		t.Errorf("UploadFile() error = nil, want error for a missing file")
+	}
+}
+
+func TestTypeByExtension(t *testing.T) {
+	tests := map[string]string{
+		"abc-phpcs.json":      "application/json",
+		"reports/REPORT.JSON": "application/json",
+		"index.html":          "text/html; charset=utf-8",
+		"archive":             "application/octet-stream",
+		"archive.unknown-ext": "application/octet-stream",
+	}
+	for reference, want := range tests {
+		if got := storage.TypeByExtension(reference); got != want {
+			t.Errorf("TypeByExtension(%q) = %q, want %q", reference, got, want)
+		}
+	}
+}
@@ -22,6 +22,8 @@ type Item struct {
 	Standards     []string               `json:"standards,omitempty"`      // Will potentially be overriden in API and should not be relied upon.
 	RequestClient string                 `json:"request_client,omitempty"` // Will be converted to a user.
 	Project       []string               `json:"project,omitempty"`        // Has to be an array of string because of how taxonomies work in WordPress.
+	Manifest      *AuditDetails          `json:"manifest,omitempty"`       // Stored list of the audited files with their checksums.
+	Revision      string                 `json:"revision,omitempty"`       // Version control revision of the audited code.
 }
 
 // CodeInfo contains the details about the files being processed.
@@ -96,6 +98,9 @@ type AuditDetails struct {
 	Type     string `json:"type,omitempty"`
 	FileName string `json:"filename,omitempty"`
 	Path     string `json:"path,omitempty"`
+	Encoding string `json:"encoding,omitempty"`    // Content encoding of the file, e.g. "gzip".
+	URL      string `json:"url,omitempty"`         // Expiring download link for the file.
+	Expires  int64  `json:"url_expires,omitempty"` // Unix time when the URL stops working.
 	*PhpcsResults
 	*LighthouseResults
 }
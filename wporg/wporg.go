@@ -6,8 +6,10 @@ import (
 	"errors"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
 /*
@@ -22,6 +24,9 @@ const (
 var (
 	themesAPIURL  = "https://api.wordpress.org/themes/info/1.1/"
 	pluginsAPIURL = "https://api.wordpress.org/plugins/info/1.1/"
+
+	// httpClient is used to look up single projects. Using a variable so that we can mock it in tests.
+	httpClient = &http.Client{Timeout: 30 * time.Second}
 )
 
 // APIInfo contains the results from a call to the WordPress.org theme/plugin API.
@@ -51,7 +56,7 @@ func (rp *RepoProject) UnmarshalJSON(d []byte) error {
 	temp := struct {
 		altRepoProject
 		// Override the version.
-		Version json.Number `json:"version"`
+		Version projectVersion `json:"version"`
 	}{
 		// Pass in original project pointer so that other fields are not skipped.
 		altRepoProject: altRepoProject(*rp),
@@ -64,11 +69,91 @@ func (rp *RepoProject) UnmarshalJSON(d []byte) error {
 
 	// Pass it back to the original.
 	*rp = RepoProject(temp.altRepoProject)
-	rp.Version = temp.Version.String() // convert the numeric version into string version/
+	rp.Version = string(temp.Version) // convert the numeric version into string version/
+
+	return nil
+}
+
+// projectVersion is a version given as a string, e.g. "5.3.1", or as a number, e.g. 3.
+type projectVersion string
+
+// UnmarshalJSON is a custom unmarshaller for projectVersion to accept both forms.
+func (v *projectVersion) UnmarshalJSON(d []byte) error {
+	var version string
+	if err := json.Unmarshal(d, &version); err == nil {
+		*v = projectVersion(version)
+		return nil
+	}
+
+	var number json.Number
+	if err := json.Unmarshal(d, &number); err != nil {
+		return err
+	}
+	*v = projectVersion(number.String())
+
+	return nil
+}
+
+// Versions maps the released versions of a project to their download links.
+type Versions map[string]string
+
+// UnmarshalJSON is a custom unmarshaller for Versions as the API returns an empty array instead of an empty object.
+func (v *Versions) UnmarshalJSON(d []byte) error {
+	if string(bytes.TrimSpace(d)) == "[]" {
+		*v = nil
+		return nil
+	}
+
+	var versions map[string]string
+	if err := json.Unmarshal(d, &versions); err != nil {
+		return err
+	}
+	*v = versions
+
+	return nil
+}
+
+// ProjectInfo describes a single project with the versions that can be downloaded.
+type ProjectInfo struct {
+	RepoProject
+	Versions Versions
+}
+
+// UnmarshalJSON is a custom unmarshaller for ProjectInfo as RepoProject has its own.
+func (pi *ProjectInfo) UnmarshalJSON(d []byte) error {
+	if err := json.Unmarshal(d, &pi.RepoProject); err != nil {
+		return err
+	}
+
+	temp := struct {
+		Versions Versions `json:"versions"`
+	}{}
+	if err := json.Unmarshal(d, &temp); err != nil {
+		return err
+	}
+	pi.Versions = temp.Versions
 
 	return nil
 }
 
+// DownloadLinkFor returns the download link of a version of the project.
+// An empty version returns the link of the current version.
+func (pi ProjectInfo) DownloadLinkFor(version string) (string, error) {
+	if version == "" || version == pi.Version {
+		if pi.DownloadLink == "" {
+			return "", errors.New("no download link for " + pi.Slug)
+		}
+		return pi.DownloadLink, nil
+	}
+
+	link, ok := pi.Versions[version]
+	if !ok || link == "" {
+		return "", errors.New("version " + version + " of " + pi.Slug + " not found")
+	}
+
+	return link, nil
+}
+
 // APIResponse describes a reponse from the WordPress.org theme/plugin API.
 type APIResponse struct {
 	Info APIInfo `json:"info"`
@@ -172,6 +257,65 @@ func (c Client) Request(source, projectType, category string, perPage, page int)
 	return &results, nil
 }
 
+// RequestProject gets the information of a single project, including its versions, from the WordPress.org API's.
+// `projectType` should be plural "themes" or "plugins".
+func (c *Client) RequestProject(projectType, slug string) (*ProjectInfo, error) {
+	var source, action string
+	switch projectType {
+	case "themes":
+		if c.themeAPI == "" {
+			c.themeAPI = themesAPIURL
+		}
+		source, action = c.themeAPI, "theme_information"
+	case "plugins":
+		if c.pluginAPI == "" {
+			c.pluginAPI = pluginsAPIURL
+		}
+		source, action = c.pluginAPI, "plugin_information"
+	default:
+		return nil, errors.New("unknown project type: " + projectType)
+	}
+
+	formValues := url.Values{
+		"action":                         {action},
+		"request[slug]":                  {slug},
+		"request[fields][sections]":      {"0"},
+		"request[fields][description]":   {"0"},
+		"request[fields][versions]":      {"1"},
+		"request[fields][download_link]": {"1"},
+	}
+
+	response, err := httpClient.PostForm(source, formValues)
+	if err != nil {
+		return nil, errors.New("could not retrieve " + slug + " from " + source)
+	}
+	defer response.Body.Close()
+	bodyByte, _ := ioutil.ReadAll(response.Body)
+
+	// The API responds with an error object (or "null" for themes) for unknown slugs.
+	var apiError struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(bodyByte, &apiError); err == nil && apiError.Error != "" {
+		return nil, errors.New(slug + ": " + apiError.Error)
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, errors.New("could not retrieve " + slug + " from " + source + ": " + response.Status)
+	}
+
+	project := ProjectInfo{}
+	if err := json.Unmarshal(bodyByte, &project); err != nil {
+		return nil, err
+	}
+	if project.Slug == "" {
+		return nil, errors.New(slug + ": project not found")
+	}
+
+	project.Type = projectType
+
+	return &project, nil
+}
+
 // RequestThemes is a convenience method.
 func (c *Client) RequestThemes(category string, perPage, page int) (*APIResponse, error) {
 	if c.themeAPI == "" {
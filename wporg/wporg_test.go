@@ -9,6 +9,7 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 )
 
 var mockThemesAPI = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -560,3 +561,245 @@ func TestClient_SetThemeApiSource(t *testing.T) {
 		})
 	}
 }
+
+var mockProjectAPI = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	switch r.PostForm.Get("request[slug]") {
+	case "akismet":
+		fmt.Fprintln(w, `{
+			"name": "Akismet",
+			"slug": "akismet",
+			"version": "5.3.1",
+			"download_link": "https://downloads.wordpress.org/plugin/akismet.5.3.1.zip",
+			"versions": {
+				"5.2": "https://downloads.wordpress.org/plugin/akismet.5.2.zip",
+				"5.3": "https://downloads.wordpress.org/plugin/akismet.5.3.zip",
+				"5.3.1": "https://downloads.wordpress.org/plugin/akismet.5.3.1.zip"
+			}
+		}`)
+	case "twentyten":
+		fmt.Fprintln(w, `{
+			"name": "Twenty Ten",
+			"slug": "twentyten",
+			"version": 3,
+			"download_link": "https://downloads.wordpress.org/theme/twentyten.3.zip",
+			"versions": []
+		}`)
+	case "twentytwenty":
+		fmt.Fprintln(w, `{
+			"name": "Twenty Twenty",
+			"slug": "twentytwenty",
+			"version": 2.1,
+			"download_link": "https://downloads.wordpress.org/theme/twentytwenty.2.1.zip",
+			"versions": {
+				"2.0": "https://downloads.wordpress.org/theme/twentytwenty.2.0.zip",
+				"2.0.1": "https://downloads.wordpress.org/theme/twentytwenty.2.0.1.zip"
+			}
+		}`)
+	case "missing":
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintln(w, `{"error": "Plugin not found."}`)
+	case "unavailable":
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, `{"name": "Unavailable", "slug": "unavailable"}`)
+	case "slow":
+		time.Sleep(100 * time.Millisecond)
+		fmt.Fprintln(w, `{"name": "Slow", "slug": "slow"}`)
+	case "null":
+		fmt.Fprintln(w, `null`)
+	default:
+		fmt.Fprintln(w, `invalid`)
+	}
+}))
+
+func TestClient_RequestProject(t *testing.T) {
+	type args struct {
+		projectType string
+		slug        string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *ProjectInfo
+		wantErr bool
+	}{
+		{
+			"Plugin",
+			args{
+				"plugins",
+				"akismet",
+			},
+			&ProjectInfo{
+				RepoProject: RepoProject{
+					Name:         "Akismet",
+					Slug:         "akismet",
+					Version:      "5.3.1",
+					DownloadLink: "https://downloads.wordpress.org/plugin/akismet.5.3.1.zip",
+					Type:         "plugins",
+				},
+				Versions: Versions{
+					"5.2":   "https://downloads.wordpress.org/plugin/akismet.5.2.zip",
+					"5.3":   "https://downloads.wordpress.org/plugin/akismet.5.3.zip",
+					"5.3.1": "https://downloads.wordpress.org/plugin/akismet.5.3.1.zip",
+				},
+			},
+			false,
+		},
+		{
+			"Theme Without Versions",
+			args{
+				"themes",
+				"twentyten",
+			},
+			&ProjectInfo{
+				RepoProject: RepoProject{
+					Name:         "Twenty Ten",
+					Slug:         "twentyten",
+					Version:      "3",
+					DownloadLink: "https://downloads.wordpress.org/theme/twentyten.3.zip",
+					Type:         "themes",
+				},
+			},
+			false,
+		},
+		{
+			"Theme With Numeric Version",
+			args{
+				"themes",
+				"twentytwenty",
+			},
+			&ProjectInfo{
+				RepoProject: RepoProject{
+					Name:         "Twenty Twenty",
+					Slug:         "twentytwenty",
+					Version:      "2.1",
+					DownloadLink: "https://downloads.wordpress.org/theme/twentytwenty.2.1.zip",
+					Type:         "themes",
+				},
+				Versions: Versions{
+					"2.0":   "https://downloads.wordpress.org/theme/twentytwenty.2.0.zip",
+					"2.0.1": "https://downloads.wordpress.org/theme/twentytwenty.2.0.1.zip",
+				},
+			},
+			false,
+		},
+		{
+			"API Error",
+			args{
+				"plugins",
+				"missing",
+			},
+			nil,
+			true,
+		},
+		{
+			"Error Status",
+			args{
+				"plugins",
+				"unavailable",
+			},
+			nil,
+			true,
+		},
+		{
+			"Timeout",
+			args{
+				"plugins",
+				"slow",
+			},
+			nil,
+			true,
+		},
+		{
+			"Not Found",
+			args{
+				"themes",
+				"null",
+			},
+			nil,
+			true,
+		},
+		{
+			"Invalid JSON",
+			args{
+				"plugins",
+				"invalid",
+			},
+			nil,
+			true,
+		},
+		{
+			"Unknown Type",
+			args{
+				"blocks",
+				"akismet",
+			},
+			nil,
+			true,
+		},
+	}
+	oldClient := httpClient
+	httpClient = &http.Client{Timeout: 50 * time.Millisecond}
+	defer func() {
+		httpClient = oldClient
+	}()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{}
+			c.SetPluginAPISource(mockProjectAPI.URL)
+			c.SetThemeAPISource(mockProjectAPI.URL)
+
+			got, err := c.RequestProject(tt.args.projectType, tt.args.slug)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Client.RequestProject() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Client.RequestProject() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProjectInfo_DownloadLinkFor(t *testing.T) {
+	project := ProjectInfo{
+		RepoProject: RepoProject{
+			Slug:         "akismet",
+			Version:      "5.3",
+			DownloadLink: "https://downloads.wordpress.org/plugin/akismet.5.3.zip",
+		},
+		Versions: Versions{
+			"5.2":   "https://downloads.wordpress.org/plugin/akismet.5.2.zip",
+			"5.2.1": "https://downloads.wordpress.org/plugin/akismet.5.2.1.zip",
+		},
+	}
+
+	tests := []struct {
+		name    string
+		project ProjectInfo
+		version string
+		want    string
+		wantErr bool
+	}{
+		{"Latest", project, "", "https://downloads.wordpress.org/plugin/akismet.5.3.zip", false},
+		{"Current Version", project, "5.3", "https://downloads.wordpress.org/plugin/akismet.5.3.zip", false},
+		{"Older Version", project, "5.2", "https://downloads.wordpress.org/plugin/akismet.5.2.zip", false},
+		{"Patch Version", project, "5.2.1", "https://downloads.wordpress.org/plugin/akismet.5.2.1.zip", false},
+		{"Unknown Version", project, "1.0", "", true},
+		{"No Download Link", ProjectInfo{RepoProject: RepoProject{Slug: "closed"}}, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.project.DownloadLinkFor(tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ProjectInfo.DownloadLinkFor() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ProjectInfo.DownloadLinkFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
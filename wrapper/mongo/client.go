@@ -72,6 +72,9 @@ type CollectionLayer interface {
 	FindOne(ctx context.Context, filter interface{}, opts ...option.FindOneOptioner) DocumentResultLayer
 	FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, opts ...option.FindOneAndUpdateOptioner) DocumentResultLayer
 	FindOneAndDelete(ctx context.Context, filter interface{}, opts ...option.FindOneAndDeleteOptioner) DocumentResultLayer
+	Find(ctx context.Context, filter interface{}, opts ...option.FindOptioner) (CursorLayer, error)
+	UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...option.UpdateOptioner) (*mongo.UpdateResult, error)
+	DeleteMany(ctx context.Context, filter interface{}, opts ...option.DeleteOptioner) (*mongo.DeleteResult, error)
 }
 
 // WrapperCollection wraps mongo.Collection.
@@ -133,6 +136,49 @@ func (c WrapperCollection) FindOneAndDelete(ctx context.Context, filter interfac
 	return docResult
 }
 
+// Find finds the documents matching the filter.
+func (c WrapperCollection) Find(ctx context.Context, filter interface{}, opts ...option.FindOptioner) (cursor CursorLayer, err error) {
+	// Recover on panic() from mongo driver.
+	defer func() {
+		if r := recover(); r != nil {
+			cursor = nil
+			err = errors.New("mongodb: collection find error")
+		}
+	}()
+
+	res, err := c.Collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &WrapperCursor{res}, nil
+}
+
+// UpdateMany updates all the documents matching the filter.
+func (c WrapperCollection) UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...option.UpdateOptioner) (result *mongo.UpdateResult, err error) {
+	// Recover on panic() from mongo driver.
+	defer func() {
+		if r := recover(); r != nil {
+			result = nil
+			err = errors.New("mongodb: collection update error")
+		}
+	}()
+
+	return c.Collection.UpdateMany(ctx, filter, update, opts...)
+}
+
+// DeleteMany removes all the documents matching the filter.
+func (c WrapperCollection) DeleteMany(ctx context.Context, filter interface{}, opts ...option.DeleteOptioner) (result *mongo.DeleteResult, err error) {
+	// Recover on panic() from mongo driver.
+	defer func() {
+		if r := recover(); r != nil {
+			result = nil
+			err = errors.New("mongodb: collection delete error")
+		}
+	}()
+
+	return c.Collection.DeleteMany(ctx, filter, opts...)
+}
+
 // InsertOneResultLayer is an empty interface. No methods are required for this.
 // Everything implements this.
 type InsertOneResultLayer interface{}
@@ -160,3 +206,23 @@ func (d WrapperDocumentResult) Decode() (*bson.Document, error) {
 	err := d.DocumentResult.Decode(elem)
 	return elem, err
 }
+
+// CursorLayer abstracts the methods of mongo.Cursor used to iterate the results of Find().
+type CursorLayer interface {
+	Next(ctx context.Context) bool
+	Decode() (*bson.Document, error)
+	Err() error
+	Close(ctx context.Context) error
+}
+
+// WrapperCursor wraps mongo.Cursor.
+type WrapperCursor struct {
+	mongo.Cursor
+}
+
+// Decode decodes the current document into a bson document, like WrapperDocumentResult.Decode().
+func (c WrapperCursor) Decode() (*bson.Document, error) {
+	elem := bson.NewDocument()
+	err := c.Cursor.Decode(elem)
+	return elem, err
+}
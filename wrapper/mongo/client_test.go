@@ -334,6 +334,125 @@ func TestMongoCollection_FindOneAndDelete(t *testing.T) {
 	}
 }
 
+func TestMongoCollection_Find(t *testing.T) {
+	type fields struct {
+		Collection CollectionLayer
+	}
+	type args struct {
+		ctx    context.Context
+		filter interface{}
+		opts   []option.FindOptioner
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		{
+			"Find()",
+			fields{
+				&WrapperCollection{},
+			},
+			args{
+				context.Background(),
+				nil,
+				nil,
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.fields.Collection
+
+			if got, err := c.Find(tt.args.ctx, tt.args.filter, tt.args.opts...); (err != nil) != tt.wantErr || (got == nil) != tt.wantErr {
+				t.Errorf("WrapperCollection.Find() = %v, error = %v, wantErr %v", got, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMongoCollection_UpdateMany(t *testing.T) {
+	type fields struct {
+		Collection CollectionLayer
+	}
+	type args struct {
+		ctx    context.Context
+		filter interface{}
+		update interface{}
+		opts   []option.UpdateOptioner
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		{
+			"UpdateMany()",
+			fields{
+				&WrapperCollection{},
+			},
+			args{
+				context.Background(),
+				nil,
+				nil,
+				nil,
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.fields.Collection
+
+			if _, err := c.UpdateMany(tt.args.ctx, tt.args.filter, tt.args.update, tt.args.opts...); (err != nil) != tt.wantErr {
+				t.Errorf("WrapperCollection.UpdateMany() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMongoCollection_DeleteMany(t *testing.T) {
+	type fields struct {
+		Collection CollectionLayer
+	}
+	type args struct {
+		ctx    context.Context
+		filter interface{}
+		opts   []option.DeleteOptioner
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		{
+			"DeleteMany()",
+			fields{
+				&WrapperCollection{},
+			},
+			args{
+				context.Background(),
+				nil,
+				nil,
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.fields.Collection
+
+			if _, err := c.DeleteMany(tt.args.ctx, tt.args.filter, tt.args.opts...); (err != nil) != tt.wantErr {
+				t.Errorf("WrapperCollection.DeleteMany() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestMongoDocumentResult_Decode(t *testing.T) {
 
 	type fields struct {